@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"github.com/GlebMoskalev/todo-api/internal/apiversion"
+	"github.com/GlebMoskalev/todo-api/internal/archiver"
+	"github.com/GlebMoskalev/todo-api/internal/blobstore"
 	"github.com/GlebMoskalev/todo-api/internal/database"
+	"github.com/GlebMoskalev/todo-api/internal/idempotency"
+	"github.com/GlebMoskalev/todo-api/internal/models/attachment"
+	"github.com/GlebMoskalev/todo-api/internal/openapi"
 	"github.com/GlebMoskalev/todo-api/internal/repository"
 	"github.com/GlebMoskalev/todo-api/internal/routes"
 	"github.com/joho/godotenv"
@@ -10,6 +17,8 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 )
 
 func init() {
@@ -38,8 +47,105 @@ func main() {
 
 	logger.Info("Database connection established successfully.")
 
+	blobStore, err := blobstore.NewLocalBlobStore(getEnv("ATTACHMENTS_DIR", "attachments"))
+	if err != nil {
+		logger.Error("Error initializing blob store", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	maxAttachmentSize := int64(attachment.DefaultMaxSize)
+	if rawMaxSize := os.Getenv("ATTACHMENTS_MAX_SIZE"); rawMaxSize != "" {
+		maxAttachmentSize, err = strconv.ParseInt(rawMaxSize, 10, 64)
+		if err != nil || maxAttachmentSize <= 0 {
+			logger.Error("Invalid ATTACHMENTS_MAX_SIZE", slog.String("value", rawMaxSize))
+			os.Exit(1)
+		}
+	}
+
+	archiveAfterDays, err := strconv.Atoi(getEnv("ARCHIVE_AFTER_DAYS", "30"))
+	if err != nil || archiveAfterDays < 0 {
+		logger.Error("Invalid ARCHIVE_AFTER_DAYS", slog.String("value", os.Getenv("ARCHIVE_AFTER_DAYS")))
+		os.Exit(1)
+	}
+
+	var openAPIValidator *openapi.Validator
+	validationMode, err := openapi.ParseMode(os.Getenv("OPENAPI_VALIDATION"))
+	if err != nil {
+		logger.Error("Invalid OPENAPI_VALIDATION", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if validationMode != openapi.Off {
+		openAPIValidator = openapi.NewValidator(validationMode, logger)
+	}
+
+	var idempotencyStore idempotency.Store
+	switch storeKind := getEnv("IDEMPOTENCY_STORE", "postgres"); storeKind {
+	case "postgres":
+		idempotencyStore = idempotency.NewPostgresStore(db, logger)
+	case "memory":
+		idempotencyStore = idempotency.NewMemoryStore()
+	case "off":
+	default:
+		logger.Error("Invalid IDEMPOTENCY_STORE", slog.String("value", storeKind))
+		os.Exit(1)
+	}
+	idempotencyTTL := idempotency.DefaultTTL
+	if rawTTL := os.Getenv("IDEMPOTENCY_TTL"); rawTTL != "" {
+		idempotencyTTL, err = time.ParseDuration(rawTTL)
+		if err != nil || idempotencyTTL <= 0 {
+			logger.Error("Invalid IDEMPOTENCY_TTL", slog.String("value", rawTTL))
+			os.Exit(1)
+		}
+	}
+	if idempotencyStore != nil {
+		go idempotency.RunCleanup(context.Background(), idempotencyStore, time.Hour, logger)
+	}
+
+	sunsets := make(map[apiversion.Version]time.Time)
+	if rawSunset := os.Getenv("API_V1_SUNSET"); rawSunset != "" {
+		sunset, err := time.Parse(time.DateOnly, rawSunset)
+		if err != nil {
+			logger.Error("Invalid API_V1_SUNSET", slog.String("value", rawSunset))
+			os.Exit(1)
+		}
+		sunsets[apiversion.V1] = sunset
+	}
+
+	statusRepo := repository.NewStatusPostgresRepository(db, logger)
+	priorityRepo := repository.NewPriorityPostgresRepository(db, logger)
+	if err := statusRepo.Load(); err != nil {
+		logger.Error("Error loading statuses", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if err := priorityRepo.Load(); err != nil {
+		logger.Error("Error loading priorities", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
 	todoRepo := repository.NewTodoPostgresRepository(db, logger)
-	r := routes.SetupRouter(todoRepo)
+	if archiveAfterDays > 0 {
+		go archiver.Run(context.Background(), todoRepo, time.Duration(archiveAfterDays)*24*time.Hour, time.Hour, logger)
+	}
+
+	r := routes.SetupRouter(routes.Dependencies{
+		TodoRepo:          todoRepo,
+		CommentRepo:       repository.NewCommentPostgresRepository(db, logger),
+		AttachmentRepo:    repository.NewAttachmentPostgresRepository(db, logger),
+		ProjectRepo:       repository.NewProjectPostgresRepository(db, logger),
+		TagRepo:           repository.NewTagPostgresRepository(db, logger),
+		TimeEntryRepo:     repository.NewTimeEntryPostgresRepository(db, logger),
+		CustomFieldRepo:   repository.NewCustomFieldPostgresRepository(db, logger),
+		TemplateRepo:      repository.NewTemplatePostgresRepository(db, logger),
+		UserRepo:          repository.NewUserPostgresRepository(db, logger),
+		StatusRepo:        statusRepo,
+		PriorityRepo:      priorityRepo,
+		BlobStore:         blobStore,
+		MaxAttachmentSize: maxAttachmentSize,
+		OpenAPIValidator:  openAPIValidator,
+		IdempotencyStore:  idempotencyStore,
+		IdempotencyTTL:    idempotencyTTL,
+		Sunsets:           sunsets,
+	})
 	http.ListenAndServe(":8080", r)
 }
 
@@ -64,3 +170,10 @@ func setupLogger() *slog.Logger {
 	logger.Debug("Logger initialized", slog.String("level", os.Getenv("LOG_LEVEL")))
 	return logger
 }
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,111 @@
+// Package apiversion tells handlers which version of the API a request
+// targets. The version comes from the path prefix (/v1, /v2) or, on
+// unversioned paths, from the Accept header.
+package apiversion
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Version int
+
+const (
+	// V1 is the original API, also served on unversioned paths by default.
+	V1 Version = 1
+	// V2 drops the legacy routes taking ids in the body and always encodes
+	// lists as arrays, never null.
+	V2 Version = 2
+)
+
+// Versions lists the supported versions, oldest first.
+var Versions = []Version{V1, V2}
+
+// Header names the version that served a response.
+const Header = "API-Version"
+
+func (v Version) String() string {
+	return "v" + strconv.Itoa(int(v))
+}
+
+// MediaType is the vendor media type selecting version v, e.g.
+// application/vnd.todo-api.v2+json.
+func (v Version) MediaType() string {
+	return "application/vnd.todo-api." + v.String() + "+json"
+}
+
+func (v Version) supported() bool {
+	return v >= V1 && v <= V2
+}
+
+type contextKey struct{}
+
+// Middleware marks requests as targeting v.
+func Middleware(v Version) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(Header, strconv.Itoa(int(v)))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, v)))
+		})
+	}
+}
+
+// FromContext returns the version of the request, V1 outside a versioned
+// router.
+func FromContext(ctx context.Context) Version {
+	if v, ok := ctx.Value(contextKey{}).(Version); ok {
+		return v
+	}
+	return V1
+}
+
+// FromAccept returns the version an Accept header asks for with the vendor
+// media type or a version parameter, as in "application/json; version=2".
+// It returns 0 when the header names no version.
+func FromAccept(accept string) (Version, error) {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		raw, ok := params["version"]
+		if !ok {
+			vendor, isVendor := strings.CutPrefix(mediaType, "application/vnd.todo-api.v")
+			if !isVendor {
+				continue
+			}
+			raw = strings.TrimSuffix(vendor, "+json")
+		}
+		number, err := strconv.Atoi(strings.TrimPrefix(raw, "v"))
+		if err != nil || !Version(number).supported() {
+			return 0, fmt.Errorf("unsupported API version %q, expected 1 or 2", raw)
+		}
+		return Version(number), nil
+	}
+	return 0, nil
+}
+
+// Deprecated marks responses of a deprecated version with the Deprecation
+// header, the Sunset header when sunset is set, and a link to the same path
+// in successor. It goes after Middleware.
+func Deprecated(sunset time.Time, successor Version) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			path := r.URL.Path
+			if rest, ok := strings.CutPrefix(path, "/"+FromContext(r.Context()).String()+"/"); ok {
+				path = "/" + rest
+			}
+			w.Header().Add("Link", fmt.Sprintf(`</%s%s>; rel="successor-version"`, successor, path))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package archiver
+
+import (
+	"context"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"log/slog"
+	"time"
+)
+
+// Run archives todos that have been completed or canceled for longer than
+// after. It checks once at start and then every interval until ctx is done.
+func Run(ctx context.Context, repo *repository.TodoPostgresRepository, after, interval time.Duration,
+	logger *slog.Logger) {
+	logger.Info("Starting archiver", slog.Duration("after", after), slog.Duration("interval", interval))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		archived, err := repo.ArchiveCompleted(after)
+		if err != nil {
+			logger.Error("Failed to archive todos", slog.String("error", err.Error()))
+		} else if archived > 0 {
+			logger.Info("Archived todos", slog.Int("count", archived))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package blobstore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+type ReadSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// BlobStore keeps the contents of uploaded files. Metadata lives in the
+// database; a store only knows about opaque keys.
+type BlobStore interface {
+	Put(key string, r io.Reader) (int64, error)
+	Open(key string) (ReadSeekCloser, error)
+	Delete(key string) error
+}
+
+func NewKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,44 @@
+package blobstore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+
+	key, err := NewKey()
+	assert.NoError(t, err)
+
+	written, err := store.Put(key, strings.NewReader("hello, world"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), written)
+
+	blob, err := store.Open(key)
+	assert.NoError(t, err)
+	_, err = blob.Seek(7, io.SeekStart)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(blob)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(content))
+	assert.NoError(t, blob.Close())
+
+	assert.NoError(t, store.Delete(key))
+	_, err = store.Open(key)
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+	assert.ErrorIs(t, store.Delete(key), ErrBlobNotFound)
+}
+
+func TestLocalBlobStoreRejectsInvalidKeys(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+
+	for _, key := range []string{"", "ab", "../../etc/passwd", "not-hex"} {
+		_, err := store.Put(key, strings.NewReader("data"))
+		assert.Error(t, err, key)
+	}
+}
@@ -0,0 +1,238 @@
+// Package codec lets handlers that speak JSON also read and write CSV, YAML
+// and MessagePack. Bodies are converted to and from JSON at the edge, so every
+// format has the JSON field names and value formats, such as YYYY-MM-DD for
+// all-day due dates.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MaxBodySize is the largest request body Middleware converts.
+const MaxBodySize = 64 << 20
+
+// Format is a representation of request and response bodies.
+type Format struct {
+	// MediaType is the Content-Type of bodies in the format.
+	MediaType string
+	// aliases are other media types naming the format.
+	aliases []string
+	// name tells the entity tags of the format apart.
+	name   string
+	encode func(value any) ([]byte, error)
+	// toJSON converts a request body to JSON for the handler, returning the
+	// converted body and its media type.
+	toJSON func(body []byte, columns map[string]cellKind) ([]byte, string, error)
+}
+
+var (
+	JSON = &Format{MediaType: "application/json", name: "json"}
+	// CSV bodies hold a header row naming the fields and one row per
+	// object. Lists are comma separated within a cell and nested objects are
+	// JSON. Cells a spreadsheet would run as formulas start with a quote,
+	// which requests may carry too.
+	CSV = &Format{
+		MediaType: "text/csv",
+		name:      "csv",
+		encode:    encodeCSV,
+		toJSON:    csvToJSON,
+	}
+	YAML = &Format{
+		MediaType: "application/yaml",
+		aliases:   []string{"application/x-yaml", "text/yaml", "text/x-yaml"},
+		name:      "yaml",
+		encode:    encodeYAML,
+		toJSON:    yamlToJSON,
+	}
+	MessagePack = &Format{
+		MediaType: "application/msgpack",
+		aliases:   []string{"application/x-msgpack", "application/vnd.msgpack"},
+		name:      "msgpack",
+		encode:    encodeMessagePack,
+		toJSON:    messagePackToJSON,
+	}
+)
+
+// Formats lists the supported formats, JSON first.
+var Formats = []*Format{JSON, CSV, YAML, MessagePack}
+
+func (f *Format) matches(mediaType string) bool {
+	if f == JSON && strings.HasSuffix(mediaType, "+json") {
+		return true
+	}
+	return mediaType == f.MediaType || slices.Contains(f.aliases, mediaType)
+}
+
+// ErrNotAcceptable is returned by Negotiate when the client accepts none of
+// the formats.
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// Negotiate picks the format the Accept header prefers, JSON when it is empty
+// or accepts anything.
+func Negotiate(accept string) (*Format, error) {
+	if strings.TrimSpace(accept) == "" {
+		return JSON, nil
+	}
+	var best *Format
+	bestQuality := 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if raw, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		format := forMediaRange(mediaType)
+		if format != nil && quality > bestQuality {
+			best, bestQuality = format, quality
+		}
+	}
+	if best == nil {
+		mediaTypes := make([]string, len(Formats))
+		for i, format := range Formats {
+			mediaTypes[i] = format.MediaType
+		}
+		return nil, fmt.Errorf("%w, expected one of %s", ErrNotAcceptable, strings.Join(mediaTypes, ", "))
+	}
+	return best, nil
+}
+
+func forMediaRange(mediaRange string) *Format {
+	switch mediaRange {
+	case "*/*", "application/*":
+		return JSON
+	case "text/*":
+		return CSV
+	}
+	return ForContentType(mediaRange)
+}
+
+// ForContentType returns the format of a Content-Type, or nil when it is none
+// of the formats.
+func ForContentType(contentType string) *Format {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	for _, format := range Formats {
+		if format.matches(mediaType) {
+			return format
+		}
+	}
+	return nil
+}
+
+// Middleware converts request bodies in the other formats to JSON and JSON
+// responses to the format the Accept header asks for, answering 406 when it
+// accepts none. The fields of schema, an example of the request bodies, type
+// the cells of CSV requests; CSV rows reach the handler as NDJSON.
+//
+// Entity tags of converted responses carry the name of the format, which is
+// removed from If-None-Match before the handler compares them.
+func Middleware(schema any) func(http.Handler) http.Handler {
+	columns := cellKinds(schema)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+			format, err := Negotiate(r.Header.Get("Accept"))
+			if err != nil {
+				w.WriteHeader(http.StatusNotAcceptable)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if from := ForContentType(r.Header.Get("Content-Type")); from != nil && from != JSON {
+				body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+				var maxBytesError *http.MaxBytesError
+				if errors.As(err, &maxBytesError) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					w.Write([]byte(err.Error()))
+					return
+				}
+				converted, mediaType := body, JSON.MediaType
+				if err == nil && len(bytes.TrimSpace(body)) > 0 {
+					converted, mediaType, err = from.toJSON(body, columns)
+				}
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(fmt.Sprintf("invalid %s body: %s", from.name, err)))
+					return
+				}
+				r = r.Clone(r.Context())
+				r.Body = io.NopCloser(bytes.NewReader(converted))
+				r.ContentLength = int64(len(converted))
+				r.Header.Set("Content-Type", mediaType)
+			}
+
+			if format == JSON {
+				next.ServeHTTP(w, r)
+				return
+			}
+			suffix := "-" + format.name + `"`
+			if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+				r = r.Clone(r.Context())
+				r.Header.Set("If-None-Match", strings.ReplaceAll(ifNoneMatch, suffix, `"`))
+			}
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			body := recorder.body.Bytes()
+			header := w.Header()
+			contentType := header.Get("Content-Type")
+			if (contentType == "" || ForContentType(contentType) == JSON) && json.Valid(body) {
+				value, err := parseJSON(body)
+				if err == nil {
+					body, err = format.encode(value)
+				}
+				if err != nil {
+					header.Del("ETag")
+					header.Set("Content-Type", "text/plain; charset=utf-8")
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(err.Error()))
+					return
+				}
+				header.Set("Content-Type", format.MediaType)
+				header.Del("Content-Length")
+			}
+			if etag := header.Get("ETag"); strings.HasSuffix(etag, `"`) {
+				header.Set("ETag", strings.TrimSuffix(etag, `"`)+suffix)
+			}
+			w.WriteHeader(recorder.status)
+			w.Write(body)
+		})
+	}
+}
+
+// responseRecorder holds back a response so that it can be converted.
+// Headers go straight to the underlying writer, which isn't written to.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(data)
+}
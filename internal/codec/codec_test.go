@@ -0,0 +1,292 @@
+package codec
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		accept   string
+		expected *Format
+	}{
+		{name: "no header", expected: JSON},
+		{name: "anything", accept: "*/*", expected: JSON},
+		{name: "json", accept: "application/json", expected: JSON},
+		{name: "vendor json", accept: "application/vnd.todo-api.v2+json", expected: JSON},
+		{name: "csv", accept: "text/csv", expected: CSV},
+		{name: "any text", accept: "text/*", expected: CSV},
+		{name: "yaml alias", accept: "application/x-yaml", expected: YAML},
+		{name: "msgpack with version", accept: "application/msgpack; version=2", expected: MessagePack},
+		{name: "quality", accept: "application/json;q=0.5, application/yaml", expected: YAML},
+		{name: "first of equal quality", accept: "text/csv, application/json", expected: CSV},
+		{name: "refused format", accept: "application/yaml;q=0, */*;q=0.1", expected: JSON},
+		{name: "browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: JSON},
+		{name: "unsupported", accept: "application/xml"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			format, err := Negotiate(tc.accept)
+			if tc.expected == nil {
+				assert.ErrorIs(t, err, ErrNotAcceptable)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected.MediaType, format.MediaType)
+		})
+	}
+}
+
+const todosJSON = `[{"id":1,"title":"pay rent","due_date":"2025-05-01","defer_until":null,"tags":["home","money"],` +
+	`"custom_fields":{"cost":1200.5},"overdue":false},{"id":2,"title":"call mom, again","due_date":"2025-05-02T18:00:00Z"}]`
+
+func TestMiddlewareResponses(t *testing.T) {
+	testCases := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{
+			name:   "csv",
+			accept: "text/csv",
+			expected: "id,title,due_date,defer_until,tags,custom_fields,overdue\n" +
+				`1,pay rent,2025-05-01,,"home,money","{""cost"":1200.5}",false` + "\n" +
+				`2,"call mom, again",2025-05-02T18:00:00Z,,,,` + "\n",
+		},
+		{
+			name:   "yaml",
+			accept: "application/yaml",
+			expected: `- id: 1
+  title: pay rent
+  due_date: "2025-05-01"
+  defer_until: null
+  tags:
+    - home
+    - money
+  custom_fields:
+    cost: 1200.5
+  overdue: false
+- id: 2
+  title: call mom, again
+  due_date: "2025-05-02T18:00:00Z"
+`,
+		},
+	}
+
+	handler := Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `W/"abc"`)
+		w.Write([]byte(todosJSON))
+	}))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/todo", nil)
+			request.Header.Set("Accept", tc.accept)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.expected, recorder.Body.String())
+			assert.Equal(t, strings.Split(tc.accept, ";")[0], recorder.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+			assert.NotEqual(t, `W/"abc"`, recorder.Header().Get("ETag"))
+		})
+	}
+
+	t.Run("msgpack", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/todo", nil)
+		request.Header.Set("Accept", "application/msgpack")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		var todos []map[string]any
+		assert.NoError(t, msgpack.Unmarshal(recorder.Body.Bytes(), &todos))
+		assert.Len(t, todos, 2)
+		assert.EqualValues(t, 1, todos[0]["id"])
+		assert.Equal(t, "2025-05-01", todos[0]["due_date"])
+		assert.Equal(t, []any{"home", "money"}, todos[0]["tags"])
+		assert.Equal(t, map[string]any{"cost": 1200.5}, todos[0]["custom_fields"])
+	})
+
+	t.Run("json untouched", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/todo", nil))
+		assert.Equal(t, todosJSON, recorder.Body.String())
+		assert.Equal(t, `W/"abc"`, recorder.Header().Get("ETag"))
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/todo", nil)
+		request.Header.Set("Accept", "application/xml")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusNotAcceptable, recorder.Code)
+	})
+
+	t.Run("plain text errors", func(t *testing.T) {
+		handler := Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("record not found"))
+		}))
+		request := httptest.NewRequest(http.MethodGet, "/todo/1", nil)
+		request.Header.Set("Accept", "text/csv")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		assert.Equal(t, "record not found", recorder.Body.String())
+	})
+}
+
+func TestMiddlewareConditionalRequests(t *testing.T) {
+	handler := Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	request := httptest.NewRequest(http.MethodGet, "/todo/1", nil)
+	request.Header.Set("Accept", "application/yaml")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	etag := recorder.Header().Get("ETag")
+	assert.Equal(t, `"abc-yaml"`, etag)
+
+	request.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Equal(t, etag, recorder.Header().Get("ETag"))
+}
+
+type example struct {
+	ID       int            `json:"id"`
+	Title    string         `json:"title"`
+	Done     bool           `json:"done"`
+	Tags     []string       `json:"tags"`
+	Estimate *int64         `json:"estimate"`
+	DueDate  *time.Time     `json:"due_date"`
+	Extra    map[string]any `json:"extra"`
+}
+
+func TestMiddlewareRequests(t *testing.T) {
+	msgpackBody, err := msgpack.Marshal(map[string]any{"title": "pay rent", "due_date": "2025-05-01", "estimate": 60})
+	assert.NoError(t, err)
+	testCases := []struct {
+		name                string
+		contentType         string
+		body                string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "csv rows",
+			contentType:         "text/csv; charset=utf-8",
+			body:                "\ufefftitle, done ,tags,estimate,due_date,extra,id\npay rent,true,\"home, money\",60,2025-05-01,\"{\"\"a\"\":1}\",\n,no,,soon,,x,\n",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody: `{"title":"pay rent","done":true,"tags":["home","money"],"estimate":60,"due_date":"2025-05-01","extra":{"a":1}}` + "\n" +
+				`{"done":"no","estimate":"soon","extra":"x"}` + "\n",
+		},
+		{
+			name:           "ragged csv",
+			contentType:    "text/csv",
+			body:           "title,done\npay rent\n",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:                "yaml",
+			contentType:         "application/yaml",
+			body:                "title: 'pay rent'\ndue_date: 2025-05-01\nestimate: 0x3c\ntags: [home]\ndone: true\nextra: ~\n",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"title":"pay rent","due_date":"2025-05-01","estimate":60,"tags":["home"],"done":true,"extra":null}`,
+		},
+		{
+			name:                "yaml aliases",
+			contentType:         "application/x-yaml",
+			body:                "- &rent {title: pay rent}\n- *rent\n",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `[{"title":"pay rent"},{"title":"pay rent"}]`,
+		},
+		{
+			name:           "invalid yaml",
+			contentType:    "application/yaml",
+			body:           "title: [pay rent\n",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:                "msgpack",
+			contentType:         "application/msgpack",
+			body:                string(msgpackBody),
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"due_date":"2025-05-01","estimate":60,"title":"pay rent"}`,
+		},
+		{
+			name:           "msgpack with trailing data",
+			contentType:    "application/msgpack",
+			body:           string(msgpackBody) + string(msgpackBody),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:                "json untouched",
+			contentType:         "application/merge-patch+json",
+			body:                `{"title": "pay rent"}`,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/merge-patch+json",
+			expectedBody:        `{"title": "pay rent"}`,
+		},
+	}
+
+	handler := Middleware(example{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Header.Get("Content-Type") + "\n" + string(body)))
+	}))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(tc.body))
+			request.Header.Set("Content-Type", tc.contentType)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.expectedContentType+"\n"+tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestCSVFormulaCells(t *testing.T) {
+	cells := []string{"=HYPERLINK(\"http://evil\")", "+1", "-x", "@SUM(A1)", "\tcmd", "\rcmd", "'=x", "'quoted", "plain"}
+	for _, cell := range cells {
+		escaped := csvCell(cell)
+		assert.NotContains(t, formulaPrefixes, escaped[:1], "unescaped cell %q", escaped)
+		assert.Equal(t, cell, csvValue(cellString, escaped))
+	}
+	assert.Equal(t, "plain", csvCell("plain"))
+	assert.Equal(t, "'quoted", csvCell("'quoted"))
+	assert.Equal(t, "'-home,work", csvCell([]any{"-home", "work"}))
+	assert.Equal(t, []any{"-home", "work"}, csvValue(cellList, "'-home,work"))
+	assert.Equal(t, "-5", csvCell(json.Number("-5")))
+
+	handler := Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"title":"=1+1","estimate":-5}]`))
+	}))
+	request := httptest.NewRequest(http.MethodGet, "/todo", nil)
+	request.Header.Set("Accept", "text/csv")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, "title,estimate\n'=1+1,-5\n", recorder.Body.String())
+}
@@ -0,0 +1,514 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// object is a JSON object that keeps its members in order, so that YAML
+// mappings and CSV columns follow the fields of the encoded struct.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+// parseJSON decodes JSON into objects, []any, string, json.Number, bool and
+// nil.
+func parseJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return parseValue(decoder)
+}
+
+func parseValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		members := object{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := parseValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, member{key: key.(string), value: value})
+		}
+		_, err := decoder.Token()
+		return members, err
+	case json.Delim('['):
+		items := []any{}
+		for decoder.More() {
+			item, err := parseValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := decoder.Token()
+		return items, err
+	}
+	return token, nil
+}
+
+// writeJSON encodes a value from parseJSON.
+func writeJSON(buf *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case object:
+		buf.WriteByte('{')
+		for i, m := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, m.key)
+			buf.WriteByte(':')
+			writeJSON(buf, m.value)
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, item)
+		}
+		buf.WriteByte(']')
+	default:
+		// Strings, numbers, booleans and null always encode.
+		encoded, _ := json.Marshal(v)
+		buf.Write(encoded)
+	}
+}
+
+// encodeCSV writes an array of objects, or a single object, as a header row
+// and one row per object. The columns are the keys in order of appearance.
+func encodeCSV(value any) ([]byte, error) {
+	var rows []any
+	switch v := value.(type) {
+	case nil:
+	case []any:
+		rows = v
+	default:
+		rows = []any{v}
+	}
+	var columns []string
+	indexes := make(map[string]int)
+	addColumn := func(name string) {
+		if _, ok := indexes[name]; !ok {
+			indexes[name] = len(columns)
+			columns = append(columns, name)
+		}
+	}
+	for _, row := range rows {
+		if members, ok := row.(object); ok {
+			for _, m := range members {
+				addColumn(m.key)
+			}
+		} else {
+			addColumn("value")
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if len(columns) > 0 {
+		writer.Write(columns)
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		if members, ok := row.(object); ok {
+			for _, m := range members {
+				record[indexes[m.key]] = csvCell(m.value)
+			}
+		} else {
+			record[indexes["value"]] = csvCell(row)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// csvCell formats a value for a cell: null is empty, lists of strings and
+// numbers are comma separated and other lists and objects are JSON. Strings
+// and lists are escaped with escapeFormula.
+func csvCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeFormula(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		cells := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				cells = append(cells, item)
+			case json.Number:
+				cells = append(cells, item.String())
+			}
+		}
+		if len(cells) == len(v) {
+			return escapeFormula(strings.Join(cells, ","))
+		}
+	}
+	var buf bytes.Buffer
+	writeJSON(&buf, value)
+	return buf.String()
+}
+
+// formulaPrefixes start cells that spreadsheets evaluate as formulas.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes a cell that a spreadsheet would run as a formula with
+// a quote, which makes it text. Cells that only look escaped get another
+// quote, so that unescapeFormula restores every cell exactly.
+func escapeFormula(cell string) string {
+	if isFormula(strings.TrimLeft(cell, "'")) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// unescapeFormula removes the quote escapeFormula adds.
+func unescapeFormula(cell string) string {
+	if strings.HasPrefix(cell, "'") && isFormula(strings.TrimLeft(cell, "'")) {
+		return cell[1:]
+	}
+	return cell
+}
+
+func isFormula(cell string) bool {
+	return cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0]))
+}
+
+// cellKind is the JSON type a CSV cell converts to.
+type cellKind int
+
+const (
+	cellString cellKind = iota
+	cellNumber
+	cellBool
+	cellList
+	cellJSON
+)
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// cellKinds maps the JSON fields of the struct schema to the kinds of their
+// cells. Structs that decode themselves, such as dates, are written as
+// strings.
+func cellKinds(schema any) map[string]cellKind {
+	kinds := make(map[string]cellKind)
+	t := reflect.TypeOf(schema)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return kinds
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		kinds[name] = kindOf(field.Type)
+	}
+	return kinds
+}
+
+func kindOf(t reflect.Type) cellKind {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return cellString
+	case reflect.Bool:
+		return cellBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return cellNumber
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return cellList
+		}
+	case reflect.Struct:
+		if reflect.PointerTo(t).Implements(unmarshalerType) {
+			return cellString
+		}
+	}
+	return cellJSON
+}
+
+// csvToJSON converts CSV rows to NDJSON, one object per row. Empty cells are
+// left out, and cells that don't convert to the kind of their column are
+// passed on as strings for the handler to report.
+func csvToJSON(body []byte, columns map[string]cellKind) ([]byte, string, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	header, err := reader.Read()
+	if err != nil {
+		return nil, "", err
+	}
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+	}
+	// Spreadsheets often start their exports with a byte order mark.
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	var buf bytes.Buffer
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		row := object{}
+		for i, cell := range record {
+			if cell != "" {
+				row = append(row, member{key: header[i], value: csvValue(columns[header[i]], cell)})
+			}
+		}
+		writeJSON(&buf, row)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), "application/x-ndjson", nil
+}
+
+func csvValue(kind cellKind, cell string) any {
+	cell = unescapeFormula(cell)
+	switch kind {
+	case cellNumber:
+		if _, err := strconv.ParseFloat(cell, 64); err == nil && json.Valid([]byte(cell)) {
+			return json.Number(cell)
+		}
+	case cellBool:
+		if b, err := strconv.ParseBool(cell); err == nil {
+			return b
+		}
+	case cellList:
+		items := []any{}
+		for _, item := range strings.Split(cell, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	case cellJSON:
+		if json.Valid([]byte(cell)) {
+			if value, err := parseJSON([]byte(cell)); err == nil {
+				return value
+			}
+		}
+	}
+	return cell
+}
+
+func encodeYAML(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(yamlNode(value)); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func yamlNode(value any) *yaml.Node {
+	switch v := value.(type) {
+	case object:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, m := range v {
+			node.Content = append(node.Content, yamlNode(m.key), yamlNode(m.value))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
+
+// maxYAMLDepth bounds the nesting of YAML documents, which aliases could
+// otherwise make endless.
+const maxYAMLDepth = 64
+
+// yamlToJSON converts a YAML document to JSON. Timestamps are kept as
+// written, so dates read the same as in JSON.
+func yamlToJSON(body []byte, _ map[string]cellKind) ([]byte, string, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(body, &document); err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if err := writeYAMLAsJSON(&buf, &document, 0); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), JSON.MediaType, nil
+}
+
+func writeYAMLAsJSON(buf *bytes.Buffer, node *yaml.Node, depth int) error {
+	if depth > maxYAMLDepth {
+		return errors.New("document is nested too deeply")
+	}
+	if buf.Len() > MaxBodySize {
+		return errors.New("document is too large")
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return writeYAMLAsJSON(buf, node.Content[0], depth)
+	case yaml.AliasNode:
+		return writeYAMLAsJSON(buf, node.Alias, depth+1)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, key.Value)
+			buf.WriteByte(':')
+			if err := writeYAMLAsJSON(buf, node.Content[i+1], depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeYAMLAsJSON(buf, item, depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		var value any = node.Value
+		switch node.ShortTag() {
+		case "!!null", "!!bool", "!!int", "!!float":
+			if err := node.Decode(&value); err != nil {
+				return err
+			}
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		buf.Write(encoded)
+	}
+	return nil
+}
+
+func encodeMessagePack(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeMessagePack(msgpack.NewEncoder(&buf), value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeMessagePack(encoder *msgpack.Encoder, value any) error {
+	switch v := value.(type) {
+	case object:
+		if err := encoder.EncodeMapLen(len(v)); err != nil {
+			return err
+		}
+		for _, m := range v {
+			if err := encoder.EncodeString(m.key); err != nil {
+				return err
+			}
+			if err := writeMessagePack(encoder, m.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if err := encoder.EncodeArrayLen(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := writeMessagePack(encoder, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return encoder.EncodeInt(i)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return encoder.EncodeFloat64(f)
+	case string:
+		return encoder.EncodeString(v)
+	case bool:
+		return encoder.EncodeBool(v)
+	}
+	return encoder.EncodeNil()
+}
+
+// messagePackToJSON converts a MessagePack value to JSON. Dates should be
+// sent as strings formatted as in JSON; timestamps become RFC 3339 datetimes.
+func messagePackToJSON(body []byte, _ map[string]cellKind) ([]byte, string, error) {
+	decoder := msgpack.NewDecoder(bytes.NewReader(body))
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, "", err
+	}
+	if _, err := decoder.PeekCode(); !errors.Is(err, io.EOF) {
+		return nil, "", errors.New("body must contain a single value")
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, "", err
+	}
+	return encoded, JSON.MediaType, nil
+}
@@ -0,0 +1,251 @@
+package attachmenthandlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/blobstore"
+	"github.com/GlebMoskalev/todo-api/internal/models/attachment"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	fileFormField = "file"
+	sniffLength   = 512
+	// multipartOverhead leaves room for part headers and boundaries on top
+	// of the file size limit.
+	multipartOverhead = 1 << 20
+)
+
+func UploadAttachment(repo *repository.AttachmentPostgresRepository, store blobstore.BlobStore, maxSize int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize+multipartOverhead)
+		multipartReader, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var part io.ReadCloser
+		var fileName string
+		for {
+			p, err := multipartReader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writeReadError(w, err)
+				return
+			}
+			if p.FormName() == fileFormField {
+				part = p
+				fileName = filepath.Base(p.FileName())
+				break
+			}
+			p.Close()
+		}
+		if part == nil || fileName == "." || fileName == string(filepath.Separator) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("missing \"" + fileFormField + "\" file field"))
+			return
+		}
+		defer part.Close()
+
+		head := make([]byte, sniffLength)
+		n, err := io.ReadFull(part, head)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			writeReadError(w, err)
+			return
+		}
+		head = head[:n]
+
+		key, err := blobstore.NewKey()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		hash := sha256.New()
+		content := &io.LimitedReader{
+			R: io.TeeReader(io.MultiReader(bytes.NewReader(head), part), hash),
+			N: maxSize + 1,
+		}
+		size, err := store.Put(key, content)
+		if err != nil {
+			writeReadError(w, err)
+			return
+		}
+		if size > maxSize {
+			store.Delete(key)
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte("attachment exceeds the maximum size of " + strconv.FormatInt(maxSize, 10) + " bytes"))
+			return
+		}
+
+		newAttachment := &attachment.Attachment{
+			TodoID:      todoId,
+			FileName:    fileName,
+			ContentType: detectContentType(head, fileName),
+			Size:        size,
+			Checksum:    hex.EncodeToString(hash.Sum(nil)),
+			StorageKey:  key,
+		}
+		if _, err := repo.Create(newAttachment); err != nil {
+			store.Delete(key)
+			writeRepositoryError(w, err)
+			return
+		}
+
+		jsonAttachment, err := json.Marshal(newAttachment)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(jsonAttachment)
+	}
+}
+
+func GetAttachments(repo *repository.AttachmentPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		attachments, err := repo.GetByTodoId(todoId)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		jsonAttachments, err := json.Marshal(attachments)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write(jsonAttachments)
+	}
+}
+
+// DownloadAttachment streams the stored file. http.ServeContent takes care
+// of Range and conditional requests.
+func DownloadAttachment(repo *repository.AttachmentPostgresRepository, store blobstore.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		todoId, attachmentId, err := parseAttachmentPath(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		a, err := repo.GetById(todoId, attachmentId)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		blob, err := store.Open(a.StorageKey)
+		if err != nil {
+			if errors.Is(err, blobstore.ErrBlobNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+		defer blob.Close()
+
+		w.Header().Set("Content-Type", a.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.FileName}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("ETag", "\""+a.Checksum+"\"")
+		http.ServeContent(w, r, a.FileName, a.CreatedAt, blob)
+	}
+}
+
+func DeleteAttachment(repo *repository.AttachmentPostgresRepository, store blobstore.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		todoId, attachmentId, err := parseAttachmentPath(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		a, lastReference, err := repo.Delete(todoId, attachmentId)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		// Duplicated todos share blobs; the last reference removes it.
+		if !lastReference {
+			w.Write([]byte("ok"))
+			return
+		}
+		if err := store.Delete(a.StorageKey); err != nil && !errors.Is(err, blobstore.ErrBlobNotFound) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+// detectContentType sniffs the first bytes of the file and falls back to the
+// file extension when sniffing cannot tell anything more specific.
+func detectContentType(head []byte, fileName string) string {
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" || contentType == "application/zip" {
+		if byExtension := mime.TypeByExtension(filepath.Ext(fileName)); byExtension != "" {
+			return byExtension
+		}
+	}
+	return contentType
+}
+
+func parseAttachmentPath(r *http.Request) (int, int, error) {
+	todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return 0, 0, err
+	}
+	attachmentId, err := strconv.Atoi(chi.URLParam(r, "attachmentId"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return todoId, attachmentId, nil
+}
+
+func writeReadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	} else {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Write([]byte(err.Error()))
+}
@@ -0,0 +1,168 @@
+package commenthandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/identity"
+	"github.com/GlebMoskalev/todo-api/internal/models/comment"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+type commentRequest struct {
+	Body string `json:"body"`
+}
+
+func GetComments(repo *repository.CommentPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		comments, err := repo.GetByTodoId(todoId)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		for _, c := range comments {
+			if err := c.RenderBody(); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+		jsonComments, err := json.Marshal(comments)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write(jsonComments)
+	}
+}
+
+func CreateComment(repo *repository.CommentPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := identity.UserID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("missing " + identity.UserIDHeader + " header"))
+			return
+		}
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var request commentRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		newComment := &comment.Comment{TodoID: todoId, AuthorID: userId, Body: request.Body}
+		if _, err := repo.Create(newComment); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeComment(w, http.StatusCreated, newComment)
+	}
+}
+
+func UpdateComment(repo *repository.CommentPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := identity.UserID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("missing " + identity.UserIDHeader + " header"))
+			return
+		}
+		todoId, commentId, err := parseCommentPath(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var request commentRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		commentToUpdate := &comment.Comment{ID: commentId, TodoID: todoId, AuthorID: userId, Body: request.Body}
+		if err := repo.Update(commentToUpdate); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeComment(w, http.StatusOK, commentToUpdate)
+	}
+}
+
+func DeleteComment(repo *repository.CommentPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := identity.UserID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("missing " + identity.UserIDHeader + " header"))
+			return
+		}
+		todoId, commentId, err := parseCommentPath(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.Delete(todoId, commentId, userId); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+func parseCommentPath(r *http.Request) (int, int, error) {
+	todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return 0, 0, err
+	}
+	commentId, err := strconv.Atoi(chi.URLParam(r, "commentId"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return todoId, commentId, nil
+}
+
+func writeComment(w http.ResponseWriter, statusCode int, c *comment.Comment) {
+	if err := c.RenderBody(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	jsonComment, err := json.Marshal(c)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(jsonComment)
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, repository.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
@@ -0,0 +1,103 @@
+package customfieldhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/customfield"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+func CreateCustomField(repo *repository.CustomFieldPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var definition customfield.Definition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if _, err := repo.Create(&definition); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, definition)
+	}
+}
+
+func GetAllCustomFields(repo *repository.CustomFieldPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		definitions, err := repo.GetAll()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, definitions)
+	}
+}
+
+// UpdateCustomField changes name, options and required flag; key and type in
+// the body are ignored.
+func UpdateCustomField(repo *repository.CustomFieldPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var definition customfield.Definition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		definition.ID = id
+		if err := repo.Update(&definition); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, definition)
+	}
+}
+
+func DeleteCustomField(repo *repository.CustomFieldPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.Delete(id); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	jsonBody, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(jsonBody)
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, repository.ErrCustomFieldExists):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
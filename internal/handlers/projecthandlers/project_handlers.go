@@ -0,0 +1,195 @@
+package projecthandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/project"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+func CreateProject(repo *repository.ProjectPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var newProject project.Project
+		if err := json.NewDecoder(r.Body).Decode(&newProject); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if _, err := repo.Create(&newProject); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusCreated, newProject)
+	}
+}
+
+func GetAllProjects(repo *repository.ProjectPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		includeArchived := false
+		if rawArchived := r.URL.Query().Get("include_archived"); rawArchived != "" {
+			var err error
+			includeArchived, err = strconv.ParseBool(rawArchived)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+		projects, err := repo.GetAll(includeArchived)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, projects)
+	}
+}
+
+func GetByIdProject(repo *repository.ProjectPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		p, err := repo.GetById(id)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+func UpdateProject(repo *repository.ProjectPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var projectForUpdate project.Project
+		if err := json.NewDecoder(r.Body).Decode(&projectForUpdate); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		projectForUpdate.ID = id
+		if err := repo.Update(&projectForUpdate); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+func DeleteProject(repo *repository.ProjectPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.Delete(id); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+func ArchiveProject(repo *repository.ProjectPostgresRepository, archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.SetArchived(id, archived); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+// MoveTodos moves the todos listed in the body to the project, or out of
+// their projects when the project id is "none".
+func MoveTodos(repo *repository.ProjectPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type moveRequest struct {
+			TodoIds []int `json:"ids"`
+		}
+
+		var projectId *int
+		if rawId := chi.URLParam(r, "id"); rawId != "none" {
+			id, err := strconv.Atoi(rawId)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			projectId = &id
+		}
+		var request moveRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		moved, err := repo.MoveTodos(projectId, request.TodoIds)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"moved": moved})
+	}
+}
+
+func GetStatusCounts(repo *repository.ProjectPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		p, err := repo.GetById(id)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p.StatusCounts)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	jsonBody, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(jsonBody)
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, repository.ErrProjectArchived):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
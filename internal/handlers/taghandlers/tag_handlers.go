@@ -0,0 +1,132 @@
+package taghandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/tag"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func GetAllTags(repo *repository.TagPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		limit := tag.DefaultLimit
+		if rawLimit := query.Get("limit"); rawLimit != "" {
+			limitInt, err := strconv.Atoi(rawLimit)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			limit = limitInt
+		}
+
+		tags, err := repo.GetAll(query.Get("prefix"), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, tags)
+	}
+}
+
+func SetTagMetadata(repo *repository.TagPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, err := url.PathUnescape(chi.URLParam(r, "name"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var t tag.Tag
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		t.Name = name
+		if err := repo.SetMetadata(&t); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+func RenameTag(repo *repository.TagPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type renameRequest struct {
+			Name string `json:"name"`
+		}
+
+		oldName, err := url.PathUnescape(chi.URLParam(r, "name"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var request renameRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		updated, err := repo.Rename(oldName, request.Name)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"updated": updated})
+	}
+}
+
+func MergeTags(repo *repository.TagPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type mergeRequest struct {
+			Sources []string `json:"sources"`
+			Target  string   `json:"target"`
+		}
+
+		var request mergeRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		updated, err := repo.Merge(request.Sources, request.Target)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"updated": updated})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	jsonBody, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(jsonBody)
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, repository.ErrTagExists):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
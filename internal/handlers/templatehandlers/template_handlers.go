@@ -0,0 +1,181 @@
+package templatehandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/identity"
+	"github.com/GlebMoskalev/todo-api/internal/models/template"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func CreateTemplate(repo *repository.TemplatePostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var newTemplate template.Template
+		if err := json.NewDecoder(r.Body).Decode(&newTemplate); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if _, err := repo.Create(&newTemplate); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, newTemplate)
+	}
+}
+
+func GetAllTemplates(repo *repository.TemplatePostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templates, err := repo.GetAll()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, templates)
+	}
+}
+
+func GetByIdTemplate(repo *repository.TemplatePostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		t, err := repo.GetById(id)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	}
+}
+
+func UpdateTemplate(repo *repository.TemplatePostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var templateForUpdate template.Template
+		if err := json.NewDecoder(r.Body).Decode(&templateForUpdate); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		templateForUpdate.ID = id
+		if err := repo.Update(&templateForUpdate); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, templateForUpdate)
+	}
+}
+
+func DeleteTemplate(repo *repository.TemplatePostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.Delete(id); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+// InstantiateTemplate creates the template's todo and all its items in one
+// transaction. The items are todos whose parent_id is the template's todo. Day
+// offsets are counted in time_zone, UTC by default.
+func InstantiateTemplate(templateRepo *repository.TemplatePostgresRepository,
+	todoRepo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type instantiateRequest struct {
+			Variables map[string]string `json:"variables"`
+			ProjectID *int              `json:"project_id"`
+			TimeZone  string            `json:"time_zone"`
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var request instantiateRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		location := time.UTC
+		if request.TimeZone != "" {
+			location, err = time.LoadLocation(request.TimeZone)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("invalid time_zone"))
+				return
+			}
+		}
+
+		t, err := templateRepo.GetById(id)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		todos, err := t.Instantiate(request.Variables, time.Now().In(location))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var createdBy *int
+		if userId, ok := identity.UserID(r.Context()); ok {
+			createdBy = &userId
+		}
+		for _, newTodo := range todos {
+			newTodo.ProjectID = request.ProjectID
+			newTodo.TimeZone = request.TimeZone
+			newTodo.CreatedBy = createdBy
+		}
+		ids, err := todoRepo.CreateWithItems(todos[0], todos[1:])
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string][]int{"ids": ids})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	jsonBody, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(jsonBody)
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
@@ -0,0 +1,217 @@
+package timehandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/identity"
+	"github.com/GlebMoskalev/todo-api/internal/models/timeentry"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func StartTimer(repo *repository.TimeEntryPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := identity.UserID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("missing " + identity.UserIDHeader + " header"))
+			return
+		}
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		entry, err := repo.StartTimer(todoId, userId)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, entry)
+	}
+}
+
+func StopTimer(repo *repository.TimeEntryPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := identity.UserID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("missing " + identity.UserIDHeader + " header"))
+			return
+		}
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		entry, err := repo.StopTimer(todoId, userId)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entry)
+	}
+}
+
+func GetTimeEntries(repo *repository.TimeEntryPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		entries, err := repo.GetByTodoId(todoId)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// CreateTimeEntry logs time manually. The end of the span is given either as
+// ended_at or as duration_seconds counted from started_at.
+func CreateTimeEntry(repo *repository.TimeEntryPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type createRequest struct {
+			StartedAt       time.Time  `json:"started_at"`
+			EndedAt         *time.Time `json:"ended_at"`
+			DurationSeconds *int64     `json:"duration_seconds"`
+			Note            string     `json:"note"`
+		}
+
+		userId, ok := identity.UserID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("missing " + identity.UserIDHeader + " header"))
+			return
+		}
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var request createRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		entry := &timeentry.TimeEntry{
+			TodoID:    todoId,
+			UserID:    userId,
+			StartedAt: request.StartedAt,
+			EndedAt:   request.EndedAt,
+			Note:      request.Note,
+		}
+		if entry.EndedAt == nil && request.DurationSeconds != nil {
+			endedAt := request.StartedAt.Add(time.Duration(*request.DurationSeconds) * time.Second)
+			entry.EndedAt = &endedAt
+		}
+		if _, err := repo.Create(entry); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, entry)
+	}
+}
+
+func DeleteTimeEntry(repo *repository.TimeEntryPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := identity.UserID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("missing " + identity.UserIDHeader + " header"))
+			return
+		}
+		todoId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		entryId, err := strconv.Atoi(chi.URLParam(r, "entryId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.Delete(todoId, entryId, userId); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+// GetTimeReport aggregates logged time. from and to are dates (YYYY-MM-DD);
+// to is inclusive.
+func GetTimeReport(repo *repository.TimeEntryPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		groupBy := timeentry.GroupBy(query.Get("group_by"))
+		if groupBy == "" {
+			groupBy = timeentry.GroupByDate
+		}
+		if !timeentry.IsValidGroupBy(groupBy) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid group_by"))
+			return
+		}
+
+		from, err := time.Parse(time.DateOnly, query.Get("from"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid from, expected YYYY-MM-DD"))
+			return
+		}
+		to, err := time.Parse(time.DateOnly, query.Get("to"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid to, expected YYYY-MM-DD"))
+			return
+		}
+
+		report, err := repo.Report(groupBy, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	jsonBody, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(jsonBody)
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, repository.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
+	case errors.Is(err, repository.ErrTimerRunning):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
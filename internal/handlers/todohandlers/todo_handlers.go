@@ -1,68 +1,136 @@
 package todohandlers
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/apiversion"
+	"github.com/GlebMoskalev/todo-api/internal/blobstore"
+	"github.com/GlebMoskalev/todo-api/internal/httpcache"
+	"github.com/GlebMoskalev/todo-api/internal/identity"
+	"github.com/GlebMoskalev/todo-api/internal/jsonpatch"
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
 	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
 	"github.com/GlebMoskalev/todo-api/internal/models/priority"
 	"github.com/GlebMoskalev/todo-api/internal/models/status"
 	"github.com/GlebMoskalev/todo-api/internal/models/todo"
 	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/GlebMoskalev/todo-api/internal/validation"
 	"github.com/go-chi/chi/v5"
+	"io"
+	"maps"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func CreateTodo(repo *repository.TodoPostgresRepository) http.HandlerFunc {
+// CreateTodo answers 201 Created with the stored todo and its URL in the
+// Location header.
+func CreateTodo(repo repository.TodoRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var newTodo todo.Todo
-		err := json.NewDecoder(r.Body).Decode(&newTodo)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(err.Error()))
+		if err := decodeJSON(w, r, &newTodo); err != nil {
+			writeRequestError(w, err)
 			return
 		}
+		newTodo.CreatedBy, newTodo.ParentID = nil, nil
+		if userId, ok := identity.UserID(r.Context()); ok {
+			newTodo.CreatedBy = &userId
+		}
 		id, err := repo.Create(&newTodo)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			writeTodoError(w, err)
+			return
+		}
+		createdTodo, err := repo.GetById(id)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		jsonId, err := json.Marshal(map[string]int{"id": id})
+		jsonTodo, err := json.Marshal(createdTodo)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.Write(jsonId)
+		w.Header().Set("Location", path.Join(r.URL.Path, strconv.Itoa(id)))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(jsonTodo)
 	}
 }
 
-func DeleteTodos(repo *repository.TodoPostgresRepository) http.HandlerFunc {
+// DeleteTodos deletes the todos listed in the body.
+//
+// Deprecated: use DeleteTodo on /todo/{id}.
+func DeleteTodos(repo repository.TodoRepository, store blobstore.BlobStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		type deleteRequest struct {
 			TodoIds []int `json:"ids"`
 		}
 
 		var todoIds deleteRequest
-		err := json.NewDecoder(r.Body).Decode(&todoIds)
+		err := decodeJSON(w, r, &todoIds)
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		storageKeys, err := repo.Delete(todoIds.TodoIds)
+		if err == nil {
+			err = deleteBlobs(store, storageKeys)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+func DeleteTodo(repo repository.TodoRepository, store blobstore.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		err = repo.Delete(todoIds.TodoIds)
+		storageKeys, err := repo.Delete([]int{id})
 		if err != nil {
+			writeNotFoundError(w, err)
+			return
+		}
+		if err := deleteBlobs(store, storageKeys); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.Write([]byte("ok"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// deleteBlobs removes the blobs deleted todos no longer share with others,
+// trying every key before reporting failures.
+func deleteBlobs(store blobstore.BlobStore, storageKeys []string) error {
+	var errs []error
+	for _, storageKey := range storageKeys {
+		if err := store.Delete(storageKey); err != nil && !errors.Is(err, blobstore.ErrBlobNotFound) {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
 }
 
-func GetByIdTodo(repo *repository.TodoPostgresRepository) http.HandlerFunc {
+func GetByIdTodo(repo repository.TodoRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		todoId := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(todoId)
@@ -73,8 +141,7 @@ func GetByIdTodo(repo *repository.TodoPostgresRepository) http.HandlerFunc {
 		}
 		todoResponse, err := repo.GetById(id)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
+			writeNotFoundError(w, err)
 			return
 		}
 
@@ -84,94 +151,162 @@ func GetByIdTodo(repo *repository.TodoPostgresRepository) http.HandlerFunc {
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.Write(jsonTodo)
+		httpcache.Write(w, r, jsonTodo, httpcache.StrongETag(jsonTodo), todoResponse.UpdatedAt)
+	}
+}
+
+// UpdateTodo replaces the todo whose id is in the body.
+//
+// Deprecated: use UpdateTodoById on /todo/{id}.
+func UpdateTodo(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var todoForUpdate todo.Todo
+		err := decodeJSON(w, r, &todoForUpdate)
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		if err := repo.Update(&todoForUpdate); err != nil {
+			writeTodoError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
 	}
 }
 
-func UpdateTodo(repo *repository.TodoPostgresRepository) http.HandlerFunc {
+// UpdateTodoById replaces the todo named by the URL and returns it. An id in
+// the body must match the URL.
+func UpdateTodoById(repo repository.TodoRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var todoForUpdate *todo.Todo
-		err := json.NewDecoder(r.Body).Decode(&todoForUpdate)
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		err = repo.Update(todoForUpdate)
+		var todoForUpdate todo.Todo
+		if err := decodeJSON(w, r, &todoForUpdate); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		if todoForUpdate.ID != 0 && todoForUpdate.ID != id {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("id in the body does not match the URL"))
+			return
+		}
+		todoForUpdate.ID = id
+		if err := repo.Update(&todoForUpdate); err != nil {
+			writeTodoError(w, err)
+			return
+		}
+		updatedTodo, err := repo.GetById(id)
+		if err != nil {
+			writeNotFoundError(w, err)
+			return
+		}
+		jsonTodo, err := json.Marshal(updatedTodo)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.Write([]byte("ok"))
+		w.Write(jsonTodo)
 	}
 }
 
-func GetAllTodos(repo *repository.TodoPostgresRepository) http.HandlerFunc {
+// PatchTodo partially updates a todo. The body is a JSON Merge Patch, or a
+// JSON Patch when sent as application/json-patch+json. The updated todo is
+// returned.
+func PatchTodo(repo repository.TodoRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var tags []string
-		var statusFilter status.Status
-		var priorityFilter priority.Priority
-		var overdue *bool
-		var dueDate todo.NullTime
-		var paginationParams pagination.Pagination
-
-		query := r.URL.Query()
-
-		if rawTags, ok := query["tags"]; ok && len(rawTags) > 0 {
-			for _, tag := range rawTags {
-				splitTags := strings.Split(tag, ",")
-				for _, t := range splitTags {
-					trimmed := strings.TrimSpace(t)
-					if trimmed != "" {
-						tags = append(tags, trimmed)
-					}
-				}
-			}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+		if err != nil {
+			writeRequestError(w, err)
+			return
 		}
 
-		overdueStr := query.Get("overdue")
-		if overdueStr != "" {
-			overdueBool, err := strconv.ParseBool(overdueStr)
+		var patch func(document []byte) ([]byte, error)
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case jsonpatch.PatchContentType:
+			operations, err := jsonpatch.DecodePatch(body)
 			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
 				w.Write([]byte(err.Error()))
 				return
-			} else {
-				overdue = todo.BoolPtr(overdueBool)
 			}
+			patch = operations.Apply
+		case jsonpatch.MergePatchContentType, "application/json", "":
+			patch = func(document []byte) ([]byte, error) {
+				return jsonpatch.MergePatch(document, body)
+			}
+		default:
+			w.Header().Set("Accept-Patch", jsonpatch.MergePatchContentType+", "+jsonpatch.PatchContentType)
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			w.Write([]byte("unsupported patch format: " + mediaType))
+			return
 		}
 
-		priorityString := query.Get("priority")
-		if priorityString != "" && !priority.IsValidPriority(priority.Priority(priorityString)) {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("invalid priority"))
+		patched, err := repo.Patch(id, patch)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, jsonpatch.ErrTestFailed):
+				w.WriteHeader(http.StatusConflict)
+			default:
+				writeRequestError(w, err)
+				return
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+		jsonTodo, err := json.Marshal(patched)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
 			return
-		} else {
-			priorityFilter = priority.Priority(priorityString)
 		}
+		w.Write(jsonTodo)
+	}
+}
 
-		statusString := query.Get("status")
-		if statusString != "" && !status.IsValidStatus(status.Status(statusString)) {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("invalid status"))
+func GetAllTodos(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var paginationParams pagination.Pagination
+
+		todoFilter, err := parseFilter(r)
+		if err != nil {
+			writeFilterError(w, err)
 			return
-		} else {
-			statusFilter = status.Status(statusString)
 		}
+		query := r.URL.Query()
 
-		dueDateString := query.Get("dueDate")
-		if dueDateString != "" {
-			date, err := time.Parse(time.DateOnly, dueDateString)
+		if rawSort := query.Get("sort"); rawSort != "" {
+			todoFilter.Sort = filter.ParseSort(rawSort)
+		}
+
+		if query.Has("fields") {
+			todoFilter.Fields, err = todo.ParseFieldset(query.Get("fields"))
 			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("invalid time"))
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+		if rawInclude := query.Get("include"); rawInclude != "" {
+			todoFilter.Include, err = todo.ParseIncludes(rawInclude)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
 				return
-			} else {
-				dueDate = todo.NullTime{Time: date, Valid: true}
 			}
-		} else {
-			dueDate = todo.NullTime{Valid: false}
 		}
 
 		if rawLimit := query.Get("limit"); rawLimit != "" {
@@ -198,18 +333,893 @@ func GetAllTodos(repo *repository.TodoPostgresRepository) http.HandlerFunc {
 			paginationParams.Offset = pagination.DefaultOffset
 		}
 
-		todos, err := repo.GetAll(tags, statusFilter, priorityFilter, overdue, dueDate, paginationParams)
+		todos, err := repo.GetAll(todoFilter, paginationParams)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		jsonTodos, err := json.Marshal(todos)
+		jsonTodos, err := encodeTodos(todos, todoFilter, apiversion.FromContext(r.Context()))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		// The list has no Last-Modified: deleting a todo or one leaving the
+		// filter changes the page without making anything on it newer.
+		w.Header().Add("Vary", identity.UserIDHeader)
+		httpcache.Write(w, r, jsonTodos, todosETag(r, todos, todoFilter, jsonTodos), time.Time{})
+	}
+}
+
+// encodeTodos encodes a page of todos with the fields and embedded data
+// todoFilter asks for. Before v2 an empty page is null.
+func encodeTodos(todos todo.Todos, todoFilter filter.Filter, version apiversion.Version) ([]byte, error) {
+	for _, t := range todos {
+		for _, c := range t.Comments {
+			if err := c.RenderBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if todos == nil && version >= apiversion.V2 {
+		todos = todo.Todos{}
+	}
+	if todoFilter.Fields == nil {
+		return json.Marshal(todos)
+	}
+	var selected []json.RawMessage
+	if todos != nil {
+		selected = make([]json.RawMessage, 0, len(todos))
+	}
+	for _, t := range todos {
+		jsonTodo, err := t.Select(todoFilter.Fields, todoFilter.Include)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, jsonTodo)
+	}
+	return json.Marshal(selected)
+}
+
+// todosETag identifies a page of todos by the query and caller that selected
+// it and the version of every todo on it. Embedded projects and users have no
+// version, so with include the body itself counts.
+func todosETag(r *http.Request, todos todo.Todos, todoFilter filter.Filter, body []byte) string {
+	parts := make([]string, 0, len(todos)+3)
+	parts = append(parts, r.URL.Query().Encode(), r.Header.Get(identity.UserIDHeader))
+	for _, t := range todos {
+		parts = append(parts, strconv.Itoa(t.ID)+":"+strconv.FormatInt(t.Version, 10))
+	}
+	if len(todoFilter.Include) > 0 {
+		parts = append(parts, string(body))
+	}
+	return httpcache.WeakETag(parts...)
+}
+
+// filterKeys are the query keys parseFilter reads, besides custom fields.
+var filterKeys = []string{"tags", "overdue", "priority", "status", "dueDate", "project", "assignee", "archived", "available"}
+
+// checkFilterKeys makes sure a query holds only filters and at least one
+// condition narrowing the todos, so that a typo can't widen a bulk update to
+// every todo. archived and available set to "all" aren't conditions.
+func checkFilterKeys(query url.Values) error {
+	conditions := 0
+	for key, values := range query {
+		if !slices.Contains(filterKeys, key) && !strings.HasPrefix(key, filter.CustomFieldPrefix) {
+			return fmt.Errorf("unknown filter %q", key)
+		}
+		if (key == "archived" || key == "available") && slices.Equal(values, []string{"all"}) {
+			continue
+		}
+		if slices.ContainsFunc(values, func(value string) bool { return value != "" }) {
+			conditions++
+		}
+	}
+	if conditions == 0 {
+		return errors.New("ids or filters are required")
+	}
+	return nil
+}
+
+// parseFilter reads the todo filter shared by GetAllTodos and
+// BulkUpdateTodos from the query string.
+func parseFilter(r *http.Request) (filter.Filter, error) {
+	var todoFilter filter.Filter
+	query := r.URL.Query()
+
+	if rawTags, ok := query["tags"]; ok && len(rawTags) > 0 {
+		for _, tag := range rawTags {
+			splitTags := strings.Split(tag, ",")
+			for _, t := range splitTags {
+				trimmed := strings.TrimSpace(t)
+				if trimmed != "" {
+					todoFilter.Tags = append(todoFilter.Tags, trimmed)
+				}
+			}
+		}
+	}
+
+	overdueStr := query.Get("overdue")
+	if overdueStr != "" {
+		overdueBool, err := strconv.ParseBool(overdueStr)
+		if err != nil {
+			return filter.Filter{}, err
+		} else {
+			todoFilter.Overdue = todo.BoolPtr(overdueBool)
+		}
+	}
+
+	priorityString := query.Get("priority")
+	if priorityString != "" && !priority.IsValidPriority(priority.Priority(priorityString)) {
+		return filter.Filter{}, errors.New("invalid priority")
+	} else {
+		todoFilter.Priority = priority.Priority(priorityString)
+	}
+
+	statusString := query.Get("status")
+	if statusString != "" && !status.IsValidStatus(status.Status(statusString)) {
+		return filter.Filter{}, errors.New("invalid status")
+	} else {
+		todoFilter.Status = status.Status(statusString)
+	}
+
+	dueDateString := query.Get("dueDate")
+	if dueDateString != "" {
+		date, err := time.Parse(time.DateOnly, dueDateString)
+		if err != nil {
+			return filter.Filter{}, errors.New("invalid time")
+		} else {
+			todoFilter.DueDate = todo.NullTime{Time: date, Valid: true}
+		}
+	}
+
+	if rawProject := query.Get("project"); rawProject == "none" {
+		todoFilter.WithoutProject = true
+	} else if rawProject != "" {
+		projectId, err := strconv.Atoi(rawProject)
+		if err != nil {
+			return filter.Filter{}, errors.New("invalid project")
+		}
+		todoFilter.ProjectID = &projectId
+	}
+
+	switch rawAssignee := query.Get("assignee"); rawAssignee {
+	case "":
+	case "none":
+		todoFilter.WithoutAssignee = true
+	case "me":
+		userId, ok := identity.UserID(r.Context())
+		if !ok {
+			return filter.Filter{}, errMissingUserID
+		}
+		todoFilter.AssigneeID = &userId
+	default:
+		assigneeId, err := strconv.Atoi(rawAssignee)
+		if err != nil {
+			return filter.Filter{}, errors.New("invalid assignee")
+		}
+		todoFilter.AssigneeID = &assigneeId
+	}
+
+	// Archived todos are hidden unless asked for with archived=true or
+	// archived=all.
+	switch rawArchived := query.Get("archived"); rawArchived {
+	case "":
+		todoFilter.Archived = todo.BoolPtr(false)
+	case "all":
+	default:
+		archived, err := strconv.ParseBool(rawArchived)
+		if err != nil {
+			return filter.Filter{}, errors.New("invalid archived")
+		}
+		todoFilter.Archived = todo.BoolPtr(archived)
+	}
+
+	// Snoozed todos are hidden unless asked for with available=false or
+	// available=all.
+	switch rawAvailable := query.Get("available"); rawAvailable {
+	case "":
+		todoFilter.Available = todo.BoolPtr(true)
+	case "all":
+	default:
+		available, err := strconv.ParseBool(rawAvailable)
+		if err != nil {
+			return filter.Filter{}, errors.New("invalid available")
+		}
+		todoFilter.Available = todo.BoolPtr(available)
+	}
+
+	for key, values := range query {
+		if customKey, ok := strings.CutPrefix(key, filter.CustomFieldPrefix); ok && len(values) > 0 {
+			if todoFilter.CustomFields == nil {
+				todoFilter.CustomFields = make(map[string]string)
+			}
+			todoFilter.CustomFields[customKey] = values[0]
+		}
+	}
+
+	return todoFilter, nil
+}
+
+// SnoozeTodo defers a todo either until a point in time or for a relative
+// duration such as "2h" or "3d".
+func SnoozeTodo(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type snoozeRequest struct {
+			Until    *time.Time `json:"until"`
+			Duration string     `json:"duration"`
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var request snoozeRequest
+		if err := decodeJSON(w, r, &request); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+
+		var until time.Time
+		switch {
+		case request.Until != nil && request.Duration != "":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("until and duration are mutually exclusive"))
+			return
+		case request.Until != nil:
+			until = *request.Until
+		case request.Duration != "":
+			duration, err := todo.ParseSnoozeDuration(request.Duration)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			until = time.Now().Add(duration)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("until or duration is required"))
+			return
+		}
+
+		if err := repo.Snooze(id, &until); err != nil {
+			writeNotFoundError(w, err)
+			return
+		}
+		jsonBody, err := json.Marshal(map[string]any{"id": id, "defer_until": until.UTC()})
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.Write(jsonTodos)
+		w.Write(jsonBody)
+	}
+}
+
+func UnsnoozeTodo(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.Snooze(id, nil); err != nil {
+			writeNotFoundError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+// MoveTodo reorders a todo manually. The body names the todo it should follow
+// (after), the todo it should precede (before), or both.
+func MoveTodo(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type moveRequest struct {
+			After  *int `json:"after"`
+			Before *int `json:"before"`
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var request moveRequest
+		if err := decodeJSON(w, r, &request); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		newRank, err := repo.Move(id, request.After, request.Before)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		jsonBody, err := json.Marshal(map[string]any{"id": id, "rank": newRank})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write(jsonBody)
+	}
+}
+
+func ArchiveTodo(repo repository.TodoRepository, archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.SetArchived(id, archived); err != nil {
+			writeNotFoundError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+// DuplicateTodo copies a todo. Tags, description and attachments are copied
+// unless switched off in the body; an empty body copies everything.
+func DuplicateTodo(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type duplicateRequest struct {
+			Tags         *bool `json:"tags"`
+			Description  *bool `json:"description"`
+			Attachments  *bool `json:"attachments"`
+			ResetStatus  bool  `json:"reset_status"`
+			DueShiftDays int   `json:"due_shift_days"`
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		var request duplicateRequest
+		if err := decodeJSON(w, r, &request); err != nil && !errors.Is(err, io.EOF) {
+			writeRequestError(w, err)
+			return
+		}
+
+		options := todo.DuplicateOptions{
+			Tags:         request.Tags == nil || *request.Tags,
+			Description:  request.Description == nil || *request.Description,
+			Attachments:  request.Attachments == nil || *request.Attachments,
+			ResetStatus:  request.ResetStatus,
+			DueShiftDays: request.DueShiftDays,
+		}
+		if userId, ok := identity.UserID(r.Context()); ok {
+			options.CreatedBy = &userId
+		}
+		duplicate, err := repo.Duplicate(id, options)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		jsonTodo, err := json.Marshal(duplicate)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(jsonTodo)
+	}
+}
+
+// MaxBatchBodySize is the largest body BatchCreateTodos accepts.
+const MaxBatchBodySize = 64 << 20
+
+// BatchCreateTodos creates up to todo.MaxBatchSize todos from a JSON array or,
+// sent as application/x-ndjson, from one JSON todo per line. Every row is
+// validated on its own: the valid ones are created together and the response
+// lists the created ids by row, with null for rows that failed, and the
+// errors of those rows.
+func BatchCreateTodos(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type rowError struct {
+			Index  int               `json:"index"`
+			Error  string            `json:"error"`
+			Fields validation.Errors `json:"fields,omitempty"`
+		}
+		type batchResponse struct {
+			Created int        `json:"created"`
+			Failed  int        `json:"failed"`
+			IDs     []*int     `json:"ids"`
+			Errors  []rowError `json:"errors"`
+		}
+
+		body := http.MaxBytesReader(w, r.Body, MaxBatchBodySize)
+		var rows [][]byte
+		var err error
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			rows, err = readNDJSONRows(body)
+		default:
+			rows, err = readJSONArrayRows(body)
+		}
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		if len(rows) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("no todos provided"))
+			return
+		}
+
+		response := batchResponse{IDs: make([]*int, len(rows)), Errors: []rowError{}}
+		addError := func(index int, err error) {
+			item := rowError{Index: index, Error: err.Error()}
+			if errors.As(err, &item.Fields) {
+				item.Error = "validation failed"
+			}
+			response.Errors = append(response.Errors, item)
+			response.Failed++
+		}
+		var createdBy *int
+		if userId, ok := identity.UserID(r.Context()); ok {
+			createdBy = &userId
+		}
+		var todos todo.Todos
+		var indexes []int
+		for i, row := range rows {
+			newTodo := &todo.Todo{}
+			if err := decodeValue(row, newTodo); err != nil {
+				addError(i, err)
+				continue
+			}
+			newTodo.CreatedBy, newTodo.ParentID = createdBy, nil
+			todos = append(todos, newTodo)
+			indexes = append(indexes, i)
+		}
+
+		if len(todos) > 0 {
+			rowErrors, err := repo.CreateBatch(todos)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			for i, t := range todos {
+				if rowErrors[i] != nil {
+					addError(indexes[i], rowErrors[i])
+					continue
+				}
+				response.IDs[indexes[i]] = &t.ID
+				response.Created++
+			}
+		}
+		slices.SortFunc(response.Errors, func(a, b rowError) int {
+			return a.Index - b.Index
+		})
+
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if response.Created == 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write(jsonResponse)
+	}
+}
+
+// readJSONArrayRows splits a JSON array into its raw elements. Only syntax
+// errors fail the whole array; the elements are decoded one by one later.
+func readJSONArrayRows(body io.Reader) ([][]byte, error) {
+	decoder := json.NewDecoder(body)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.New("request body must be a JSON array or NDJSON")
+	}
+	var rows [][]byte
+	for decoder.More() {
+		if len(rows) == todo.MaxBatchSize {
+			return nil, fmt.Errorf("a batch may contain at most %d todos", todo.MaxBatchSize)
+		}
+		var row json.RawMessage
+		if err := decoder.Decode(&row); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// readNDJSONRows splits newline-delimited JSON into its non-blank lines.
+func readNDJSONRows(body io.Reader) ([][]byte, error) {
+	reader := bufio.NewReader(body)
+	var rows [][]byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if len(rows) == todo.MaxBatchSize {
+				return nil, fmt.Errorf("a batch may contain at most %d todos", todo.MaxBatchSize)
+			}
+			rows = append(rows, line)
+		}
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// BulkUpdateTodos applies one update to the todos listed in the body or, when
+// no ids are given, to every todo matching the GetAllTodos filters in the
+// query string, which must hold at least one condition and nothing else; see
+// checkFilterKeys. The mode is "atomic" (the default), where any failure rolls
+// everything back and answers 409, or "best_effort". Either way the response
+// lists the outcome for each todo.
+func BulkUpdateTodos(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type bulkRequest struct {
+			TodoIds []int           `json:"ids"`
+			Update  todo.BulkUpdate `json:"update"`
+			Mode    string          `json:"mode"`
+			DryRun  bool            `json:"dry_run"`
+		}
+
+		var request bulkRequest
+		if err := decodeJSON(w, r, &request); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		options := todo.BulkOptions{DryRun: request.DryRun}
+		switch request.Mode {
+		case "", "atomic":
+			options.Atomic = true
+		case "best_effort":
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid mode, expected atomic or best_effort"))
+			return
+		}
+
+		var todoFilter filter.Filter
+		switch {
+		case request.TodoIds != nil && r.URL.RawQuery != "":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("ids and filters are mutually exclusive"))
+			return
+		case request.TodoIds == nil:
+			err := checkFilterKeys(r.URL.Query())
+			if err == nil {
+				todoFilter, err = parseFilter(r)
+			}
+			if err != nil {
+				writeFilterError(w, err)
+				return
+			}
+		}
+
+		result, err := repo.BulkUpdate(request.TodoIds, todoFilter, request.Update, options)
+		if err != nil && !errors.Is(err, repository.ErrBulkRolledBack) {
+			writeRequestError(w, err)
+			return
+		}
+		jsonResult, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(marshalErr.Error()))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusConflict)
+		}
+		w.Write(jsonResult)
+	}
+}
+
+// ReassignTodos sets the assignee of several todos at once. A null
+// assignee_id unassigns them.
+func ReassignTodos(repo repository.TodoRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type reassignRequest struct {
+			TodoIds    []int `json:"ids"`
+			AssigneeID *int  `json:"assignee_id"`
+		}
+
+		var request reassignRequest
+		if err := decodeJSON(w, r, &request); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		reassigned, err := repo.Reassign(request.TodoIds, request.AssigneeID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		jsonBody, err := json.Marshal(map[string]int{"reassigned": reassigned})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write(jsonBody)
+	}
+}
+
+// errMissingUserID reports a filter on the caller's own todos without a
+// caller.
+var errMissingUserID = errors.New("missing " + identity.UserIDHeader + " header")
+
+func writeFilterError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errMissingUserID) {
+		w.WriteHeader(http.StatusUnauthorized)
+	} else {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
+
+func writeNotFoundError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Write([]byte(err.Error()))
+}
+
+// writeTodoError answers a failed create or update: 404 for a missing todo,
+// 422 or 400 for values the client sent and 500 for anything else.
+func writeTodoError(w http.ResponseWriter, err error) {
+	var validationErrors validation.Errors
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		writeNotFoundError(w, err)
+	case errors.As(err, &validationErrors), errors.Is(err, repository.ErrInvalidValue),
+		errors.Is(err, repository.ErrProjectNotFound), errors.Is(err, repository.ErrUserNotFound):
+		writeRequestError(w, err)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}
+}
+
+// MaxBodySize is the largest request body the todo handlers accept.
+const MaxBodySize = 1 << 20
+
+// decodeJSON decodes a single JSON value from a body of at most MaxBodySize
+// bytes into v; see decodeValue. An empty body gives io.EOF.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	if decoder.Decode(&struct{}{}) != io.EOF {
+		return errors.New("request body must contain a single JSON value")
+	}
+	return decodeValue(value, v)
+}
+
+// validator is implemented by request bodies that check themselves, such as
+// todo.Todo.
+type validator interface {
+	Validate() error
+}
+
+// decodeValue decodes data into v, which points to a struct, field by field,
+// so that every unknown field and mistyped value is reported, then runs
+// Validate when v has it. All of them come back as one validation.Errors;
+// fields that failed to decode aren't validated again. Other targets are
+// decoded strictly and report their first problem; see decodeError.
+func decodeValue(data []byte, v any) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct || isUnmarshaler(target.Type()) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return decodeError(err)
+		}
+		return nil
+	}
+
+	var errs validation.Errors
+	if err := decodeFields(data, target.Elem(), "", &errs); err != nil {
+		return err
+	}
+	if validatable, ok := v.(validator); ok {
+		var validationErrors validation.Errors
+		if err := validatable.Validate(); errors.As(err, &validationErrors) {
+			for _, fieldError := range validationErrors {
+				if !slices.ContainsFunc(errs, func(e validation.FieldError) bool {
+					return topField(e.Field) == topField(fieldError.Field)
+				}) {
+					errs = append(errs, fieldError)
+				}
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	return errs.Err()
+}
+
+// decodeFields decodes the JSON object data into the struct target, adding an
+// error named prefix plus the key for each unknown or undecodable field.
+// Nested structs are decoded the same way. Keys match field names without
+// regard to case, as in encoding/json. It returns an error only when data
+// isn't an object.
+func decodeFields(data []byte, target reflect.Value, prefix string, errs *validation.Errors) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		var typeError *json.UnmarshalTypeError
+		if errors.As(err, &typeError) && prefix != "" {
+			errs.Add(strings.TrimSuffix(prefix, "."), "expected object, got %s", typeError.Value)
+			return nil
+		}
+		if errors.As(err, &typeError) {
+			return fmt.Errorf("request body must be a JSON object, got %s", typeError.Value)
+		}
+		return err
+	}
+
+	fields := reflect.VisibleFields(target.Type())
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		name := prefix + key
+		index := slices.IndexFunc(fields, func(f reflect.StructField) bool { return jsonName(f) == key })
+		if index < 0 {
+			index = slices.IndexFunc(fields, func(f reflect.StructField) bool { return strings.EqualFold(jsonName(f), key) })
+		}
+		if index < 0 {
+			errs.Add(name, "unknown field")
+			continue
+		}
+		field := target.FieldByIndex(fields[index].Index)
+		raw := object[key]
+		if nested := structTarget(field, raw); nested.IsValid() {
+			if err := decodeFields(raw, nested, name+".", errs); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+			var typeError *json.UnmarshalTypeError
+			if errors.As(err, &typeError) {
+				for _, element := range strings.Split(typeError.Field, ".") {
+					if _, err := strconv.Atoi(element); err == nil {
+						name += "[" + element + "]"
+					} else if element != "" {
+						name += "." + element
+					}
+				}
+				errs.Add(name, "expected %s, got %s", typeError.Type, typeError.Value)
+			} else {
+				errs.Add(name, "%s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// topField returns the top-level key of a field error name such as
+// "tags[1]" or "update.status".
+func topField(name string) string {
+	if i := strings.IndexAny(name, ".["); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// jsonName returns the key encoding/json uses for a field, or "" when the
+// field isn't decoded.
+func jsonName(field reflect.StructField) string {
+	if !field.IsExported() || field.Anonymous && field.Type.Kind() == reflect.Struct {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// structTarget returns the struct a field holding raw should be decoded into
+// field by field, allocating pointers, or the zero Value when the field is
+// decoded as a whole.
+func structTarget(field reflect.Value, raw json.RawMessage) reflect.Value {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) || isUnmarshaler(field.Addr().Type()) {
+		return reflect.Value{}
+	}
+	switch {
+	case field.Kind() == reflect.Struct:
+		return field
+	case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct &&
+		!isUnmarshaler(field.Type()):
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return field.Elem()
+	}
+	return reflect.Value{}
+}
+
+func isUnmarshaler(t reflect.Type) bool {
+	return t.Implements(reflect.TypeFor[json.Unmarshaler]())
+}
+
+// decodeError reports unknown fields and mistyped values from a strict JSON
+// decoder as validation.Errors and returns other errors unchanged.
+func decodeError(err error) error {
+	var errs validation.Errors
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		errs.Add(typeError.Field, "expected %s, got %s", typeError.Type, typeError.Value)
+		return errs
+	}
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+		errs.Add(name, "unknown field")
+		return errs
+	}
+	return err
+}
+
+// writeRequestError answers 413 for oversized bodies, 422 with every field
+// error for validation.Errors and 400 otherwise.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(fmt.Sprintf("request body must be at most %d bytes", maxBytesError.Limit)))
+		return
+	}
+	var validationErrors validation.Errors
+	if !errors.As(err, &validationErrors) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	jsonBody, err := json.Marshal(map[string]any{"error": "validation failed", "fields": validationErrors})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write(jsonBody)
 }
@@ -0,0 +1,150 @@
+package vocabularyhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+func GetAllStatuses(repo *repository.StatusPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		definitions, err := repo.GetAll()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, definitions)
+	}
+}
+
+func CreateStatus(repo *repository.StatusPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var definition status.Definition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.Create(&definition); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, definition)
+	}
+}
+
+// UpdateStatus changes a status named by the URL; a value in the body is
+// ignored.
+func UpdateStatus(repo *repository.StatusPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var definition status.Definition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		definition.Value = status.Status(chi.URLParam(r, "value"))
+		if err := repo.Update(&definition); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, definition)
+	}
+}
+
+func DeleteStatus(repo *repository.StatusPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := repo.Delete(status.Status(chi.URLParam(r, "value"))); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+func GetAllPriorities(repo *repository.PriorityPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		definitions, err := repo.GetAll()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, definitions)
+	}
+}
+
+func CreatePriority(repo *repository.PriorityPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var definition priority.Definition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := repo.Create(&definition); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, definition)
+	}
+}
+
+// UpdatePriority changes a priority named by the URL; a value in the body is
+// ignored.
+func UpdatePriority(repo *repository.PriorityPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var definition priority.Definition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		definition.Value = priority.Priority(chi.URLParam(r, "value"))
+		if err := repo.Update(&definition); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, definition)
+	}
+}
+
+func DeletePriority(repo *repository.PriorityPostgresRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := repo.Delete(priority.Priority(chi.URLParam(r, "value"))); err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	jsonBody, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(jsonBody)
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, repository.ErrStatusExists),
+		errors.Is(err, repository.ErrPriorityExists),
+		errors.Is(err, repository.ErrValueInUse):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(err.Error()))
+}
@@ -0,0 +1,96 @@
+// Package httpcache implements entity tags and conditional GET requests.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CacheControl lets clients and private caches keep responses but makes them
+// revalidate before each use, which a conditional request makes cheap.
+const CacheControl = "private, no-cache"
+
+// StrongETag returns an entity tag for the exact bytes of body.
+func StrongETag(body []byte) string {
+	return `"` + hash(body) + `"`
+}
+
+// WeakETag returns an entity tag for a representation identified by parts
+// rather than by its bytes.
+func WeakETag(parts ...string) string {
+	return `W/"` + hash([]byte(strings.Join(parts, "\x00"))) + `"`
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Write sends body with its validators and Cache-Control, or 304 Not Modified
+// without a body when the request's preconditions show the client already
+// has it. A zero lastModified omits Last-Modified.
+func Write(w http.ResponseWriter, r *http.Request, body []byte, etag string, lastModified time.Time) {
+	w.Header().Set("Cache-Control", CacheControl)
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if NotModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(body)
+}
+
+// NotModified evaluates If-None-Match and If-Modified-Since for a GET or
+// HEAD request as RFC 9110 section 13.2.2 orders them: If-Modified-Since is
+// ignored when If-None-Match is present.
+func NotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return matchesAny(ifNoneMatch, etag)
+	}
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	// HTTP dates have a resolution of one second.
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// matchesAny reports whether the If-None-Match list matches etag using the
+// weak comparison.
+func matchesAny(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for list != "" {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			break
+		}
+		if list[0] == '*' {
+			return true
+		}
+		list = strings.TrimPrefix(list, "W/")
+		if list == "" || list[0] != '"' {
+			return false
+		}
+		end := strings.IndexByte(list[1:], '"')
+		if end < 0 {
+			return false
+		}
+		if list[:end+2] == etag {
+			return true
+		}
+		list = list[end+2:]
+	}
+	return false
+}
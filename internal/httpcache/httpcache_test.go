@@ -0,0 +1,122 @@
+package httpcache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2025, 5, 5, 9, 31, 20, 500_000_000, time.UTC)
+	etag := StrongETag([]byte(`{"id": 1}`))
+	testCases := []struct {
+		name     string
+		method   string
+		header   map[string]string
+		expected bool
+	}{
+		{
+			name:     "no preconditions",
+			expected: false,
+		},
+		{
+			name:     "matching etag",
+			header:   map[string]string{"If-None-Match": etag},
+			expected: true,
+		},
+		{
+			name:     "matching weak etag in a list",
+			header:   map[string]string{"If-None-Match": `"other", W/` + etag},
+			expected: true,
+		},
+		{
+			name:     "any etag",
+			header:   map[string]string{"If-None-Match": "*"},
+			expected: true,
+		},
+		{
+			name:     "stale etag",
+			header:   map[string]string{"If-None-Match": `"other"`},
+			expected: false,
+		},
+		{
+			name:     "malformed etag",
+			header:   map[string]string{"If-None-Match": "other"},
+			expected: false,
+		},
+		{
+			name:     "not modified since",
+			header:   map[string]string{"If-Modified-Since": "Mon, 05 May 2025 09:31:20 GMT"},
+			expected: true,
+		},
+		{
+			name:     "modified since",
+			header:   map[string]string{"If-Modified-Since": "Mon, 05 May 2025 09:31:19 GMT"},
+			expected: false,
+		},
+		{
+			name:     "invalid date",
+			header:   map[string]string{"If-Modified-Since": "yesterday"},
+			expected: false,
+		},
+		{
+			name: "etag takes precedence over date",
+			header: map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": "Mon, 05 May 2025 09:31:20 GMT",
+			},
+			expected: false,
+		},
+		{
+			name:     "unsafe method",
+			method:   http.MethodPost,
+			header:   map[string]string{"If-None-Match": etag},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			request := httptest.NewRequest(method, "/todo/1", nil)
+			for name, value := range tc.header {
+				request.Header.Set(name, value)
+			}
+			assert.Equal(t, tc.expected, NotModified(request, etag, lastModified))
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	body := []byte(`{"id": 1}`)
+	etag := StrongETag(body)
+	lastModified := time.Date(2025, 5, 5, 9, 31, 20, 0, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	Write(recorder, httptest.NewRequest(http.MethodGet, "/todo/1", nil), body, etag, lastModified)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, string(body), recorder.Body.String())
+	assert.Equal(t, etag, recorder.Header().Get("ETag"))
+	assert.Equal(t, "Mon, 05 May 2025 09:31:20 GMT", recorder.Header().Get("Last-Modified"))
+	assert.Equal(t, CacheControl, recorder.Header().Get("Cache-Control"))
+
+	request := httptest.NewRequest(http.MethodGet, "/todo/1", nil)
+	request.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	Write(recorder, request, body, etag, lastModified)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+	assert.Equal(t, etag, recorder.Header().Get("ETag"))
+}
+
+func TestETags(t *testing.T) {
+	assert.Equal(t, StrongETag([]byte("a")), StrongETag([]byte("a")))
+	assert.NotEqual(t, StrongETag([]byte("a")), StrongETag([]byte("b")))
+	assert.Regexp(t, `^W/"[0-9a-f]{32}"$`, WeakETag("a", "b"))
+	assert.NotEqual(t, WeakETag("ab", "c"), WeakETag("a", "bc"))
+}
@@ -0,0 +1,229 @@
+// Package idempotency lets clients retry POST requests safely. The first
+// response to a request carrying an Idempotency-Key header is stored and
+// replayed to every retry with the same key.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/identity"
+	"github.com/GlebMoskalev/todo-api/internal/validation"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Header = "Idempotency-Key"
+	// ReplayedHeader marks responses replayed from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+	MaxKeyLength   = 255
+	// DefaultTTL is how long responses are kept unless configured otherwise.
+	DefaultTTL = 24 * time.Hour
+	// maxBodySize bounds the request bodies read for fingerprinting. It
+	// matches the largest JSON, CSV, YAML or MessagePack body a route takes;
+	// multipart uploads aren't read at all.
+	maxBodySize = 64 << 20
+)
+
+var ErrKeyNotReserved = errors.New("idempotency key not reserved")
+
+// Record is what a Store keeps for a key.
+type Record struct {
+	// Fingerprint identifies the request that claimed the key.
+	Fingerprint string
+	// Status is zero while that request is still being handled.
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store keeps idempotency records until their TTL passes.
+type Store interface {
+	// Reserve claims key for a request with fingerprint. If a live record
+	// already holds key it is returned instead and nothing is claimed.
+	Reserve(key, fingerprint string, ttl time.Duration) (*Record, error)
+	// Complete stores the response of the request that reserved key.
+	Complete(key string, record *Record) error
+	// Release forgets key so that its request can be retried.
+	Release(key string) error
+	DeleteExpired() (int, error)
+}
+
+// Middleware makes POST requests with an Idempotency-Key header idempotent.
+// Keys are scoped to the caller's X-User-ID, which is required with a key so
+// that anonymous clients can't replay each other's responses. A retry with a
+// different method, path, body, Content-Type or Accept answers 422 and one
+// arriving while the original is still running answers 409. Server errors aren't stored, so those
+// requests can be retried with the same key.
+//
+// Multipart requests, such as attachment uploads, pass through untouched:
+// their bodies are streamed under the limits of their own handlers and can't
+// be buffered for a fingerprint.
+func Middleware(store Store, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if r.Method != http.MethodPost || key == "" || isMultipart(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > MaxKeyLength {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(fmt.Sprintf("%s must be at most %d characters", Header, MaxKeyLength)))
+				return
+			}
+			userId, ok := identity.UserID(r.Context())
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(identity.UserIDHeader + " header is required with " + Header))
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			if err != nil {
+				var maxBytesError *http.MaxBytesError
+				if errors.As(err, &maxBytesError) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+				} else {
+					w.WriteHeader(http.StatusBadRequest)
+				}
+				w.Write([]byte(err.Error()))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key = strconv.Itoa(userId) + ":" + key
+			fingerprint := Fingerprint(r, body)
+			record, err := store.Reserve(key, fingerprint, ttl)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if record != nil {
+				replay(w, record, fingerprint)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if !completed {
+					store.Release(key)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			if recorder.status >= http.StatusInternalServerError {
+				return
+			}
+			err = store.Complete(key, &Record{
+				Fingerprint: fingerprint,
+				Status:      recorder.status,
+				Header:      recorder.Header().Clone(),
+				Body:        recorder.body.Bytes(),
+			})
+			completed = err == nil
+		})
+	}
+}
+
+func isMultipart(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return strings.HasPrefix(mediaType, "multipart/")
+}
+
+func replay(w http.ResponseWriter, record *Record, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		var errs validation.Errors
+		errs.Add(Header, "was already used for a different request")
+		jsonBody, err := json.Marshal(map[string]any{"error": "validation failed", "fields": errs})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(jsonBody)
+	case record.Status == 0:
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("a request with this " + Header + " is still in progress"))
+	default:
+		for name, values := range record.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(record.Status)
+		w.Write(record.Body)
+	}
+}
+
+// Fingerprint hashes the method, URL, body and the Content-Type and Accept
+// headers of a request. The headers pick the formats, and for Accept the API
+// version, so a stored response only fits a retry that negotiated the same.
+func Fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write([]byte("Content-Type: " + r.Header.Get("Content-Type") + "\n"))
+	hash.Write([]byte("Accept: " + r.Header.Get("Accept") + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// RunCleanup deletes expired records from store. It runs once at start and
+// then every interval until ctx is done.
+func RunCleanup(ctx context.Context, store Store, interval time.Duration, logger *slog.Logger) {
+	logger.Info("Starting idempotency key cleanup", slog.Duration("interval", interval))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		deleted, err := store.DeleteExpired()
+		if err != nil {
+			logger.Error("Failed to delete expired idempotency keys", slog.String("error", err.Error()))
+		} else if deleted > 0 {
+			logger.Info("Deleted expired idempotency keys", slog.Int("count", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of its
+// status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,169 @@
+package idempotency
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/identity"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2025, 5, 2, 10, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	record, err := store.Reserve("key", "a", time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	record, err = store.Reserve("key", "b", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, &Record{Fingerprint: "a"}, record)
+
+	assert.NoError(t, store.Complete("key", &Record{Fingerprint: "a", Status: http.StatusCreated, Body: []byte("1")}))
+	record, err = store.Reserve("key", "a", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, record.Status)
+
+	// Completed records survive Release and are only dropped once expired.
+	assert.NoError(t, store.Release("key"))
+	deleted, err := store.DeleteExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+
+	now = now.Add(time.Hour)
+	record, err = store.Reserve("key", "b", time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	now = now.Add(time.Hour)
+	deleted, err = store.DeleteExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.ErrorIs(t, store.Complete("key", &Record{}), ErrKeyNotReserved)
+}
+
+func TestMiddleware(t *testing.T) {
+	calls := 0
+	handler := identity.Middleware(Middleware(NewMemoryStore(), time.Hour)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.URL.Query().Has("fail") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 1}`))
+		}),
+	))
+	post := func(target, key, userId, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if key != "" {
+			request.Header.Set(Header, key)
+		}
+		if userId != "" {
+			request.Header.Set(identity.UserIDHeader, userId)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	first := post("/todo", "abc", "1", `{"title": "a"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(ReplayedHeader))
+
+	replayed := post("/todo", "abc", "1", `{"title": "a"}`)
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, `{"id": 1}`, replayed.Body.String())
+	assert.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+	assert.Equal(t, "true", replayed.Header().Get(ReplayedHeader))
+	assert.Equal(t, 1, calls)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, post("/todo", "abc", "1", `{"title": "b"}`).Code)
+	assert.Equal(t, 1, calls)
+
+	// Keys belong to a user, and requests without a key pass through.
+	assert.Empty(t, post("/todo", "abc", "2", `{"title": "a"}`).Header().Get(ReplayedHeader))
+	assert.Empty(t, post("/todo", "", "1", `{"title": "a"}`).Header().Get(ReplayedHeader))
+	assert.Equal(t, 3, calls)
+
+	// Server errors aren't stored.
+	assert.Equal(t, http.StatusInternalServerError, post("/todo?fail", "retry", "1", "{}").Code)
+	assert.Equal(t, http.StatusInternalServerError, post("/todo?fail", "retry", "1", "{}").Code)
+	assert.Equal(t, 5, calls)
+
+	assert.Equal(t, http.StatusBadRequest, post("/todo", strings.Repeat("k", MaxKeyLength+1), "1", "{}").Code)
+
+	// Keys need a user to be scoped to.
+	assert.Equal(t, http.StatusUnauthorized, post("/todo", "abc", "", `{"title": "a"}`).Code)
+	assert.Equal(t, 5, calls)
+}
+
+func TestMiddlewareFingerprintsFormats(t *testing.T) {
+	calls := 0
+	handler := identity.Middleware(Middleware(NewMemoryStore(), time.Hour)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusCreated)
+		}),
+	))
+	post := func(contentType, accept string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(`{"title": "a"}`))
+		request.Header.Set(Header, "abc")
+		request.Header.Set(identity.UserIDHeader, "1")
+		request.Header.Set("Content-Type", contentType)
+		request.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusCreated, post("application/json", "application/json").Code)
+	assert.Equal(t, "true", post("application/json", "application/json").Header().Get(ReplayedHeader))
+	assert.Equal(t, http.StatusUnprocessableEntity, post("application/json", "application/x-msgpack").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, post("application/json", "application/vnd.todo-api.v1+json").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, post("application/yaml", "application/json").Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestMiddlewareSkipsMultipart(t *testing.T) {
+	handler := identity.Middleware(Middleware(NewMemoryStore(), time.Hour)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+		}),
+	))
+	for _, body := range []string{"first", "second"} {
+		request := httptest.NewRequest(http.MethodPost, "/todo/1/attachments", strings.NewReader(body))
+		request.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+		request.Header.Set(Header, "abc")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Equal(t, body, recorder.Body.String())
+		assert.Empty(t, recorder.Header().Get(ReplayedHeader))
+	}
+}
+
+func TestMiddlewareRejectsConcurrentRetries(t *testing.T) {
+	store := NewMemoryStore()
+	_, err := store.Reserve("1:abc", Fingerprint(httptest.NewRequest(http.MethodPost, "/todo", nil), []byte("{}")), time.Hour)
+	assert.NoError(t, err)
+
+	handler := identity.Middleware(Middleware(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler must not run while the key is in progress")
+	})))
+	request := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader("{}"))
+	request.Header.Set(Header, "abc")
+	request.Header.Set(identity.UserIDHeader, "1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+}
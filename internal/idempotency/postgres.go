@@ -0,0 +1,118 @@
+package idempotency
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+type PostgresStore struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewPostgresStore(db *sql.DB, logger *slog.Logger) *PostgresStore {
+	return &PostgresStore{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *PostgresStore) Reserve(key, fingerprint string, ttl time.Duration) (*Record, error) {
+	for {
+		// Expired records are taken over in place, so a key that outlived its
+		// TTL can be used again before the cleanup has removed it.
+		var reserved string
+		err := s.db.QueryRow(
+			"INSERT INTO idempotency_keys (key, fingerprint, expires_at) "+
+				"VALUES ($1, $2, now() + $3 * interval '1 microsecond') "+
+				"ON CONFLICT (key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = NULL, "+
+				"header = NULL, body = NULL, created_at = now(), expires_at = EXCLUDED.expires_at "+
+				"WHERE idempotency_keys.expires_at <= now() RETURNING key",
+			key,
+			fingerprint,
+			ttl.Microseconds(),
+		).Scan(&reserved)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Error("Failed to reserve idempotency key", slog.String("error", err.Error()))
+			return nil, err
+		}
+
+		var (
+			record Record
+			status sql.NullInt64
+			header []byte
+		)
+		err = s.db.QueryRow(
+			"SELECT fingerprint, status, header, body FROM idempotency_keys WHERE key = $1 AND expires_at > now()",
+			key,
+		).Scan(&record.Fingerprint, &status, &header, &record.Body)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The record was released or expired in between; try again.
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Failed to fetch idempotency key", slog.String("error", err.Error()))
+			return nil, err
+		}
+		record.Status = int(status.Int64)
+		if header != nil {
+			if err := json.Unmarshal(header, &record.Header); err != nil {
+				return nil, err
+			}
+		}
+		return &record, nil
+	}
+}
+
+func (s *PostgresStore) Complete(key string, record *Record) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(
+		"UPDATE idempotency_keys SET status = $2, header = $3, body = $4 WHERE key = $1 AND fingerprint = $5",
+		key,
+		record.Status,
+		header,
+		record.Body,
+		record.Fingerprint,
+	)
+	if err != nil {
+		s.logger.Error("Failed to store idempotent response", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrKeyNotReserved
+	}
+	return nil
+}
+
+func (s *PostgresStore) Release(key string) error {
+	if _, err := s.db.Exec("DELETE FROM idempotency_keys WHERE key = $1 AND status IS NULL", key); err != nil {
+		s.logger.Error("Failed to release idempotency key", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteExpired() (int, error) {
+	result, err := s.db.Exec("DELETE FROM idempotency_keys WHERE expires_at <= now()")
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
+}
@@ -0,0 +1,33 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+const UserIDHeader = "X-User-ID"
+
+type contextKey struct{}
+
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawUserId := r.Header.Get(UserIDHeader)
+		if rawUserId == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		userId, err := strconv.Atoi(rawUserId)
+		if err != nil || userId <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid " + UserIDHeader + " header"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, userId)))
+	})
+}
+
+func UserID(ctx context.Context) (int, bool) {
+	userId, ok := ctx.Value(contextKey{}).(int)
+	return userId, ok
+}
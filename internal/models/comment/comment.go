@@ -0,0 +1,47 @@
+package comment
+
+import (
+	"bytes"
+	"errors"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"strings"
+	"time"
+)
+
+const MaxBodyLength = 10000
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+type Comment struct {
+	ID        int        `json:"id"`
+	TodoID    int        `json:"todo_id"`
+	AuthorID  int        `json:"author_id"`
+	Body      string     `json:"body"`
+	BodyHTML  string     `json:"body_html"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at"`
+}
+
+type Comments []*Comment
+
+func (c *Comment) Validate() error {
+	if strings.TrimSpace(c.Body) == "" {
+		return errors.New("comment body must not be empty")
+	}
+	if len(c.Body) > MaxBodyLength {
+		return errors.New("comment body is too long")
+	}
+	return nil
+}
+
+// RenderBody converts the Markdown body into HTML. Raw HTML in the source
+// is dropped by the renderer, so the result is safe to embed.
+func (c *Comment) RenderBody() error {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(c.Body), &buf); err != nil {
+		return err
+	}
+	c.BodyHTML = buf.String()
+	return nil
+}
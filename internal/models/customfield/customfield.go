@@ -0,0 +1,183 @@
+package customfield
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Type string
+
+const (
+	Text    Type = "text"
+	Number  Type = "number"
+	Date    Type = "date"
+	Enum    Type = "enum"
+	Boolean Type = "boolean"
+)
+
+const (
+	MaxNameLength      = 200
+	MaxTextValueLength = 2000
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+func IsValidType(t Type) bool {
+	switch t {
+	case Text, Number, Date, Enum, Boolean:
+		return true
+	default:
+		return false
+	}
+}
+
+// Definition describes one custom attribute that todos may carry. Key is the
+// name used in todo JSON and in cf.<key> filters.
+type Definition struct {
+	ID        int       `json:"id"`
+	Key       string    `json:"key"`
+	Name      string    `json:"name"`
+	Type      Type      `json:"type"`
+	Options   []string  `json:"options"`
+	Required  bool      `json:"required"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Definitions []*Definition
+
+// Values maps definition keys to values. Numbers are float64, dates are
+// YYYY-MM-DD strings.
+type Values map[string]any
+
+func (d *Definition) Validate() error {
+	if !keyPattern.MatchString(d.Key) {
+		return fmt.Errorf("invalid value field \"Key\": %s, expected lowercase letters, digits and underscores", d.Key)
+	}
+	if strings.TrimSpace(d.Name) == "" {
+		return errors.New("custom field name must not be empty")
+	}
+	if len(d.Name) > MaxNameLength {
+		return fmt.Errorf("custom field name must be at most %d characters", MaxNameLength)
+	}
+	if !IsValidType(d.Type) {
+		return fmt.Errorf("invalid value field \"Type\": %s", d.Type)
+	}
+	if d.Type == Enum && len(d.Options) == 0 {
+		return errors.New("enum custom field needs at least one option")
+	}
+	if d.Type != Enum && len(d.Options) > 0 {
+		return errors.New("only enum custom fields can have options")
+	}
+	for _, option := range d.Options {
+		if option == "" {
+			return errors.New("enum options must not be empty")
+		}
+	}
+	return nil
+}
+
+func (defs Definitions) Find(key string) *Definition {
+	for _, d := range defs {
+		if d.Key == key {
+			return d
+		}
+	}
+	return nil
+}
+
+// ValidateValues checks values against the definitions and returns them in
+// normalized form. Null values are dropped.
+func (defs Definitions) ValidateValues(values Values) (Values, error) {
+	normalized := Values{}
+	for key, value := range values {
+		d := defs.Find(key)
+		if d == nil {
+			return nil, fmt.Errorf("unknown custom field %q", key)
+		}
+		if value == nil {
+			continue
+		}
+		normalizedValue, err := d.normalize(value)
+		if err != nil {
+			return nil, err
+		}
+		normalized[key] = normalizedValue
+	}
+	for _, d := range defs {
+		if _, ok := normalized[d.Key]; d.Required && !ok {
+			return nil, fmt.Errorf("custom field %q is required", d.Key)
+		}
+	}
+	return normalized, nil
+}
+
+// ParseFilterValue converts a query string value into the JSON value stored
+// for this field, so it can be matched by containment.
+func (d *Definition) ParseFilterValue(raw string) (any, error) {
+	switch d.Type {
+	case Number:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number for custom field %q", d.Key)
+		}
+		return d.normalize(number)
+	case Boolean:
+		boolean, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean for custom field %q", d.Key)
+		}
+		return boolean, nil
+	default:
+		return d.normalize(raw)
+	}
+}
+
+func (d *Definition) normalize(value any) (any, error) {
+	switch d.Type {
+	case Text:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("custom field %q must be a string", d.Key)
+		}
+		if len(text) > MaxTextValueLength {
+			return nil, fmt.Errorf("custom field %q must be at most %d characters", d.Key, MaxTextValueLength)
+		}
+		return text, nil
+	case Number:
+		number, ok := value.(float64)
+		if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, fmt.Errorf("custom field %q must be a number", d.Key)
+		}
+		return number, nil
+	case Date:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("custom field %q must be a date string", d.Key)
+		}
+		date, err := time.Parse(time.DateOnly, text)
+		if err != nil {
+			return nil, fmt.Errorf("custom field %q must be a date in YYYY-MM-DD format", d.Key)
+		}
+		return date.Format(time.DateOnly), nil
+	case Enum:
+		text, ok := value.(string)
+		if !ok || !slices.Contains(d.Options, text) {
+			return nil, fmt.Errorf("custom field %q must be one of %s", d.Key, strings.Join(d.Options, ", "))
+		}
+		return text, nil
+	case Boolean:
+		boolean, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("custom field %q must be a boolean", d.Key)
+		}
+		return boolean, nil
+	default:
+		return nil, fmt.Errorf("invalid value field \"Type\": %s", d.Type)
+	}
+}
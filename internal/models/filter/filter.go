@@ -0,0 +1,56 @@
+package filter
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/GlebMoskalev/todo-api/internal/models/todo"
+	"strings"
+)
+
+// CustomFieldPrefix marks custom field keys in filter and sort parameters,
+// e.g. cf.customer=acme or sort=-cf.story_points.
+const CustomFieldPrefix = "cf."
+
+// Filter holds the conditions, ordering and shape of the todos GetAll
+// returns. Zero values mean "no restriction".
+type Filter struct {
+	Tags     []string
+	Status   status.Status
+	Priority priority.Priority
+	Overdue  *bool
+	DueDate  todo.NullTime
+	// ProjectID restricts results to one project, WithoutProject to todos
+	// that don't belong to any project.
+	ProjectID      *int
+	WithoutProject bool
+	// AssigneeID restricts results to one assignee, WithoutAssignee to
+	// unassigned todos.
+	AssigneeID      *int
+	WithoutAssignee bool
+	// Available keeps only todos that aren't snoozed (true) or only snoozed
+	// ones (false). Snoozed todos become available once defer_until passes.
+	Available *bool
+	// Archived keeps only unarchived (false) or only archived (true) todos.
+	Archived *bool
+	// CustomFields maps custom field keys to the raw value they must equal.
+	CustomFields map[string]string
+	Sort         Sort
+	// Fields limits the columns read for each todo, and Include names the
+	// related data embedded in them, see todo.ParseIncludes.
+	Fields  todo.Fieldset
+	Include []string
+}
+
+// Sort orders the results by a todo column or, with CustomFieldPrefix, by a
+// custom field. An empty Field keeps the default order.
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+func ParseSort(raw string) Sort {
+	if field, ok := strings.CutPrefix(raw, "-"); ok {
+		return Sort{Field: field, Descending: true}
+	}
+	return Sort{Field: raw}
+}
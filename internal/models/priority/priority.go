@@ -1,7 +1,11 @@
 package priority
 
+import "github.com/GlebMoskalev/todo-api/internal/vocabulary"
+
 type Priority string
 
+// The priorities every installation starts with. Further ones are configured
+// at runtime; see SetRegistry.
 const (
 	Low    Priority = "low"
 	Medium Priority = "medium"
@@ -9,11 +13,50 @@ const (
 	Urgent Priority = "urgent"
 )
 
+// Definition describes one configurable priority. Position orders priorities
+// from least to most important.
+type Definition struct {
+	Value    Priority `json:"value"`
+	Name     string   `json:"name"`
+	Position int      `json:"position"`
+	Color    string   `json:"color"`
+}
+
+type Definitions []*Definition
+
+func (d *Definition) Validate() error {
+	return vocabulary.Validate("priority", string(d.Value), d.Name, d.Color)
+}
+
+// Defaults mirrors the priorities seeded by the migrations. It serves as the
+// registry until SetRegistry loads the configured ones.
+var Defaults = Definitions{
+	{Value: Low, Name: "Low", Position: 1, Color: "#8bc34a"},
+	{Value: Medium, Name: "Medium", Position: 2, Color: "#ffc107"},
+	{Value: High, Name: "High", Position: 3, Color: "#ff9800"},
+	{Value: Urgent, Name: "Urgent", Position: 4, Color: "#f44336"},
+}
+
+var registry = vocabulary.NewRegistry(
+	func(d *Definition) Priority { return d.Value },
+	func(d *Definition) int { return d.Position },
+)
+
+func init() {
+	SetRegistry(Defaults)
+}
+
+// SetRegistry replaces the cached priorities.
+func SetRegistry(definitions Definitions) {
+	registry.Set(definitions)
+}
+
+// All returns the cached priorities from least to most important.
+func All() Definitions {
+	return registry.All()
+}
+
 func IsValidPriority(p Priority) bool {
-	switch p {
-	case Low, Medium, High, Urgent:
-		return true
-	default:
-		return false
-	}
+	_, ok := registry.Get(p)
+	return ok
 }
@@ -0,0 +1,46 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	MaxNameLength = 200
+	MaxIconLength = 64
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type Project struct {
+	ID           int                   `json:"id"`
+	Name         string                `json:"name"`
+	Description  string                `json:"description"`
+	Color        string                `json:"color"`
+	Icon         string                `json:"icon"`
+	Archived     bool                  `json:"archived"`
+	CreatedAt    time.Time             `json:"created_at"`
+	StatusCounts map[status.Status]int `json:"status_counts"`
+}
+
+type Projects []*Project
+
+func (p *Project) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("project name must not be empty")
+	}
+	if len(p.Name) > MaxNameLength {
+		return fmt.Errorf("project name must be at most %d characters", MaxNameLength)
+	}
+	if p.Color != "" && !colorPattern.MatchString(p.Color) {
+		return fmt.Errorf("invalid value field \"Color\": %s, expected #RRGGBB", p.Color)
+	}
+	if len(p.Icon) > MaxIconLength {
+		return fmt.Errorf("project icon must be at most %d characters", MaxIconLength)
+	}
+	return nil
+}
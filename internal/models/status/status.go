@@ -1,7 +1,11 @@
 package status
 
+import "github.com/GlebMoskalev/todo-api/internal/vocabulary"
+
 type Status string
 
+// The statuses every installation starts with. Further ones are configured at
+// runtime; see SetRegistry.
 const (
 	Planned    Status = "planned"
 	InProgress Status = "in_progress"
@@ -9,11 +13,58 @@ const (
 	Canceled   Status = "canceled"
 )
 
+// Definition describes one configurable status. Terminal statuses mean no
+// further work is expected, e.g. completed or canceled.
+type Definition struct {
+	Value    Status `json:"value"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Color    string `json:"color"`
+	Terminal bool   `json:"terminal"`
+}
+
+type Definitions []*Definition
+
+func (d *Definition) Validate() error {
+	return vocabulary.Validate("status", string(d.Value), d.Name, d.Color)
+}
+
+// Defaults mirrors the statuses seeded by the migrations. It serves as the
+// registry until SetRegistry loads the configured ones.
+var Defaults = Definitions{
+	{Value: Planned, Name: "Planned", Position: 1, Color: "#9e9e9e"},
+	{Value: InProgress, Name: "In progress", Position: 2, Color: "#2196f3"},
+	{Value: Completed, Name: "Completed", Position: 3, Color: "#4caf50", Terminal: true},
+	{Value: Canceled, Name: "Canceled", Position: 4, Color: "#795548", Terminal: true},
+}
+
+var registry = vocabulary.NewRegistry(
+	func(d *Definition) Status { return d.Value },
+	func(d *Definition) int { return d.Position },
+)
+
+func init() {
+	SetRegistry(Defaults)
+}
+
+// SetRegistry replaces the cached statuses.
+func SetRegistry(definitions Definitions) {
+	registry.Set(definitions)
+}
+
+// All returns the cached statuses in display order.
+func All() Definitions {
+	return registry.All()
+}
+
 func IsValidStatus(s Status) bool {
-	switch s {
-	case Planned, InProgress, Completed, Canceled:
-		return true
-	default:
-		return false
-	}
+	_, ok := registry.Get(s)
+	return ok
+}
+
+// IsTerminal reports whether no further work is expected on a todo in this
+// status.
+func IsTerminal(s Status) bool {
+	d, ok := registry.Get(s)
+	return ok && d.Terminal
 }
@@ -0,0 +1,53 @@
+package tag
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	MaxNameLength        = 64
+	MaxDescriptionLength = 500
+	DefaultLimit         = 50
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type Tag struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+	UsageCount  int    `json:"usage_count"`
+}
+
+type Tags []*Tag
+
+// ValidateName checks a tag name. Commas are rejected because the tags query
+// parameter is comma separated.
+func ValidateName(name string) error {
+	if name == "" || strings.TrimSpace(name) != name {
+		return fmt.Errorf("invalid tag name %q", name)
+	}
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("tag name must be at most %d characters", MaxNameLength)
+	}
+	if strings.Contains(name, ",") {
+		return errors.New("tag name must not contain commas")
+	}
+	return nil
+}
+
+func (t *Tag) Validate() error {
+	if err := ValidateName(t.Name); err != nil {
+		return err
+	}
+	if t.Color != "" && !colorPattern.MatchString(t.Color) {
+		return fmt.Errorf("invalid value field \"Color\": %s, expected #RRGGBB", t.Color)
+	}
+	if len(t.Description) > MaxDescriptionLength {
+		return fmt.Errorf("tag description must be at most %d characters", MaxDescriptionLength)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/GlebMoskalev/todo-api/internal/models/todo"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MaxItems = 100
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	dueOffsetPattern   = regexp.MustCompile(`^\+(\d{1,4})([hdw])$`)
+)
+
+// Template describes a todo and its child items. Title, description and tags
+// may contain {{name}} placeholders that are filled in on instantiation.
+type Template struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Tags        []string          `json:"tags"`
+	Priority    priority.Priority `json:"priority"`
+	DueOffset   DueOffset         `json:"due_offset"`
+	Items       []*Item           `json:"items"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+type Templates []*Template
+
+// Item is a child todo of a template. An empty priority inherits the
+// template's.
+type Item struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Tags        []string          `json:"tags"`
+	Priority    priority.Priority `json:"priority"`
+	DueOffset   DueOffset         `json:"due_offset"`
+}
+
+// DueOffset is a due date relative to instantiation: "+3d" and "+2w" give
+// an all-day date, "+4h" a precise time. Empty means no due date.
+type DueOffset string
+
+func (o DueOffset) Validate() error {
+	if o != "" && !dueOffsetPattern.MatchString(string(o)) {
+		return fmt.Errorf("invalid due offset %q, expected e.g. +4h, +3d or +2w", o)
+	}
+	return nil
+}
+
+// Apply computes the due date relative to now. Day offsets count calendar
+// days in now's location.
+func (o DueOffset) Apply(now time.Time) todo.NullTime {
+	match := dueOffsetPattern.FindStringSubmatch(string(o))
+	if match == nil {
+		return todo.NullTime{}
+	}
+	n, _ := strconv.Atoi(match[1])
+	switch match[2] {
+	case "h":
+		return todo.NullTime{Time: now.Add(time.Duration(n) * time.Hour), Valid: true, HasTime: true}
+	case "w":
+		n *= 7
+	}
+	year, month, day := now.Date()
+	return todo.NullTime{Time: time.Date(year, month, day+n, 0, 0, 0, 0, time.UTC), Valid: true}
+}
+
+func (t *Template) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return errors.New("template name must not be empty")
+	}
+	if strings.TrimSpace(t.Title) == "" {
+		return errors.New("template title must not be empty")
+	}
+	if !priority.IsValidPriority(t.Priority) {
+		return fmt.Errorf("invalid value field \"Priority\": %s", t.Priority)
+	}
+	if err := t.DueOffset.Validate(); err != nil {
+		return err
+	}
+	if len(t.Items) > MaxItems {
+		return fmt.Errorf("a template can have at most %d items", MaxItems)
+	}
+	for i, item := range t.Items {
+		if item == nil || strings.TrimSpace(item.Title) == "" {
+			return fmt.Errorf("item %d: title must not be empty", i)
+		}
+		if item.Priority != "" && !priority.IsValidPriority(item.Priority) {
+			return fmt.Errorf("item %d: invalid value field \"Priority\": %s", i, item.Priority)
+		}
+		if err := item.DueOffset.Validate(); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Instantiate renders the template into todos: the main todo first, followed
+// by one todo per item, which are stored as its children. Every placeholder
+// must have a variable.
+func (t *Template) Instantiate(variables map[string]string, now time.Time) (todo.Todos, error) {
+	var missing []string
+	render := func(text string) string {
+		return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+			value, ok := variables[name]
+			if !ok && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return value
+		})
+	}
+	renderTags := func(tags []string) []string {
+		rendered := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			rendered = append(rendered, render(tag))
+		}
+		return rendered
+	}
+
+	todos := todo.Todos{{
+		Title:       render(t.Title),
+		Description: render(t.Description),
+		Tags:        renderTags(t.Tags),
+		Priority:    t.Priority,
+		Status:      status.Planned,
+		DueDate:     t.DueOffset.Apply(now),
+	}}
+	for _, item := range t.Items {
+		itemPriority := item.Priority
+		if itemPriority == "" {
+			itemPriority = t.Priority
+		}
+		todos = append(todos, &todo.Todo{
+			Title:       render(item.Title),
+			Description: render(item.Description),
+			Tags:        renderTags(item.Tags),
+			Priority:    itemPriority,
+			Status:      status.Planned,
+			DueDate:     item.DueOffset.Apply(now),
+		})
+	}
+
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return nil, fmt.Errorf("missing variables: %s", strings.Join(missing, ", "))
+	}
+	return todos, nil
+}
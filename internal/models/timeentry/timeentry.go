@@ -0,0 +1,69 @@
+package timeentry
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const MaxNoteLength = 1000
+
+type GroupBy string
+
+const (
+	GroupByTag    GroupBy = "tag"
+	GroupByStatus GroupBy = "status"
+	GroupByDate   GroupBy = "date"
+)
+
+func IsValidGroupBy(g GroupBy) bool {
+	switch g {
+	case GroupByTag, GroupByStatus, GroupByDate:
+		return true
+	default:
+		return false
+	}
+}
+
+// TimeEntry is a span of work on a todo. A running timer is an entry whose
+// EndedAt is still nil.
+type TimeEntry struct {
+	ID              int        `json:"id"`
+	TodoID          int        `json:"todo_id"`
+	UserID          int        `json:"user_id"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	Note            string     `json:"note"`
+}
+
+type TimeEntries []*TimeEntry
+
+func (e *TimeEntry) Validate() error {
+	if e.StartedAt.IsZero() {
+		return errors.New("started_at is required")
+	}
+	if e.EndedAt == nil {
+		return errors.New("ended_at is required")
+	}
+	if e.EndedAt.Before(e.StartedAt) {
+		return errors.New("ended_at must not be before started_at")
+	}
+	if len(e.Note) > MaxNoteLength {
+		return fmt.Errorf("note must be at most %d characters", MaxNoteLength)
+	}
+	return nil
+}
+
+type ReportRow struct {
+	Key     string `json:"key"`
+	Seconds int64  `json:"seconds"`
+}
+
+type Report struct {
+	GroupBy      GroupBy      `json:"group_by"`
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	TotalSeconds int64        `json:"total_seconds"`
+	Rows         []*ReportRow `json:"rows"`
+}
@@ -0,0 +1,100 @@
+package todo
+
+import (
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/GlebMoskalev/todo-api/internal/models/tag"
+	"github.com/GlebMoskalev/todo-api/internal/validation"
+	"slices"
+)
+
+const (
+	// MaxBulkSize is the largest number of todos one bulk update may touch.
+	MaxBulkSize = 1000
+	// MaxBatchSize is the largest number of todos one batch may create.
+	MaxBatchSize = 50000
+)
+
+// BulkUpdate lists the changes a bulk update applies to every targeted todo.
+// Zero values leave the field alone. DueShiftDays moves due dates as
+// Todo.ShiftDueDate does.
+type BulkUpdate struct {
+	Status       status.Status     `json:"status"`
+	Priority     priority.Priority `json:"priority"`
+	AddTags      []string          `json:"add_tags"`
+	RemoveTags   []string          `json:"remove_tags"`
+	DueShiftDays int               `json:"due_shift_days"`
+}
+
+func (u *BulkUpdate) Validate() error {
+	var errs validation.Errors
+	if u.Status == "" && u.Priority == "" && len(u.AddTags) == 0 && len(u.RemoveTags) == 0 && u.DueShiftDays == 0 {
+		errs.Add("update", "must change at least one field")
+	}
+	if u.Status != "" && !status.IsValidStatus(u.Status) {
+		errs.Add("update.status", "invalid value %q", u.Status)
+	}
+	if u.Priority != "" && !priority.IsValidPriority(u.Priority) {
+		errs.Add("update.priority", "invalid value %q", u.Priority)
+	}
+	u.AddTags = NormalizeTags(u.AddTags)
+	for i, name := range u.AddTags {
+		if err := tag.ValidateName(name); err != nil {
+			errs.Add(fmt.Sprintf("update.add_tags[%d]", i), "%s", err)
+		}
+	}
+	u.RemoveTags = NormalizeTags(u.RemoveTags)
+	return errs.Err()
+}
+
+// Apply changes t in place. Tags are removed before new ones are added.
+func (u *BulkUpdate) Apply(t *Todo) {
+	if u.Status != "" {
+		t.Status = u.Status
+	}
+	if u.Priority != "" {
+		t.Priority = u.Priority
+	}
+	if len(u.RemoveTags) > 0 {
+		t.Tags = slices.DeleteFunc(t.Tags, func(name string) bool {
+			return slices.Contains(u.RemoveTags, name)
+		})
+	}
+	t.Tags = NormalizeTags(append(t.Tags, u.AddTags...))
+	t.ShiftDueDate(u.DueShiftDays)
+}
+
+// BulkOptions controls how a bulk update commits. Atomic updates roll back
+// entirely when any todo fails; otherwise each todo succeeds or fails on its
+// own. A dry run reports what would happen and commits nothing.
+type BulkOptions struct {
+	Atomic bool
+	DryRun bool
+}
+
+// The outcomes of one todo in a bulk update. When an atomic update fails,
+// the todos before the failure are rolled back and those after it are not
+// attempted.
+const (
+	BulkUpdated      = "updated"
+	BulkNotFound     = "not_found"
+	BulkFailed       = "failed"
+	BulkRolledBack   = "rolled_back"
+	BulkNotAttempted = "not_attempted"
+)
+
+type BulkItemResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkResult reports the outcome of a bulk update for every targeted todo.
+type BulkResult struct {
+	Matched int              `json:"matched"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	DryRun  bool             `json:"dry_run"`
+	Items   []BulkItemResult `json:"items"`
+}
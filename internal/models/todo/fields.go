@@ -0,0 +1,101 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Fields lists the JSON fields of a todo that a fieldset may select.
+var Fields = []string{
+	"id", "title", "description", "due_date", "time_zone", "defer_until", "rank", "archived_at", "tags", "priority",
+	"status", "overdue", "project_id", "parent_id", "assignee_id", "created_by", "estimate_seconds", "logged_seconds",
+	"comments_count", "custom_fields", "version", "updated_at",
+}
+
+// Fieldset selects the fields of the todos in a response. The id is always
+// kept; a nil Fieldset keeps every field.
+type Fieldset []string
+
+// ParseFieldset parses a comma separated list of fields such as
+// "id,title,due_date".
+func ParseFieldset(raw string) (Fieldset, error) {
+	fieldset := Fieldset{"id"}
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || slices.Contains(fieldset, field) {
+			continue
+		}
+		if !slices.Contains(Fields, field) {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(Fields, ", "))
+		}
+		fieldset = append(fieldset, field)
+	}
+	return fieldset, nil
+}
+
+func (f Fieldset) Has(field string) bool {
+	return f == nil || slices.Contains(f, field)
+}
+
+// Related data that can be embedded in todos.
+const (
+	IncludeProject     = "project"
+	IncludeAssignee    = "assignee"
+	IncludeComments    = "comments"
+	IncludeAttachments = "attachments"
+)
+
+var includes = []string{IncludeProject, IncludeAssignee, IncludeComments, IncludeAttachments}
+
+// ParseIncludes parses a comma separated list of related data to embed such
+// as "project,comments".
+func ParseIncludes(raw string) ([]string, error) {
+	var parsed []string
+	for _, include := range strings.Split(raw, ",") {
+		include = strings.TrimSpace(include)
+		if include == "" || slices.Contains(parsed, include) {
+			continue
+		}
+		if !slices.Contains(includes, include) {
+			return nil, fmt.Errorf("unknown include %q, expected one of %s", include, strings.Join(includes, ", "))
+		}
+		parsed = append(parsed, include)
+	}
+	return parsed, nil
+}
+
+// Select encodes t with only the fields in f and the embedded data in
+// include, in the order they are asked for.
+func (t *Todo) Select(f Fieldset, include []string) (json.RawMessage, error) {
+	encoded, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return encoded, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	var selected bytes.Buffer
+	selected.WriteByte('{')
+	for _, field := range append(slices.Clone(f), include...) {
+		value, ok := fields[field]
+		if !ok {
+			continue
+		}
+		if selected.Len() > 1 {
+			selected.WriteByte(',')
+		}
+		name, _ := json.Marshal(field)
+		selected.Write(name)
+		selected.WriteByte(':')
+		selected.Write(value)
+	}
+	selected.WriteByte('}')
+	return selected.Bytes(), nil
+}
@@ -1,67 +1,238 @@
 package todo
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/attachment"
+	"github.com/GlebMoskalev/todo-api/internal/models/comment"
+	"github.com/GlebMoskalev/todo-api/internal/models/customfield"
 	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/project"
 	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/GlebMoskalev/todo-api/internal/models/tag"
+	"github.com/GlebMoskalev/todo-api/internal/models/user"
+	"github.com/GlebMoskalev/todo-api/internal/validation"
+	"math"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
 )
 
-type NullTime sql.NullTime
+// NullTime is an optional due date. By default it is an all-day date and
+// only the calendar date of Time matters; with HasTime it is a precise
+// instant.
+type NullTime struct {
+	Time    time.Time
+	Valid   bool
+	HasTime bool
+}
 
+// UnmarshalJSON accepts a YYYY-MM-DD date for all-day values and an RFC 3339
+// datetime for precise ones.
 func (nt *NullTime) UnmarshalJSON(data []byte) error {
 	if string(data) == "null" {
-		nt.Valid = false
+		*nt = NullTime{}
 		return nil
 	}
-	errInvalidDueDate := errors.New("invalid due_date format, expected YYYY-MM-DD")
+	errInvalidDueDate := errors.New("invalid due_date format, expected YYYY-MM-DD or RFC 3339 datetime")
 	var str string
 	if err := json.Unmarshal(data, &str); err != nil {
 		return errInvalidDueDate
 	}
 	if str == "" {
-		nt.Valid = false
+		*nt = NullTime{}
+		return nil
+	}
+	if parsedTime, err := time.Parse(time.DateOnly, str); err == nil {
+		*nt = NullTime{Time: parsedTime, Valid: true}
 		return nil
 	}
-	parsedTime, err := time.Parse(time.DateOnly, str)
+	parsedTime, err := time.Parse(time.RFC3339, str)
 	if err != nil {
 		return errInvalidDueDate
 	}
-	nt.Valid = true
-	nt.Time = parsedTime
+	*nt = NullTime{Time: parsedTime, Valid: true, HasTime: true}
 	return nil
 }
+
 func (nt *NullTime) MarshalJSON() ([]byte, error) {
 	if !nt.Valid {
 		return []byte("null"), nil
 	}
+	if nt.HasTime {
+		return json.Marshal(nt.Time.Format(time.RFC3339))
+	}
 	return json.Marshal(nt.Time.Format(time.DateOnly))
 }
 
 type Todo struct {
-	ID          int               `json:"id"`
-	Title       string            `json:"title"`
-	Description string            `json:"description"`
-	DueDate     NullTime          `json:"due_date"`
-	Tags        []string          `json:"tags"`
-	Priority    priority.Priority `json:"priority"`
-	Status      status.Status     `json:"status"`
-	Overdue     bool              `json:"overdue"`
+	ID              int                    `json:"id"`
+	Title           string                 `json:"title"`
+	Description     string                 `json:"description"`
+	DueDate         NullTime               `json:"due_date"`
+	TimeZone        string                 `json:"time_zone,omitempty"`
+	DeferUntil      *time.Time             `json:"defer_until"`
+	Rank            string                 `json:"rank"`
+	ArchivedAt      *time.Time             `json:"archived_at"`
+	Tags            []string               `json:"tags"`
+	Priority        priority.Priority      `json:"priority"`
+	Status          status.Status          `json:"status"`
+	Overdue         bool                   `json:"overdue"`
+	ProjectID       *int                   `json:"project_id"`
+	AssigneeID      *int                   `json:"assignee_id"`
+	CreatedBy       *int                   `json:"created_by"`
+	EstimateSeconds *int64                 `json:"estimate_seconds"`
+	LoggedSeconds   int64                  `json:"logged_seconds"`
+	CommentsCount   int                    `json:"comments_count"`
+	CustomFields    customfield.Values     `json:"custom_fields"`
+	Attachments     attachment.Attachments `json:"attachments,omitempty"`
+	// ParentID is the todo an item instantiated from a template belongs to.
+	// Only instantiation sets it.
+	ParentID *int `json:"parent_id"`
+	// Project, Assignee and Comments are only set when a listing asks to
+	// embed them.
+	Project  *project.Project `json:"project,omitempty"`
+	Assignee *user.User       `json:"assignee,omitempty"`
+	Comments comment.Comments `json:"comments,omitempty"`
+	// Version grows with every change to the todo, including its comments,
+	// attachments and time entries; UpdatedAt is the time of the last one.
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Todos []*Todo
 
+// Limits on the size of a todo enforced by Validate.
+const (
+	MaxTitleLength       = 500
+	MaxDescriptionLength = 10000
+	MaxTags              = 50
+)
+
+// Validate normalizes the tags of the todo and checks every field, returning
+// a validation.Errors listing all problems found.
 func (t *Todo) Validate() error {
+	var errs validation.Errors
+	if strings.TrimSpace(t.Title) == "" {
+		errs.Add("title", "must not be empty")
+	} else if utf8.RuneCountInString(t.Title) > MaxTitleLength {
+		errs.Add("title", "must be at most %d characters", MaxTitleLength)
+	}
+	if utf8.RuneCountInString(t.Description) > MaxDescriptionLength {
+		errs.Add("description", "must be at most %d characters", MaxDescriptionLength)
+	}
+	t.Tags = NormalizeTags(t.Tags)
+	if len(t.Tags) > MaxTags {
+		errs.Add("tags", "must contain at most %d tags", MaxTags)
+	}
+	for i, name := range t.Tags {
+		if err := tag.ValidateName(name); err != nil {
+			errs.Add(fmt.Sprintf("tags[%d]", i), "%s", err)
+		}
+	}
 	if !status.IsValidStatus(t.Status) {
-		return fmt.Errorf("invalid value field \"Status\": %s", t.Status)
+		errs.Add("status", "invalid value %q", t.Status)
 	}
 	if !priority.IsValidPriority(t.Priority) {
-		return fmt.Errorf("invalid value field \"Priority\": %s", t.Priority)
+		errs.Add("priority", "invalid value %q", t.Priority)
 	}
-	return nil
+	if t.EstimateSeconds != nil && *t.EstimateSeconds < 0 {
+		errs.Add("estimate_seconds", "must not be negative")
+	}
+	if _, err := t.Location(); err != nil {
+		errs.Add("time_zone", "invalid value %q", t.TimeZone)
+	}
+	return errs.Err()
+}
+
+// NormalizeTags trims the tags, drops empty ones and removes duplicates,
+// keeping the first occurrence of each.
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, name := range tags {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	return normalized
+}
+
+// Location returns the IANA time zone of the todo, UTC when none is set.
+// Timed due dates are rendered in it and their calendar date is taken there.
+func (t *Todo) Location() (*time.Location, error) {
+	switch t.TimeZone {
+	case "":
+		return time.UTC, nil
+	case "Local":
+		return nil, errors.New("the server's local time zone is not allowed")
+	}
+	return time.LoadLocation(t.TimeZone)
+}
+
+// ParseSnoozeDuration parses a relative snooze such as "90m", "2h", "3d" or
+// "1w". Days and weeks are added as 24 and 168 hours.
+func ParseSnoozeDuration(s string) (time.Duration, error) {
+	errInvalidDuration := fmt.Errorf("invalid duration %q, expected e.g. 90m, 2h, 3d or 1w", s)
+	var duration time.Duration
+	count, unit := s, time.Duration(0)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		count, unit = days, 24*time.Hour
+	} else if weeks, ok := strings.CutSuffix(s, "w"); ok {
+		count, unit = weeks, 7*24*time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.ParseInt(count, 10, 64)
+		if err != nil || n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+			return 0, errInvalidDuration
+		}
+		duration = time.Duration(n) * unit
+	} else {
+		var err error
+		duration, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, errInvalidDuration
+		}
+	}
+	if duration <= 0 {
+		return 0, errors.New("duration must be positive")
+	}
+	return duration, nil
+}
+
+// ShiftDueDate moves the due date by whole calendar days. Timed due dates keep
+// their time of day in the todo's time zone, also across DST changes.
+func (t *Todo) ShiftDueDate(days int) {
+	if !t.DueDate.Valid || days == 0 {
+		return
+	}
+	location := time.UTC
+	if t.DueDate.HasTime {
+		if todoLocation, err := t.Location(); err == nil {
+			location = todoLocation
+		}
+	}
+	t.DueDate.Time = t.DueDate.Time.In(location).AddDate(0, 0, days)
+}
+
+// DuplicateOptions controls which parts of a todo a duplicate copies.
+// DueShiftDays moves the due date as ShiftDueDate does.
+type DuplicateOptions struct {
+	Tags         bool
+	Description  bool
+	Attachments  bool
+	ResetStatus  bool
+	DueShiftDays int
+	CreatedBy    *int
 }
 
 func BoolPtr(b bool) *bool {
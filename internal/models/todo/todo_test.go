@@ -0,0 +1,100 @@
+package todo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseSnoozeDuration(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{input: "90m", expected: 90 * time.Minute},
+		{input: "3d", expected: 72 * time.Hour},
+		{input: "1w", expected: 168 * time.Hour},
+		{input: "0d", wantErr: true},
+		{input: "-1w", wantErr: true},
+		{input: "soon", wantErr: true},
+		{input: "106751d", expected: 106751 * 24 * time.Hour},
+		{input: "106752d", wantErr: true},
+		{input: "213504d", wantErr: true},
+		{input: "15250w", expected: 15250 * 7 * 24 * time.Hour},
+		{input: "15251w", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			duration, err := ParseSnoozeDuration(tc.input)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, duration)
+		})
+	}
+}
+
+func TestShiftDueDate(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	testCases := []struct {
+		name     string
+		todo     Todo
+		days     int
+		expected time.Time
+	}{
+		{
+			name:     "all-day date",
+			todo:     Todo{DueDate: NullTime{Time: time.Date(2025, 3, 29, 0, 0, 0, 0, time.UTC), Valid: true}},
+			days:     2,
+			expected: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "timed date across the start of DST",
+			todo: Todo{
+				DueDate:  NullTime{Time: time.Date(2025, 3, 29, 8, 0, 0, 0, time.UTC), Valid: true, HasTime: true},
+				TimeZone: "Europe/Berlin",
+			},
+			days:     1,
+			expected: time.Date(2025, 3, 30, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "timed date back across the end of DST",
+			todo: Todo{
+				DueDate:  NullTime{Time: time.Date(2025, 10, 27, 8, 0, 0, 0, time.UTC), Valid: true, HasTime: true},
+				TimeZone: "Europe/Berlin",
+			},
+			days:     -1,
+			expected: time.Date(2025, 10, 26, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:     "no due date",
+			todo:     Todo{},
+			days:     1,
+			expected: time.Time{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.todo.ShiftDueDate(tc.days)
+			assert.True(t, tc.expected.Equal(tc.todo.DueDate.Time), "expected %s, got %s", tc.expected, tc.todo.DueDate.Time)
+		})
+	}
+}
+
+func TestBulkUpdateApplyShiftsInTimeZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	shifted := Todo{
+		DueDate:  NullTime{Time: time.Date(2025, 3, 29, 8, 0, 0, 0, time.UTC), Valid: true, HasTime: true},
+		TimeZone: "Europe/Berlin",
+	}
+	update := BulkUpdate{DueShiftDays: 1}
+	update.Apply(&shifted)
+	assert.True(t, time.Date(2025, 3, 30, 9, 0, 0, 0, berlin).Equal(shifted.DueDate.Time), "got %s", shifted.DueDate.Time)
+}
@@ -0,0 +1,37 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const MaxNameLength = 200
+
+type User struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Users []*User
+
+// Validate checks the user and lower-cases the email, which is unique
+// regardless of case.
+func (u *User) Validate() error {
+	if strings.TrimSpace(u.Name) == "" {
+		return errors.New("user name must not be empty")
+	}
+	if len(u.Name) > MaxNameLength {
+		return fmt.Errorf("user name must be at most %d characters", MaxNameLength)
+	}
+	address, err := mail.ParseAddress(u.Email)
+	if err != nil || address.Address != u.Email {
+		return fmt.Errorf("invalid value field \"Email\": %s", u.Email)
+	}
+	u.Email = strings.ToLower(u.Email)
+	return nil
+}
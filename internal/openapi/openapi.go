@@ -0,0 +1,170 @@
+// Package openapi publishes the OpenAPI document of the todo routes and
+// validates traffic against it.
+package openapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"net/http"
+	"strings"
+)
+
+//go:generate curl -fsSL -o swagger-ui/swagger-ui.css https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui.css
+//go:generate curl -fsSL -o swagger-ui/swagger-ui-bundle.js https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui-bundle.js
+//go:generate curl -fsSL -o swagger-ui/LICENSE https://unpkg.com/swagger-ui-dist@5.17.14/LICENSE
+
+var (
+	//go:embed openapi.json
+	specJSON []byte
+	//go:embed docs.html
+	docsHTML []byte
+	// swaggerUI holds the vendored Swagger UI release; see swagger-ui/README.md.
+	//go:embed swagger-ui
+	swaggerUI embed.FS
+)
+
+// docsAssets are the files of swaggerUI the docs page loads.
+var docsAssets = []string{"swagger-ui.css", "swagger-ui-bundle.js"}
+
+// document is the parsed spec. It is shared and must not be modified; see
+// Document for a copy with the runtime enums filled in.
+var document = mustParse(specJSON)
+
+// dynamicEnums fills the enums of schemas whose values are configured at
+// runtime rather than in the spec file.
+var dynamicEnums = map[string]func() []any{
+	"#/components/schemas/Priority": func() []any {
+		var values []any
+		for _, definition := range priority.All() {
+			values = append(values, string(definition.Value))
+		}
+		return values
+	},
+	"#/components/schemas/Status": func() []any {
+		var values []any
+		for _, definition := range status.All() {
+			values = append(values, string(definition.Value))
+		}
+		return values
+	},
+}
+
+func mustParse(data []byte) map[string]any {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		panic("openapi: invalid embedded spec: " + err.Error())
+	}
+	return doc
+}
+
+// Document returns the spec with the currently configured priorities and
+// statuses.
+func Document() map[string]any {
+	doc := document
+	for ref, values := range dynamicEnums {
+		doc = withValue(doc, refPath(ref), "enum", values())
+	}
+	return doc
+}
+
+// withValue returns a copy of node with key set on the object at path,
+// copying only the maps along the path.
+func withValue(node map[string]any, path []string, key string, value any) map[string]any {
+	copied := make(map[string]any, len(node))
+	for k, v := range node {
+		copied[k] = v
+	}
+	if len(path) == 0 {
+		copied[key] = value
+		return copied
+	}
+	child, _ := node[path[0]].(map[string]any)
+	copied[path[0]] = withValue(child, path[1:], key, value)
+	return copied
+}
+
+// lookup resolves a local reference such as #/components/schemas/Todo.
+func lookup(ref string) map[string]any {
+	node := document
+	for _, segment := range refPath(ref) {
+		node, _ = node[segment].(map[string]any)
+	}
+	if fill, ok := dynamicEnums[ref]; ok {
+		node = withValue(node, nil, "enum", fill())
+	}
+	return node
+}
+
+func refPath(ref string) []string {
+	return strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+}
+
+// resolve follows $ref until it reaches an inline object.
+func resolve(node map[string]any) map[string]any {
+	for node != nil {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			break
+		}
+		node = lookup(ref)
+	}
+	return node
+}
+
+// SpecHandler serves the spec as JSON.
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	jsonSpec, err := json.Marshal(Document())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonSpec)
+}
+
+// docsPolicy lets the docs page load scripts and styles only from this
+// server, its one inline script and the spec itself.
+const docsPolicy = "default-src 'none'; " +
+	"script-src 'self' 'sha256-%s'; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; connect-src 'self'"
+
+var docsContentSecurityPolicy = fmt.Sprintf(docsPolicy, inlineScriptHash(docsHTML))
+
+// inlineScriptHash returns the base64 SHA-256 of the last inline script of
+// page, as a Content-Security-Policy source expects it.
+func inlineScriptHash(page []byte) string {
+	start := bytes.LastIndex(page, []byte("<script>"))
+	end := bytes.LastIndex(page, []byte("</script>"))
+	if start < 0 || end < start {
+		panic("openapi: docs page has no inline script")
+	}
+	sum := sha256.Sum256(page[start+len("<script>") : end])
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// DocsHandler serves a page rendering the spec with Swagger UI.
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Security-Policy", docsContentSecurityPolicy)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsHTML)
+}
+
+// DocsAssetHandler serves the Swagger UI files of the docs page under /docs/.
+func DocsAssetHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/docs/")
+	for _, asset := range docsAssets {
+		if name == asset {
+			http.ServeFileFS(w, r, swaggerUI, "swagger-ui/"+asset)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
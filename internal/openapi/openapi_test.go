@@ -0,0 +1,238 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/GlebMoskalev/todo-api/internal/apiversion"
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/routes/todoroutes"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSpecCoversTodoRoutes(t *testing.T) {
+	paths := document["paths"].(map[string]any)
+	err := chi.Walk(todoroutes.Routes(nil, nil, apiversion.V1), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path := strings.TrimSuffix("/todo"+route, "/")
+		pathItem, ok := paths[path].(map[string]any)
+		if assert.True(t, ok, "missing path %s", path) {
+			assert.Contains(t, pathItem, strings.ToLower(method), "missing operation %s %s", method, path)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestReferencesResolve(t *testing.T) {
+	var walk func(node any)
+	walk = func(node any) {
+		switch v := node.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				assert.NotNil(t, lookup(ref), "unresolved reference %s", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(document)
+}
+
+func TestDocumentEnums(t *testing.T) {
+	schemas := Document()["components"].(map[string]any)["schemas"].(map[string]any)
+	assert.Contains(t, schemas["Priority"].(map[string]any)["enum"], string(priority.High))
+	assert.Contains(t, schemas["Status"].(map[string]any)["enum"], "planned")
+	assert.NotEmpty(t, lookup("#/components/schemas/Priority")["enum"])
+	// The shared document stays untouched.
+	assert.Empty(t, document["components"].(map[string]any)["schemas"].(map[string]any)["Priority"].(map[string]any)["enum"])
+}
+
+func TestValidateRequests(t *testing.T) {
+	testCases := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+		expectedFields []string
+	}{
+		{
+			name:           "valid todo",
+			method:         http.MethodPost,
+			target:         "/todo",
+			body:           `{"title": "write spec", "priority": "high", "status": "planned", "tags": ["docs"]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "every invalid field",
+			method:         http.MethodPost,
+			target:         "/todo/",
+			body:           `{"title": "", "priority": "someday", "tags": ["a,b"], "estimate_seconds": -1, "foo": 1}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFields: []string{"estimate_seconds", "foo", "priority", "tags[0]", "title"},
+		},
+		{
+			name:           "missing body",
+			method:         http.MethodPost,
+			target:         "/todo",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFields: []string{"body"},
+		},
+		{
+			name:           "optional body",
+			method:         http.MethodPost,
+			target:         "/todo/1/duplicate",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid path parameter",
+			method:         http.MethodGet,
+			target:         "/todo/abc",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFields: []string{"id"},
+		},
+		{
+			name:           "versioned path",
+			method:         http.MethodGet,
+			target:         "/v2/todo/abc",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFields: []string{"id"},
+		},
+		{
+			name:           "invalid query parameters",
+			method:         http.MethodGet,
+			target:         "/todo?limit=ten&archived=maybe&assignee=me",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFields: []string{"archived", "limit"},
+		},
+		{
+			name:           "literal path wins over template",
+			method:         http.MethodPost,
+			target:         "/todo/reassign",
+			body:           `{"ids": [1, 2], "assignee_id": null}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "json patch",
+			method:         http.MethodPatch,
+			target:         "/todo/1",
+			body:           `[{"op": "rename", "path": "/title"}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFields: []string{"[0].op"},
+		},
+		{
+			name:           "route outside the spec",
+			method:         http.MethodPost,
+			target:         "/projects",
+			body:           `{"anything": true}`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	validator := NewValidator(ValidateRequests, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	handler := validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.method == http.MethodPatch {
+				request.Header.Set("Content-Type", "application/json-patch+json")
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			if tc.expectedFields == nil {
+				return
+			}
+			var response struct {
+				Fields []struct {
+					Field string `json:"field"`
+				} `json:"fields"`
+			}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			var fields []string
+			for _, field := range response.Fields {
+				fields = append(fields, field.Field)
+			}
+			assert.ElementsMatch(t, tc.expectedFields, fields)
+		})
+	}
+}
+
+func TestValidateResponses(t *testing.T) {
+	var logs bytes.Buffer
+	validator := NewValidator(ValidateResponses, slog.New(slog.NewTextHandler(&logs, nil)))
+	handler := validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "1", "title": "x"}`))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/todo/1", nil))
+
+	assert.Equal(t, `{"id": "1", "title": "x"}`, recorder.Body.String())
+	assert.Contains(t, logs.String(), "Response does not match the spec")
+	assert.Contains(t, logs.String(), "id: must be of type integer")
+}
+
+func TestValidateResponseAlternatives(t *testing.T) {
+	var logs bytes.Buffer
+	validator := NewValidator(ValidateResponses, slog.New(slog.NewTextHandler(&logs, nil)))
+	body := `{"error": "validation failed", "fields": [{"field": "title", "message": "must not be empty"}]}`
+	handler := validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(body))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/todo/batch", strings.NewReader(`[{"title": "a"}]`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Empty(t, logs.String())
+
+	body = `{"created": 0}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/todo/batch", strings.NewReader(`[{"title": "a"}]`)))
+	assert.Contains(t, logs.String(), "does not match any allowed form")
+}
+
+func TestDocsPage(t *testing.T) {
+	assert.Empty(t, regexp.MustCompile(`https?://[^"]+`).FindAll(docsHTML, -1), "the docs page must load its assets from this server")
+
+	recorder := httptest.NewRecorder()
+	DocsHandler(recorder, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	policy := recorder.Header().Get("Content-Security-Policy")
+	assert.Contains(t, policy, "script-src 'self' 'sha256-")
+	assert.Contains(t, policy, "style-src 'self' 'unsafe-inline'")
+	assert.NotContains(t, policy, "'sha256-'")
+	assert.NotContains(t, policy, "https:")
+
+	recorder = httptest.NewRecorder()
+	DocsAssetHandler(recorder, httptest.NewRequest(http.MethodGet, "/docs/README.md", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestDocsAssets(t *testing.T) {
+	for _, asset := range docsAssets {
+		assert.Contains(t, string(docsHTML), `"docs/`+asset+`"`)
+		if _, err := fs.Stat(swaggerUI, "swagger-ui/"+asset); err != nil {
+			t.Skipf("%s is not vendored yet; run go generate ./internal/openapi", asset)
+		}
+		recorder := httptest.NewRecorder()
+		DocsAssetHandler(recorder, httptest.NewRequest(http.MethodGet, "/docs/"+asset, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, asset)
+		assert.NotEmpty(t, recorder.Body.Bytes(), asset)
+	}
+}
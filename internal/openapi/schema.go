@@ -0,0 +1,207 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/validation"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// validateValue checks value, decoded with json.Decoder.UseNumber, against
+// schema. It covers the subset of JSON Schema the spec uses: $ref, oneOf,
+// type, enum, const, string lengths, pattern, date and date-time formats,
+// minimum, array items and sizes, and object properties.
+func validateValue(schema map[string]any, value any, field string, errs *validation.Errors) {
+	schema = resolve(schema)
+	if schema == nil {
+		return
+	}
+	name := field
+	if name == "" {
+		name = "body"
+	}
+
+	// The alternatives of oneOf never overlap in the spec, so matching any of
+	// them is enough.
+	if alternatives, ok := schema["oneOf"].([]any); ok {
+		if !slices.ContainsFunc(alternatives, func(alternative any) bool {
+			alternativeSchema, _ := alternative.(map[string]any)
+			var alternativeErrs validation.Errors
+			validateValue(alternativeSchema, value, field, &alternativeErrs)
+			return len(alternativeErrs) == 0
+		}) {
+			errs.Add(name, "does not match any allowed form")
+		}
+		return
+	}
+	if types := schemaTypes(schema); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool {
+		return hasType(value, t)
+	}) {
+		errs.Add(name, "must be of type %s", strings.Join(types, " or "))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(candidate any) bool {
+		return sameValue(candidate, value)
+	}) {
+		allowed := make([]string, len(enum))
+		for i, candidate := range enum {
+			allowed[i] = fmt.Sprint(candidate)
+		}
+		errs.Add(name, "must be one of %s", strings.Join(allowed, ", "))
+		return
+	}
+	if constant, ok := schema["const"]; ok && !sameValue(constant, value) {
+		errs.Add(name, "must be %v", constant)
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if minLength, ok := schema["minLength"].(float64); ok && length < int(minLength) {
+			if minLength == 1 {
+				errs.Add(name, "must not be empty")
+			} else {
+				errs.Add(name, "must be at least %d characters", int(minLength))
+			}
+		}
+		if maxLength, ok := schema["maxLength"].(float64); ok && length > int(maxLength) {
+			errs.Add(name, "must be at most %d characters", int(maxLength))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !compilePattern(pattern).MatchString(v) {
+			errs.Add(name, "must match %s", pattern)
+		}
+		switch schema["format"] {
+		case "date":
+			if _, err := time.Parse(time.DateOnly, v); err != nil {
+				errs.Add(name, "must be a date in the form YYYY-MM-DD")
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				errs.Add(name, "must be an RFC 3339 datetime")
+			}
+		}
+	case json.Number:
+		number, _ := v.Float64()
+		if minimum, ok := schema["minimum"].(float64); ok && number < minimum {
+			errs.Add(name, "must be at least %v", minimum)
+		}
+	case []any:
+		if maxItems, ok := schema["maxItems"].(float64); ok && len(v) > int(maxItems) {
+			errs.Add(name, "must contain at most %d items", int(maxItems))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+			}
+		}
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, key := range required {
+			if _, ok := v[key.(string)]; !ok {
+				errs.Add(childField(field, key.(string)), "is required")
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if propertySchema, ok := properties[key].(map[string]any); ok {
+				validateValue(propertySchema, v[key], childField(field, key), errs)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs.Add(childField(field, key), "unknown field")
+				}
+			case map[string]any:
+				validateValue(additional, v[key], childField(field, key), errs)
+			}
+		}
+	}
+}
+
+func childField(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// schemaTypes returns the types a schema allows; OpenAPI 3.1 spells nullable
+// values as a list such as ["string", "null"].
+func schemaTypes(schema map[string]any) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			types = append(types, item.(string))
+		}
+		return types
+	}
+	return nil
+}
+
+func hasType(value any, schemaType string) bool {
+	switch schemaType {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := number.Int64()
+		return err == nil
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+// sameValue compares a value from the spec with one from a request. Numbers
+// in the spec are float64 and in requests json.Number.
+func sameValue(expected, actual any) bool {
+	if number, ok := actual.(json.Number); ok {
+		actualFloat, err := number.Float64()
+		expectedFloat, isFloat := expected.(float64)
+		return err == nil && isFloat && actualFloat == expectedFloat
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+var patterns sync.Map
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if compiled, ok := patterns.Load(pattern); ok {
+		return compiled.(*regexp.Regexp)
+	}
+	compiled := regexp.MustCompile(pattern)
+	patterns.Store(pattern, compiled)
+	return compiled
+}
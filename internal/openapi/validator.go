@@ -0,0 +1,317 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/codec"
+	"github.com/GlebMoskalev/todo-api/internal/validation"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxValidatedBodySize bounds the bodies the Validator reads. Larger bodies
+// are passed on unvalidated for the handler to reject.
+const maxValidatedBodySize = 1 << 20
+
+// Mode selects what a Validator checks.
+type Mode int
+
+const (
+	Off Mode = iota
+	// ValidateRequests rejects requests that don't match the spec.
+	ValidateRequests
+	// ValidateResponses also checks responses and logs every mismatch. It
+	// buffers response bodies and is meant for development.
+	ValidateResponses
+)
+
+// ParseMode parses the OPENAPI_VALIDATION setting: off (or empty), on, or
+// dev to validate responses too.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "off":
+		return Off, nil
+	case "on":
+		return ValidateRequests, nil
+	case "dev":
+		return ValidateResponses, nil
+	}
+	return Off, fmt.Errorf("invalid validation mode %q, expected off, on or dev", s)
+}
+
+// Validator checks requests to the operations in the spec and answers 422
+// with every invalid parameter and body field. Routes missing from the spec
+// pass through untouched.
+type Validator struct {
+	mode   Mode
+	logger *slog.Logger
+}
+
+func NewValidator(mode Mode, logger *slog.Logger) *Validator {
+	return &Validator{mode: mode, logger: logger}
+}
+
+type operation struct {
+	id         string
+	spec       map[string]any
+	parameters []map[string]any
+	pathParams map[string]string
+}
+
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v.mode == Off {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op := findOperation(r.Method, r.URL.Path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if errs := validateRequest(r, op); len(errs) > 0 {
+			v.logger.Debug("Request does not match the spec",
+				slog.String("operation", op.id), slog.String("error", errs.Error()))
+			writeValidationError(w, errs)
+			return
+		}
+		if v.mode < ValidateResponses {
+			next.ServeHTTP(w, r)
+			return
+		}
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		v.checkResponse(op, recorder)
+	})
+}
+
+// findOperation returns the operation for a request, preferring literal path
+// segments over templated ones so that /todo/reassign doesn't match
+// /todo/{id}.
+func findOperation(method, requestPath string) *operation {
+	requestPath = trimServer(requestPath)
+	paths, _ := document["paths"].(map[string]any)
+	requestSegments := strings.Split(strings.TrimSuffix(requestPath, "/"), "/")
+	var best *operation
+	bestTemplated := len(requestSegments) + 1
+	for template, item := range paths {
+		pathItem, _ := item.(map[string]any)
+		spec, ok := pathItem[strings.ToLower(method)].(map[string]any)
+		if !ok {
+			continue
+		}
+		templateSegments := strings.Split(template, "/")
+		if len(templateSegments) != len(requestSegments) {
+			continue
+		}
+		pathParams := make(map[string]string)
+		matched := true
+		for i, segment := range templateSegments {
+			if name, ok := strings.CutPrefix(segment, "{"); ok {
+				pathParams[strings.TrimSuffix(name, "}")] = requestSegments[i]
+			} else if segment != requestSegments[i] {
+				matched = false
+				break
+			}
+		}
+		if !matched || len(pathParams) >= bestTemplated {
+			continue
+		}
+		bestTemplated = len(pathParams)
+		best = &operation{spec: spec, pathParams: pathParams}
+		best.id, _ = spec["operationId"].(string)
+		for _, list := range []any{pathItem["parameters"], spec["parameters"]} {
+			parameters, _ := list.([]any)
+			for _, parameter := range parameters {
+				parameterSpec, _ := parameter.(map[string]any)
+				best.parameters = append(best.parameters, resolve(parameterSpec))
+			}
+		}
+	}
+	return best
+}
+
+// trimServer strips the server URL, such as /v2, from the start of a
+// request path.
+func trimServer(requestPath string) string {
+	servers, _ := document["servers"].([]any)
+	for _, server := range servers {
+		url, _ := server.(map[string]any)["url"].(string)
+		if rest, ok := strings.CutPrefix(requestPath, strings.TrimSuffix(url, "/")); ok && strings.HasPrefix(rest, "/") {
+			return rest
+		}
+	}
+	return requestPath
+}
+
+func validateRequest(r *http.Request, op *operation) validation.Errors {
+	var errs validation.Errors
+	query := r.URL.Query()
+	for _, parameter := range op.parameters {
+		name, _ := parameter["name"].(string)
+		required, _ := parameter["required"].(bool)
+		schema, _ := parameter["schema"].(map[string]any)
+		var values []string
+		switch parameter["in"] {
+		case "path":
+			if value, ok := op.pathParams[name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = query[name]
+		case "header":
+			values = r.Header.Values(name)
+		}
+		if len(values) == 0 {
+			if required {
+				errs.Add(name, "is required")
+			}
+			continue
+		}
+		for _, value := range values {
+			validateValue(schema, parameterValue(schema, value), name, &errs)
+		}
+	}
+
+	requestBody := resolve(mapValue(op.spec, "requestBody"))
+	if requestBody == nil {
+		return errs
+	}
+	mediaType := "application/json"
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ = mime.ParseMediaType(contentType)
+	}
+	media := mapValue(mapValue(requestBody, "content"), mediaType)
+	if format := codec.ForContentType(mediaType); media == nil || format != nil && format != codec.JSON {
+		// Unsupported media types are left to the handler to reject, and
+		// bodies in the other codec formats aren't checked.
+		return errs
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBodySize+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil || len(body) > maxValidatedBodySize {
+		return errs
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if required, _ := requestBody["required"].(bool); required {
+			errs.Add("body", "is required")
+		}
+		return errs
+	}
+	value, err := decode(body)
+	if err != nil {
+		errs.Add("body", "must be valid JSON")
+		return errs
+	}
+	validateValue(mapValue(media, "schema"), value, "", &errs)
+	return errs
+}
+
+// parameterValue converts a raw parameter to the JSON value its schema
+// expects, leaving it a string when it doesn't parse so validation reports
+// the type.
+func parameterValue(schema map[string]any, raw string) any {
+	types := schemaTypes(resolve(schema))
+	if slices.Contains(types, "integer") || slices.Contains(types, "number") {
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	}
+	if slices.Contains(types, "boolean") {
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+// checkResponse logs responses whose status isn't documented or whose JSON
+// body doesn't match the spec.
+func (v *Validator) checkResponse(op *operation, recorder *responseRecorder) {
+	responses := mapValue(op.spec, "responses")
+	response := mapValue(responses, strconv.Itoa(recorder.status))
+	if response == nil {
+		response = mapValue(responses, "default")
+	}
+	if response == nil {
+		v.logger.Error("Undocumented response status",
+			slog.String("operation", op.id), slog.Int("status", recorder.status))
+		return
+	}
+	media := mapValue(mapValue(resolve(response), "content"), "application/json")
+	if media == nil {
+		return
+	}
+	// Responses converted to another format aren't checked.
+	if format := codec.ForContentType(recorder.Header().Get("Content-Type")); format != nil && format != codec.JSON {
+		return
+	}
+	value, err := decode(recorder.body.Bytes())
+	if err != nil {
+		v.logger.Error("Response is not valid JSON",
+			slog.String("operation", op.id), slog.Int("status", recorder.status), slog.String("error", err.Error()))
+		return
+	}
+	var errs validation.Errors
+	validateValue(mapValue(media, "schema"), value, "", &errs)
+	if len(errs) > 0 {
+		v.logger.Error("Response does not match the spec",
+			slog.String("operation", op.id), slog.Int("status", recorder.status), slog.String("error", errs.Error()))
+	}
+}
+
+func mapValue(node map[string]any, key string) map[string]any {
+	value, _ := node[key].(map[string]any)
+	return value
+}
+
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func writeValidationError(w http.ResponseWriter, errs validation.Errors) {
+	jsonBody, err := json.Marshal(map[string]any{"error": "validation failed", "fields": errs})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write(jsonBody)
+}
+
+// responseRecorder passes a response through while keeping a copy of its
+// status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,162 @@
+// Package rank generates fractional index keys for manual ordering.
+//
+// A key is a base-62 fraction written with the digits 0-9A-Za-z, so keys
+// compare correctly as plain byte strings. Keys never end in '0', which
+// guarantees there is always room for another key between two of them.
+package rank
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// MaxLength is the key length beyond which callers should rebalance.
+const MaxLength = 32
+
+var ErrInvalidKey = errors.New("invalid rank key")
+
+func Validate(key string) error {
+	if key == "" || key[len(key)-1] == '0' {
+		return ErrInvalidKey
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// Between returns a key that sorts strictly between lower and upper. An empty
+// lower means "before everything", an empty upper "after everything".
+// Appending after the last key increments it instead of halving the
+// remaining space, so the keys of a growing list stay short.
+func Between(lower, upper string) (string, error) {
+	for _, key := range []string{lower, upper} {
+		if key != "" {
+			if err := Validate(key); err != nil {
+				return "", fmt.Errorf("%w: %q", err, key)
+			}
+		}
+	}
+	if lower != "" && upper != "" && lower >= upper {
+		return "", fmt.Errorf("rank %q is not before %q", lower, upper)
+	}
+
+	switch {
+	case lower == "" && upper == "":
+		return digits[base/2 : base/2+1], nil
+	case upper == "":
+		return increment(lower), nil
+	case lower == "":
+		return decrement(upper), nil
+	default:
+		return midpoint(lower, upper), nil
+	}
+}
+
+// Spread returns n keys evenly spaced over the whole key space, leaving room
+// for moves between every pair of neighbours.
+func Spread(n int) []string {
+	width := 1
+	space := uint64(base)
+	for space/uint64(n+1) < uint64(base) {
+		width++
+		space *= uint64(base)
+	}
+	step := space / uint64(n+1)
+
+	keys := make([]string, n)
+	for i := range keys {
+		value := uint64(i+1) * step
+		key := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			key[j] = digits[value%uint64(base)]
+			value /= uint64(base)
+		}
+		keys[i] = strings.TrimRight(string(key), "0")
+	}
+	return keys
+}
+
+// Append returns n ascending keys that sort after last, for adding n items at
+// the end of a list whose last key is last ("" for an empty list). The keys
+// extend last by a few digits, so they stay short however large n is.
+func Append(last string, n int) []string {
+	keys := Spread(n)
+	for i := range keys {
+		keys[i] = last + keys[i]
+	}
+	return keys
+}
+
+func increment(key string) string {
+	for i := 0; i < len(key); i++ {
+		if d := digitValue(key[i]); d < base-1 {
+			return key[:i] + digits[d+1:d+2]
+		}
+	}
+	return key + digits[1:2]
+}
+
+func decrement(key string) string {
+	for i := 0; i < len(key); i++ {
+		if d := digitValue(key[i]); d > 1 {
+			return key[:i] + digits[d-1:d]
+		} else if d == 1 {
+			break
+		}
+	}
+	return midpoint("", key)
+}
+
+// midpoint returns a key between a and b, where a < b and b may be empty for
+// "the end of the key space".
+func midpoint(a, b string) string {
+	if b != "" {
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(suffix(a, n), b[n:])
+		}
+	}
+
+	digitA := digitValue(digitAt(a, 0))
+	digitB := base
+	if b != "" {
+		digitB = digitValue(b[0])
+	}
+	if digitB-digitA > 1 {
+		middle := (digitA + digitB + 1) / 2
+		return digits[middle : middle+1]
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return digits[digitA:digitA+1] + midpoint(suffix(a, 1), "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return digits[0]
+}
+
+func suffix(key string, n int) string {
+	if n >= len(key) {
+		return ""
+	}
+	return key[n:]
+}
+
+func digitValue(c byte) int {
+	return strings.IndexByte(digits, c)
+}
@@ -0,0 +1,111 @@
+package rank
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	testCases := []struct {
+		name          string
+		lower         string
+		upper         string
+		expectedError bool
+	}{
+		{name: "empty list"},
+		{name: "append", lower: "V"},
+		{name: "append after last digit", lower: "zz"},
+		{name: "prepend", upper: "V"},
+		{name: "prepend before smallest digit", upper: "01"},
+		{name: "adjacent digits", lower: "V", upper: "W"},
+		{name: "prefix", lower: "V", upper: "VV"},
+		{name: "long lower", lower: "Vz", upper: "W"},
+		{name: "lower not before upper", lower: "W", upper: "V", expectedError: true},
+		{name: "equal keys", lower: "V", upper: "V", expectedError: true},
+		{name: "trailing zero", lower: "V0", expectedError: true},
+		{name: "invalid character", upper: "V-", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := Between(tc.lower, tc.upper)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, Validate(key))
+			if tc.lower != "" {
+				assert.Less(t, tc.lower, key)
+			}
+			if tc.upper != "" {
+				assert.Less(t, key, tc.upper)
+			}
+		})
+	}
+}
+
+func TestRandomMoves(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for range 1000 {
+		i := random.Intn(len(keys) + 1)
+		var lower, upper string
+		if i > 0 {
+			lower = keys[i-1]
+		}
+		if i < len(keys) {
+			upper = keys[i]
+		}
+		key, err := Between(lower, upper)
+		assert.NoError(t, err)
+		keys = append(keys[:i], append([]string{key}, keys[i:]...)...)
+	}
+	assert.True(t, sort.StringsAreSorted(keys))
+}
+
+func TestAppendKeepsKeysShort(t *testing.T) {
+	key := ""
+	for range 1000 {
+		next, err := Between(key, "")
+		assert.NoError(t, err)
+		assert.Less(t, key, next)
+		key = next
+	}
+	assert.LessOrEqual(t, len(key), 20)
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 61, 62, 5000} {
+		keys := Spread(n)
+		assert.Len(t, keys, n)
+		assert.True(t, sort.StringsAreSorted(keys))
+		for i, key := range keys {
+			assert.NoError(t, Validate(key))
+			if i > 0 {
+				assert.NotEqual(t, keys[i-1], key)
+			}
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	for _, last := range []string{"", "V", "zz1"} {
+		keys := Append(last, 50000)
+		assert.Len(t, keys, 50000)
+		assert.True(t, sort.StringsAreSorted(keys))
+		assert.Less(t, last, keys[0])
+		assert.LessOrEqual(t, len(keys[len(keys)-1]), len(last)+4)
+		for i, key := range keys {
+			assert.NoError(t, Validate(key))
+			if i > 0 {
+				assert.NotEqual(t, keys[i-1], key)
+			}
+		}
+		next, err := Between(keys[len(keys)-1], "")
+		assert.NoError(t, err)
+		assert.Less(t, keys[len(keys)-1], next)
+	}
+}
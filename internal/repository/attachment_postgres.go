@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/attachment"
+	"log/slog"
+)
+
+const attachmentColumns = "id, todo_id, file_name, content_type, size, checksum, storage_key, created_at"
+
+type AttachmentPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewAttachmentPostgresRepository(db *sql.DB, logger *slog.Logger) *AttachmentPostgresRepository {
+	return &AttachmentPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *AttachmentPostgresRepository) Create(a *attachment.Attachment) (int, error) {
+	r.logger.Debug("Attempting to create attachment", slog.Int("todo_id", a.TodoID),
+		slog.String("file_name", a.FileName))
+
+	err := r.db.QueryRow(
+		"INSERT INTO attachments (todo_id, file_name, content_type, size, checksum, storage_key) "+
+			"VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		a.TodoID,
+		a.FileName,
+		a.ContentType,
+		a.Size,
+		a.Checksum,
+		a.StorageKey,
+	).Scan(&a.ID, &a.CreatedAt)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("Todo not found", slog.Int("todo_id", a.TodoID))
+			return 0, ErrRecordNotFound
+		}
+		r.logger.Error("Failed to insert attachment", slog.String("error", err.Error()))
+		return 0, err
+	}
+	a.CreatedAt = a.CreatedAt.UTC()
+
+	r.logger.Debug("Attachment created successfully", slog.Int("ID", a.ID))
+	return a.ID, nil
+}
+
+func (r *AttachmentPostgresRepository) GetById(todoId, id int) (*attachment.Attachment, error) {
+	r.logger.Debug("Fetching attachment by id", slog.Int("ID", id))
+	a, err := scanAttachment(r.db.QueryRow(
+		"SELECT "+attachmentColumns+" FROM attachments WHERE id = $1 AND todo_id = $2",
+		id,
+		todoId,
+	))
+	if err != nil {
+		r.logger.Warn("Record not found", slog.Int("id", id), slog.String("error", err.Error()))
+		return nil, ErrRecordNotFound
+	}
+	return a, nil
+}
+
+func (r *AttachmentPostgresRepository) GetByTodoId(todoId int) (attachment.Attachments, error) {
+	r.logger.Debug("Fetching attachments", slog.Int("todo_id", todoId))
+
+	var exists bool
+	if err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1)", todoId).Scan(&exists); err != nil {
+		r.logger.Error("Failed to check todo", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if !exists {
+		r.logger.Warn("Todo not found", slog.Int("todo_id", todoId))
+		return nil, ErrRecordNotFound
+	}
+
+	attachments, err := getAttachmentsByTodoId(r.db, todoId)
+	if err != nil {
+		r.logger.Error("Failed to fetch attachments", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if attachments == nil {
+		attachments = attachment.Attachments{}
+	}
+	r.logger.Debug("Attachments fetched", slog.Int("count", len(attachments)))
+	return attachments, nil
+}
+
+// Delete removes the attachment metadata and returns the deleted row, and
+// whether it was the last reference to its blob so that the caller can drop
+// the blob from the store. The rows sharing the blob are locked, so a
+// concurrent Duplicate either copies the attachment before the delete and is
+// seen by the check, or runs after it and doesn't copy it.
+func (r *AttachmentPostgresRepository) Delete(todoId, id int) (*attachment.Attachment, bool, error) {
+	r.logger.Debug("Attempting to delete attachment", slog.Int("ID", id))
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return nil, false, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	_, err = tx.Exec(
+		"SELECT id FROM attachments WHERE storage_key = "+
+			"(SELECT storage_key FROM attachments WHERE id = $1 AND todo_id = $2) "+
+			"ORDER BY id FOR UPDATE",
+		id,
+		todoId,
+	)
+	if err != nil {
+		r.logger.Error("Failed to lock attachments", slog.String("error", err.Error()))
+		return nil, false, err
+	}
+	a, err := scanAttachment(tx.QueryRow(
+		"DELETE FROM attachments WHERE id = $1 AND todo_id = $2 RETURNING "+attachmentColumns,
+		id,
+		todoId,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Delete failed: no rows affected", slog.Int("id", id))
+		err = ErrRecordNotFound
+		return nil, false, err
+	}
+	if err != nil {
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return nil, false, err
+	}
+	// A new statement sees duplicates committed while waiting for the lock.
+	var inUse bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM attachments WHERE storage_key = $1)", a.StorageKey).Scan(&inUse)
+	if err != nil {
+		r.logger.Error("Failed to check storage key", slog.String("error", err.Error()))
+		return nil, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return nil, false, err
+	}
+	r.logger.Debug("Attachment deleted successfully", slog.Int("ID", id), slog.Bool("last_reference", !inUse))
+	return a, !inUse, nil
+}
+
+func getAttachmentsByTodoId(q querier, todoId int) (attachment.Attachments, error) {
+	rows, err := q.Query(
+		"SELECT "+attachmentColumns+" FROM attachments WHERE todo_id = $1 ORDER BY created_at, id",
+		todoId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments attachment.Attachments
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func scanAttachment(row rowScanner) (*attachment.Attachment, error) {
+	a := &attachment.Attachment{}
+	err := row.Scan(
+		&a.ID,
+		&a.TodoID,
+		&a.FileName,
+		&a.ContentType,
+		&a.Size,
+		&a.Checksum,
+		&a.StorageKey,
+		&a.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	a.CreatedAt = a.CreatedAt.UTC()
+	return a, nil
+}
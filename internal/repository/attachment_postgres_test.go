@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/attachment"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func createTestAttachment(todoId int, storageKey string) *attachment.Attachment {
+	return &attachment.Attachment{
+		TodoID:      todoId,
+		FileName:    "screenshot.png",
+		ContentType: "image/png",
+		Size:        42,
+		Checksum:    "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		StorageKey:  storageKey,
+	}
+}
+
+func TestCreateAttachment(t *testing.T) {
+	testCases := []struct {
+		name          string
+		todoId        func(todoId int) int
+		expectedError error
+	}{
+		{
+			name:   "successfully create",
+			todoId: func(todoId int) int { return todoId },
+		},
+		{
+			name:          "non-existing todo",
+			todoId:        func(todoId int) int { return 999 },
+			expectedError: ErrRecordNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+			repo := AttachmentPostgresRepository{db: testDb, logger: logger}
+
+			todoId, err := todoRepo.Create(createTestTodo())
+			assert.NoError(t, err)
+
+			a := createTestAttachment(tc.todoId(todoId), "0a1b2c")
+			_, err = repo.Create(a)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			fetched, err := repo.GetById(todoId, a.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, a, fetched)
+
+			fetchedTodo, err := todoRepo.GetById(todoId)
+			assert.NoError(t, err)
+			assert.Equal(t, attachment.Attachments{a}, fetchedTodo.Attachments)
+		})
+	}
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := AttachmentPostgresRepository{db: testDb, logger: logger}
+
+	todoId, err := todoRepo.Create(createTestTodo())
+	assert.NoError(t, err)
+	a := createTestAttachment(todoId, "0a1b2c")
+	_, err = repo.Create(a)
+	assert.NoError(t, err)
+
+	deleted, lastReference, err := repo.Delete(todoId, a.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "0a1b2c", deleted.StorageKey)
+	assert.True(t, lastReference)
+
+	_, _, err = repo.Delete(todoId, a.ID)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	attachments, err := repo.GetByTodoId(todoId)
+	assert.NoError(t, err)
+	assert.Empty(t, attachments)
+}
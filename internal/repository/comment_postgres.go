@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/comment"
+	"log/slog"
+)
+
+type CommentPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewCommentPostgresRepository(db *sql.DB, logger *slog.Logger) *CommentPostgresRepository {
+	return &CommentPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *CommentPostgresRepository) Create(c *comment.Comment) (int, error) {
+	r.logger.Debug("Attempting to create comment", slog.Int("todo_id", c.TodoID))
+	if err := c.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	err := r.db.QueryRow(
+		"INSERT INTO comments (todo_id, author_id, body) VALUES ($1, $2, $3) RETURNING id, created_at",
+		c.TodoID,
+		c.AuthorID,
+		c.Body,
+	).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("Todo not found", slog.Int("todo_id", c.TodoID))
+			return 0, ErrRecordNotFound
+		}
+		r.logger.Error("Failed to insert comment", slog.String("error", err.Error()))
+		return 0, err
+	}
+	c.CreatedAt = c.CreatedAt.UTC()
+	c.EditedAt = nil
+
+	r.logger.Debug("Comment created successfully", slog.Int("ID", c.ID))
+	return c.ID, nil
+}
+
+func (r *CommentPostgresRepository) GetByTodoId(todoId int) (comment.Comments, error) {
+	r.logger.Debug("Fetching comments", slog.Int("todo_id", todoId))
+
+	var exists bool
+	if err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1)", todoId).Scan(&exists); err != nil {
+		r.logger.Error("Failed to check todo", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if !exists {
+		r.logger.Warn("Todo not found", slog.Int("todo_id", todoId))
+		return nil, ErrRecordNotFound
+	}
+
+	rows, err := r.db.Query(
+		"SELECT id, todo_id, author_id, body, created_at, edited_at FROM comments "+
+			"WHERE todo_id = $1 ORDER BY created_at, id",
+		todoId,
+	)
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	comments := comment.Comments{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	r.logger.Debug("Comments fetched", slog.Int("count", len(comments)))
+	return comments, nil
+}
+
+// Update changes the body of a comment. Only the original author is allowed
+// to edit it; anyone else gets ErrForbidden.
+func (r *CommentPostgresRepository) Update(c *comment.Comment) error {
+	r.logger.Debug("Updating comment", slog.Int("ID", c.ID))
+	if err := c.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	if err = checkCommentAuthor(tx, c.ID, c.TodoID, c.AuthorID); err != nil {
+		r.logger.Warn("Comment update rejected", slog.Int("ID", c.ID), slog.String("error", err.Error()))
+		return err
+	}
+
+	var editedAt sql.NullTime
+	err = tx.QueryRow(
+		"UPDATE comments SET body = $1, edited_at = now() WHERE id = $2 RETURNING created_at, edited_at",
+		c.Body,
+		c.ID,
+	).Scan(&c.CreatedAt, &editedAt)
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return err
+	}
+	c.CreatedAt = c.CreatedAt.UTC()
+	editedAtUTC := editedAt.Time.UTC()
+	c.EditedAt = &editedAtUTC
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return err
+	}
+	r.logger.Debug("Comment updated", slog.Int("ID", c.ID))
+	return nil
+}
+
+// Delete removes a comment on behalf of authorId. Only the original author is
+// allowed to delete it; anyone else gets ErrForbidden.
+func (r *CommentPostgresRepository) Delete(todoId, id, authorId int) error {
+	r.logger.Debug("Attempting to delete comment", slog.Int("ID", id))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	if err = checkCommentAuthor(tx, id, todoId, authorId); err != nil {
+		r.logger.Warn("Comment deletion rejected", slog.Int("ID", id), slog.String("error", err.Error()))
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM comments WHERE id = $1", id); err != nil {
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return err
+	}
+	r.logger.Debug("Comment deleted successfully", slog.Int("ID", id))
+	return nil
+}
+
+func checkCommentAuthor(tx *sql.Tx, id, todoId, authorId int) error {
+	var actualAuthorId int
+	err := tx.QueryRow(
+		"SELECT author_id FROM comments WHERE id = $1 AND todo_id = $2 FOR UPDATE",
+		id,
+		todoId,
+	).Scan(&actualAuthorId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if actualAuthorId != authorId {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func scanComment(row rowScanner) (*comment.Comment, error) {
+	c := &comment.Comment{}
+	var editedAt sql.NullTime
+	err := row.Scan(
+		&c.ID,
+		&c.TodoID,
+		&c.AuthorID,
+		&c.Body,
+		&c.CreatedAt,
+		&editedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.CreatedAt = c.CreatedAt.UTC()
+	if editedAt.Valid {
+		editedAtUTC := editedAt.Time.UTC()
+		c.EditedAt = &editedAtUTC
+	}
+	return c, nil
+}
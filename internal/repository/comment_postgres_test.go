@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/comment"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func setupCommentRepositories(t *testing.T) (*TodoPostgresRepository, *CommentPostgresRepository, func()) {
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	return &TodoPostgresRepository{db: testDb, logger: logger},
+		&CommentPostgresRepository{db: testDb, logger: logger},
+		tearDown
+}
+
+func createTestComment(t *testing.T, todoRepo *TodoPostgresRepository, commentRepo *CommentPostgresRepository) *comment.Comment {
+	todoId, err := todoRepo.Create(createTestTodo())
+	assert.NoError(t, err)
+	c := &comment.Comment{TodoID: todoId, AuthorID: 1, Body: "**first** comment"}
+	_, err = commentRepo.Create(c)
+	assert.NoError(t, err)
+	return c
+}
+
+func TestCreateComment(t *testing.T) {
+	testCases := []struct {
+		name          string
+		comment       func(todoId int) *comment.Comment
+		expectedError bool
+	}{
+		{
+			name: "successfully create",
+			comment: func(todoId int) *comment.Comment {
+				return &comment.Comment{TodoID: todoId, AuthorID: 1, Body: "hello"}
+			},
+		},
+		{
+			name: "empty body",
+			comment: func(todoId int) *comment.Comment {
+				return &comment.Comment{TodoID: todoId, AuthorID: 1, Body: "  "}
+			},
+			expectedError: true,
+		},
+		{
+			name: "non-existing todo",
+			comment: func(todoId int) *comment.Comment {
+				return &comment.Comment{TodoID: 999, AuthorID: 1, Body: "hello"}
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			todoRepo, commentRepo, tearDown := setupCommentRepositories(t)
+			defer tearDown()
+
+			todoId, err := todoRepo.Create(createTestTodo())
+			assert.NoError(t, err)
+
+			c := tc.comment(todoId)
+			id, err := commentRepo.Create(c)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 1, id)
+
+			comments, err := commentRepo.GetByTodoId(todoId)
+			assert.NoError(t, err)
+			assert.Equal(t, comment.Comments{c}, comments)
+
+			fetchedTodo, err := todoRepo.GetById(todoId)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, fetchedTodo.CommentsCount)
+		})
+	}
+}
+
+func TestGetCommentsByTodoId(t *testing.T) {
+	t.Parallel()
+	todoRepo, commentRepo, tearDown := setupCommentRepositories(t)
+	defer tearDown()
+
+	_, err := commentRepo.GetByTodoId(999)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	todoId, err := todoRepo.Create(createTestTodo())
+	assert.NoError(t, err)
+	comments, err := commentRepo.GetByTodoId(todoId)
+	assert.NoError(t, err)
+	assert.Empty(t, comments)
+}
+
+func TestUpdateComment(t *testing.T) {
+	testCases := []struct {
+		name          string
+		authorId      int
+		body          string
+		expectedError error
+	}{
+		{
+			name:     "successfully update own comment",
+			authorId: 1,
+			body:     "edited",
+		},
+		{
+			name:          "update someone else's comment",
+			authorId:      2,
+			body:          "edited",
+			expectedError: ErrForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			todoRepo, commentRepo, tearDown := setupCommentRepositories(t)
+			defer tearDown()
+
+			c := createTestComment(t, todoRepo, commentRepo)
+			err := commentRepo.Update(&comment.Comment{ID: c.ID, TodoID: c.TodoID, AuthorID: tc.authorId, Body: tc.body})
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			comments, err := commentRepo.GetByTodoId(c.TodoID)
+			assert.NoError(t, err)
+			assert.Len(t, comments, 1)
+			assert.Equal(t, tc.body, comments[0].Body)
+			assert.NotNil(t, comments[0].EditedAt)
+		})
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	testCases := []struct {
+		name          string
+		authorId      int
+		commentId     func(c *comment.Comment) int
+		expectedError error
+	}{
+		{
+			name:      "successfully delete own comment",
+			authorId:  1,
+			commentId: func(c *comment.Comment) int { return c.ID },
+		},
+		{
+			name:          "delete someone else's comment",
+			authorId:      2,
+			commentId:     func(c *comment.Comment) int { return c.ID },
+			expectedError: ErrForbidden,
+		},
+		{
+			name:          "delete non-existing comment",
+			authorId:      1,
+			commentId:     func(c *comment.Comment) int { return 999 },
+			expectedError: ErrRecordNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			todoRepo, commentRepo, tearDown := setupCommentRepositories(t)
+			defer tearDown()
+
+			c := createTestComment(t, todoRepo, commentRepo)
+			err := commentRepo.Delete(c.TodoID, tc.commentId(c), tc.authorId)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			comments, err := commentRepo.GetByTodoId(c.TodoID)
+			assert.NoError(t, err)
+			assert.Empty(t, comments)
+		})
+	}
+}
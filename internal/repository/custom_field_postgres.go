@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/customfield"
+	"github.com/lib/pq"
+	"log/slog"
+)
+
+const customFieldColumns = "id, key, name, type, options, required, created_at"
+
+type CustomFieldPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewCustomFieldPostgresRepository(db *sql.DB, logger *slog.Logger) *CustomFieldPostgresRepository {
+	return &CustomFieldPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *CustomFieldPostgresRepository) Create(d *customfield.Definition) (int, error) {
+	r.logger.Debug("Attempting to create custom field", slog.String("key", d.Key))
+	if err := d.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	created, err := scanCustomField(r.db.QueryRow(
+		"INSERT INTO custom_fields (key, name, type, options, required) "+
+			"VALUES ($1, $2, $3, $4, $5) RETURNING "+customFieldColumns,
+		d.Key,
+		d.Name,
+		d.Type,
+		pq.Array(d.Options),
+		d.Required,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			r.logger.Warn("Custom field already exists", slog.String("key", d.Key))
+			return 0, ErrCustomFieldExists
+		}
+		r.logger.Error("Failed to insert custom field", slog.String("error", err.Error()))
+		return 0, err
+	}
+	*d = *created
+
+	r.logger.Debug("Custom field created successfully", slog.Int("ID", d.ID))
+	return d.ID, nil
+}
+
+func (r *CustomFieldPostgresRepository) GetAll() (customfield.Definitions, error) {
+	r.logger.Debug("Fetching all custom fields")
+	definitions, err := getCustomFieldDefinitions(r.db)
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	r.logger.Debug("Custom fields fetched", slog.Int("count", len(definitions)))
+	return definitions, nil
+}
+
+// Update changes the name, options and required flag of a definition. Key and
+// type are fixed after creation, since existing values depend on them.
+// Making a field required doesn't touch todos that lack it; they fail
+// validation on their next update.
+func (r *CustomFieldPostgresRepository) Update(d *customfield.Definition) error {
+	r.logger.Debug("Updating custom field", slog.Int("ID", d.ID))
+	if d.ID == 0 {
+		r.logger.Warn("Missing ID for update")
+		return errors.New("absent id")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	err = tx.QueryRow("SELECT key, type FROM custom_fields WHERE id = $1 FOR UPDATE", d.ID).Scan(&d.Key, &d.Type)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Record not found", slog.Int("id", d.ID))
+		err = ErrRecordNotFound
+		return err
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch custom field", slog.String("error", err.Error()))
+		return err
+	}
+	if err = d.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = tx.QueryRow(
+		"UPDATE custom_fields SET name = $1, options = $2, required = $3 WHERE id = $4 RETURNING created_at",
+		d.Name,
+		pq.Array(d.Options),
+		d.Required,
+		d.ID,
+	).Scan(&d.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return err
+	}
+	d.CreatedAt = d.CreatedAt.UTC()
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return err
+	}
+	r.logger.Debug("Custom field updated", slog.Int("ID", d.ID))
+	return nil
+}
+
+// Delete removes a definition together with the values todos hold for it.
+func (r *CustomFieldPostgresRepository) Delete(id int) error {
+	r.logger.Debug("Attempting to delete custom field", slog.Int("ID", id))
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	var key string
+	err = tx.QueryRow("DELETE FROM custom_fields WHERE id = $1 RETURNING key", id).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Delete failed: no rows affected", slog.Int("id", id))
+		err = ErrRecordNotFound
+		return err
+	}
+	if err != nil {
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return err
+	}
+	if _, err = tx.Exec("UPDATE todos SET custom_fields = custom_fields - $1 WHERE custom_fields ? $1", key); err != nil {
+		r.logger.Error("Failed to remove custom field values", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return err
+	}
+	r.logger.Debug("Custom field deleted successfully", slog.Int("ID", id))
+	return nil
+}
+
+func getCustomFieldDefinitions(q querier) (customfield.Definitions, error) {
+	rows, err := q.Query("SELECT " + customFieldColumns + " FROM custom_fields ORDER BY key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	definitions := customfield.Definitions{}
+	for rows.Next() {
+		d, err := scanCustomField(rows)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, d)
+	}
+	return definitions, rows.Err()
+}
+
+func scanCustomField(row rowScanner) (*customfield.Definition, error) {
+	d := &customfield.Definition{}
+	err := row.Scan(
+		&d.ID,
+		&d.Key,
+		&d.Name,
+		&d.Type,
+		pq.Array(&d.Options),
+		&d.Required,
+		&d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.CreatedAt = d.CreatedAt.UTC()
+	return d, nil
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/customfield"
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
+	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func createTestCustomFields(t *testing.T, repo *CustomFieldPostgresRepository) {
+	for _, d := range []*customfield.Definition{
+		{Key: "customer", Name: "Customer", Type: customfield.Text},
+		{Key: "story_points", Name: "Story points", Type: customfield.Number},
+		{Key: "size", Name: "Size", Type: customfield.Enum, Options: []string{"s", "m", "l"}},
+		{Key: "billable", Name: "Billable", Type: customfield.Boolean},
+		{Key: "signed_off", Name: "Signed off", Type: customfield.Date},
+	} {
+		_, err := repo.Create(d)
+		assert.NoError(t, err)
+	}
+}
+
+func TestCreateCustomField(t *testing.T) {
+	testCases := []struct {
+		name          string
+		definition    *customfield.Definition
+		expectedError bool
+		errorIs       error
+	}{
+		{
+			name:       "successfully create",
+			definition: &customfield.Definition{Key: "sprint", Name: "Sprint", Type: customfield.Number},
+		},
+		{
+			name:          "duplicate key",
+			definition:    &customfield.Definition{Key: "customer", Name: "Client", Type: customfield.Text},
+			expectedError: true,
+			errorIs:       ErrCustomFieldExists,
+		},
+		{
+			name:          "enum without options",
+			definition:    &customfield.Definition{Key: "size_2", Name: "Size", Type: customfield.Enum},
+			expectedError: true,
+		},
+		{
+			name:          "invalid key",
+			definition:    &customfield.Definition{Key: "Story Points", Name: "Story points", Type: customfield.Number},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			repo := CustomFieldPostgresRepository{db: testDb, logger: logger}
+			createTestCustomFields(t, &repo)
+
+			_, err := repo.Create(tc.definition)
+			if tc.expectedError {
+				assert.Error(t, err)
+				if tc.errorIs != nil {
+					assert.ErrorIs(t, err, tc.errorIs)
+				}
+				return
+			}
+			assert.NoError(t, err)
+
+			definitions, err := repo.GetAll()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.definition, definitions.Find(tc.definition.Key))
+		})
+	}
+}
+
+func TestTodoCustomFieldValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		values        customfield.Values
+		expected      customfield.Values
+		expectedError bool
+	}{
+		{
+			name:     "valid values",
+			values:   customfield.Values{"customer": "acme", "story_points": 3.0, "size": "m", "billable": true},
+			expected: customfield.Values{"customer": "acme", "story_points": 3.0, "size": "m", "billable": true},
+		},
+		{
+			name:     "null values are dropped",
+			values:   customfield.Values{"customer": nil, "signed_off": "2025-04-10"},
+			expected: customfield.Values{"signed_off": "2025-04-10"},
+		},
+		{
+			name:          "unknown field",
+			values:        customfield.Values{"unknown": "x"},
+			expectedError: true,
+		},
+		{
+			name:          "wrong type",
+			values:        customfield.Values{"story_points": "three"},
+			expectedError: true,
+		},
+		{
+			name:          "option not allowed",
+			values:        customfield.Values{"size": "xl"},
+			expectedError: true,
+		},
+		{
+			name:          "invalid date",
+			values:        customfield.Values{"signed_off": "10.04.2025"},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+			repo := CustomFieldPostgresRepository{db: testDb, logger: logger}
+			createTestCustomFields(t, &repo)
+
+			newTodo := createTestTodo()
+			newTodo.CustomFields = tc.values
+			id, err := todoRepo.Create(newTodo)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, newTodo.CustomFields)
+
+			fetched, err := todoRepo.GetById(id)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, fetched.CustomFields)
+		})
+	}
+}
+
+func TestRequiredCustomField(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := CustomFieldPostgresRepository{db: testDb, logger: logger}
+
+	existing := createTestTodo()
+	_, err := todoRepo.Create(existing)
+	assert.NoError(t, err)
+
+	definition := &customfield.Definition{Key: "customer", Name: "Customer", Type: customfield.Text}
+	_, err = repo.Create(definition)
+	assert.NoError(t, err)
+	definition.Required = true
+	definition.Type = customfield.Number
+	assert.NoError(t, repo.Update(definition))
+	assert.Equal(t, customfield.Text, definition.Type)
+
+	_, err = todoRepo.Create(createTestTodo())
+	assert.Error(t, err)
+	assert.Error(t, todoRepo.Update(existing))
+
+	existing.CustomFields = customfield.Values{"customer": "acme"}
+	assert.NoError(t, todoRepo.Update(existing))
+}
+
+func TestFilterAndSortByCustomFields(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := CustomFieldPostgresRepository{db: testDb, logger: logger}
+	createTestCustomFields(t, &repo)
+
+	var ids []int
+	for _, values := range []customfield.Values{
+		{"customer": "acme", "story_points": 8.0, "billable": true},
+		{"customer": "acme", "story_points": 13.0},
+		{"customer": "globex", "story_points": 2.0, "billable": true},
+		{},
+	} {
+		newTodo := createTestTodo()
+		newTodo.CustomFields = values
+		id, err := todoRepo.Create(newTodo)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	page := pagination.Pagination{Limit: pagination.DefaultLimit}
+	idsOf := func(todoFilter filter.Filter) []int {
+		todos, err := todoRepo.GetAll(todoFilter, page)
+		assert.NoError(t, err)
+		var fetched []int
+		for _, fetchedTodo := range todos {
+			fetched = append(fetched, fetchedTodo.ID)
+		}
+		return fetched
+	}
+
+	assert.ElementsMatch(t, []int{ids[0], ids[1]}, idsOf(filter.Filter{
+		CustomFields: map[string]string{"customer": "acme"},
+	}))
+	assert.ElementsMatch(t, []int{ids[0]}, idsOf(filter.Filter{
+		CustomFields: map[string]string{"customer": "acme", "billable": "true"},
+	}))
+	assert.ElementsMatch(t, []int{ids[2]}, idsOf(filter.Filter{
+		CustomFields: map[string]string{"story_points": "2"},
+	}))
+
+	// Numbers sort numerically, todos without the field come last.
+	assert.Equal(t, []int{ids[2], ids[0], ids[1], ids[3]}, idsOf(filter.Filter{
+		Sort: filter.ParseSort("cf.story_points"),
+	}))
+	assert.Equal(t, []int{ids[1], ids[0], ids[2], ids[3]}, idsOf(filter.Filter{
+		Sort: filter.ParseSort("-cf.story_points"),
+	}))
+
+	_, err := todoRepo.GetAll(filter.Filter{CustomFields: map[string]string{"unknown": "x"}}, page)
+	assert.Error(t, err)
+	_, err = todoRepo.GetAll(filter.Filter{CustomFields: map[string]string{"story_points": "many"}}, page)
+	assert.Error(t, err)
+	_, err = todoRepo.GetAll(filter.Filter{Sort: filter.ParseSort("cf.unknown")}, page)
+	assert.Error(t, err)
+	_, err = todoRepo.GetAll(filter.Filter{Sort: filter.ParseSort("description")}, page)
+	assert.Error(t, err)
+}
+
+func TestDeleteCustomField(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := CustomFieldPostgresRepository{db: testDb, logger: logger}
+	createTestCustomFields(t, &repo)
+
+	newTodo := createTestTodo()
+	newTodo.CustomFields = customfield.Values{"customer": "acme", "size": "s"}
+	id, err := todoRepo.Create(newTodo)
+	assert.NoError(t, err)
+
+	definitions, err := repo.GetAll()
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(definitions.Find("customer").ID))
+	assert.ErrorIs(t, repo.Delete(definitions.Find("customer").ID), ErrRecordNotFound)
+
+	fetched, err := todoRepo.GetById(id)
+	assert.NoError(t, err)
+	assert.Equal(t, customfield.Values{"size": "s"}, fetched.CustomFields)
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"log/slog"
+)
+
+// PriorityPostgresRepository manages the configurable priorities. Like
+// StatusPostgresRepository it reloads the registry after every change.
+type PriorityPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewPriorityPostgresRepository(db *sql.DB, logger *slog.Logger) *PriorityPostgresRepository {
+	return &PriorityPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Load reads the priorities from the database into the priority registry.
+func (r *PriorityPostgresRepository) Load() error {
+	definitions, err := r.GetAll()
+	if err != nil {
+		return err
+	}
+	priority.SetRegistry(definitions)
+	r.logger.Debug("Priority registry loaded", slog.Int("count", len(definitions)))
+	return nil
+}
+
+func (r *PriorityPostgresRepository) GetAll() (priority.Definitions, error) {
+	r.logger.Debug("Fetching all priorities")
+	rows, err := r.db.Query("SELECT value, name, position, color FROM priorities ORDER BY position, value")
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	definitions := priority.Definitions{}
+	for rows.Next() {
+		d := &priority.Definition{}
+		if err := rows.Scan(&d.Value, &d.Name, &d.Position, &d.Color); err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		definitions = append(definitions, d)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (r *PriorityPostgresRepository) Create(d *priority.Definition) error {
+	r.logger.Debug("Attempting to create priority", slog.String("value", string(d.Value)))
+	if err := d.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err := r.db.Exec(
+		"INSERT INTO priorities (value, name, position, color) VALUES ($1, $2, $3, $4)",
+		d.Value,
+		d.Name,
+		d.Position,
+		d.Color,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			r.logger.Warn("Priority already exists", slog.String("value", string(d.Value)))
+			return ErrPriorityExists
+		}
+		r.logger.Error("Failed to insert priority", slog.String("error", err.Error()))
+		return err
+	}
+
+	r.logger.Debug("Priority created successfully", slog.String("value", string(d.Value)))
+	return r.Load()
+}
+
+// Update changes name, position and color of a priority; the value itself is
+// fixed.
+func (r *PriorityPostgresRepository) Update(d *priority.Definition) error {
+	r.logger.Debug("Updating priority", slog.String("value", string(d.Value)))
+	if err := d.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	res, err := r.db.Exec(
+		"UPDATE priorities SET name = $1, position = $2, color = $3 WHERE value = $4",
+		d.Name,
+		d.Position,
+		d.Color,
+		d.Value,
+	)
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Update failed: no rows affected", slog.String("value", string(d.Value)))
+		return ErrRecordNotFound
+	}
+
+	r.logger.Debug("Priority updated", slog.String("value", string(d.Value)))
+	return r.Load()
+}
+
+// Delete removes a priority that no todo or template uses. Template items
+// are stored as JSON and aren't checked; they fail validation on their next
+// update.
+func (r *PriorityPostgresRepository) Delete(value priority.Priority) error {
+	r.logger.Debug("Attempting to delete priority", slog.String("value", string(value)))
+	res, err := r.db.Exec("DELETE FROM priorities WHERE value = $1", value)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("Priority is in use", slog.String("value", string(value)))
+			return ErrValueInUse
+		}
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Delete failed: no rows affected", slog.String("value", string(value)))
+		return ErrRecordNotFound
+	}
+
+	r.logger.Debug("Priority deleted successfully", slog.String("value", string(value)))
+	return r.Load()
+}
@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/project"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/lib/pq"
+	"log/slog"
+)
+
+const projectColumns = "id, name, description, color, icon, archived, created_at"
+
+type ProjectPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewProjectPostgresRepository(db *sql.DB, logger *slog.Logger) *ProjectPostgresRepository {
+	return &ProjectPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *ProjectPostgresRepository) Create(p *project.Project) (int, error) {
+	r.logger.Debug("Attempting to create project", slog.String("name", p.Name))
+	if err := p.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	err := r.db.QueryRow(
+		"INSERT INTO projects (name, description, color, icon, archived) "+
+			"VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		p.Name,
+		p.Description,
+		p.Color,
+		p.Icon,
+		p.Archived,
+	).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to insert project", slog.String("error", err.Error()))
+		return 0, err
+	}
+	p.CreatedAt = p.CreatedAt.UTC()
+	p.StatusCounts = map[status.Status]int{}
+
+	r.logger.Debug("Project created successfully", slog.Int("ID", p.ID))
+	return p.ID, nil
+}
+
+func (r *ProjectPostgresRepository) GetById(id int) (*project.Project, error) {
+	r.logger.Debug("Fetching project by id", slog.Int("ID", id))
+	p, err := scanProject(r.db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE id = $1", id))
+	if err != nil {
+		r.logger.Warn("Record not found", slog.Int("id", id), slog.String("error", err.Error()))
+		return nil, ErrRecordNotFound
+	}
+
+	p.StatusCounts, err = r.StatusCounts(id)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetAll lists projects together with their per-status todo counts. The
+// counts are collected with a single grouped query.
+func (r *ProjectPostgresRepository) GetAll(includeArchived bool) (project.Projects, error) {
+	r.logger.Debug("Fetching all projects", slog.Bool("include_archived", includeArchived))
+	query := "SELECT " + projectColumns + " FROM projects"
+	if !includeArchived {
+		query += " WHERE NOT archived"
+	}
+	query += " ORDER BY name, id"
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("query", query), slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	projects := project.Projects{}
+	byId := make(map[int]*project.Project)
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		p.StatusCounts = map[status.Status]int{}
+		projects = append(projects, p)
+		byId[p.ID] = p
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return projects, nil
+	}
+
+	ids := make([]int64, 0, len(projects))
+	for _, p := range projects {
+		ids = append(ids, int64(p.ID))
+	}
+	countRows, err := r.db.Query(
+		"SELECT project_id, status, COUNT(*) FROM todos WHERE project_id = ANY($1) AND status IS NOT NULL "+
+			"GROUP BY project_id, status",
+		pq.Array(ids),
+	)
+	if err != nil {
+		r.logger.Error("Failed to count todos", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer countRows.Close()
+	for countRows.Next() {
+		var projectId, count int
+		var todoStatus status.Status
+		if err := countRows.Scan(&projectId, &todoStatus, &count); err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		byId[projectId].StatusCounts[todoStatus] = count
+	}
+	if err := countRows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	r.logger.Debug("Projects fetched", slog.Int("count", len(projects)))
+	return projects, nil
+}
+
+func (r *ProjectPostgresRepository) Update(p *project.Project) error {
+	r.logger.Debug("Updating project", slog.Int("ID", p.ID))
+	if p.ID == 0 {
+		r.logger.Warn("Missing ID for update")
+		return errors.New("absent id")
+	}
+	if err := p.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	err := r.db.QueryRow(
+		"UPDATE projects SET name = $1, description = $2, color = $3, icon = $4, archived = $5 "+
+			"WHERE id = $6 RETURNING created_at",
+		p.Name,
+		p.Description,
+		p.Color,
+		p.Icon,
+		p.Archived,
+		p.ID,
+	).Scan(&p.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Update failed: no rows affected", slog.Int("id", p.ID))
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return err
+	}
+	p.CreatedAt = p.CreatedAt.UTC()
+	r.logger.Debug("Project updated", slog.Int("ID", p.ID))
+	return nil
+}
+
+func (r *ProjectPostgresRepository) SetArchived(id int, archived bool) error {
+	r.logger.Debug("Setting project archived flag", slog.Int("ID", id), slog.Bool("archived", archived))
+	res, err := r.db.Exec("UPDATE projects SET archived = $1 WHERE id = $2", archived, id)
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Update failed: no rows affected", slog.Int("id", id))
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes a project. Its todos are kept and no longer belong to any
+// project.
+func (r *ProjectPostgresRepository) Delete(id int) error {
+	r.logger.Debug("Attempting to delete project", slog.Int("ID", id))
+	res, err := r.db.Exec("DELETE FROM projects WHERE id = $1", id)
+	if err != nil {
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Delete failed: no rows affected", slog.Int("id", id))
+		return ErrRecordNotFound
+	}
+	r.logger.Debug("Project deleted successfully", slog.Int("ID", id))
+	return nil
+}
+
+// MoveTodos assigns the given todos to a project in one statement, or takes
+// them out of their projects when projectId is nil, and returns how many
+// todos were moved.
+func (r *ProjectPostgresRepository) MoveTodos(projectId *int, todoIds []int) (int, error) {
+	r.logger.Debug("Moving todos to project", slog.Any("project_id", projectId), slog.Any("ids", todoIds))
+	if len(todoIds) == 0 {
+		r.logger.Warn("No IDs provided for move")
+		return 0, errors.New("no ids provided for move")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	if projectId != nil {
+		var archived bool
+		err = tx.QueryRow("SELECT archived FROM projects WHERE id = $1 FOR SHARE", *projectId).Scan(&archived)
+		if errors.Is(err, sql.ErrNoRows) {
+			r.logger.Warn("Project not found", slog.Int("project_id", *projectId))
+			err = ErrRecordNotFound
+			return 0, err
+		}
+		if err != nil {
+			r.logger.Error("Failed to fetch project", slog.String("error", err.Error()))
+			return 0, err
+		}
+		if archived {
+			r.logger.Warn("Project is archived", slog.Int("project_id", *projectId))
+			err = ErrProjectArchived
+			return 0, err
+		}
+	}
+
+	ids := make([]int64, len(todoIds))
+	for i, id := range todoIds {
+		ids[i] = int64(id)
+	}
+	res, err := tx.Exec("UPDATE todos SET project_id = $1 WHERE id = ANY($2)", projectId, pq.Array(ids))
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return 0, err
+	}
+	r.logger.Debug("Todos moved", slog.Any("project_id", projectId), slog.Int64("count", rowsAffected))
+	return int(rowsAffected), nil
+}
+
+func (r *ProjectPostgresRepository) StatusCounts(projectId int) (map[status.Status]int, error) {
+	rows, err := r.db.Query(
+		"SELECT status, COUNT(*) FROM todos WHERE project_id = $1 AND status IS NOT NULL GROUP BY status",
+		projectId,
+	)
+	if err != nil {
+		r.logger.Error("Failed to count todos", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[status.Status]int{}
+	for rows.Next() {
+		var todoStatus status.Status
+		var count int
+		if err := rows.Scan(&todoStatus, &count); err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		counts[todoStatus] = count
+	}
+	return counts, rows.Err()
+}
+
+func scanProject(row rowScanner) (*project.Project, error) {
+	p := &project.Project{}
+	err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&p.Description,
+		&p.Color,
+		&p.Icon,
+		&p.Archived,
+		&p.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.CreatedAt = p.CreatedAt.UTC()
+	return p, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
+	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
+	"github.com/GlebMoskalev/todo-api/internal/models/project"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func createTestProject() *project.Project {
+	return &project.Project{
+		Name:  "work",
+		Color: "#ff8800",
+		Icon:  "briefcase",
+	}
+}
+
+func TestCreateProject(t *testing.T) {
+	testCases := []struct {
+		name          string
+		project       *project.Project
+		expectedError bool
+	}{
+		{
+			name:    "successfully create",
+			project: createTestProject(),
+		},
+		{
+			name: "empty name",
+			project: func() *project.Project {
+				p := createTestProject()
+				p.Name = ""
+				return p
+			}(),
+			expectedError: true,
+		},
+		{
+			name: "invalid color",
+			project: func() *project.Project {
+				p := createTestProject()
+				p.Color = "orange"
+				return p
+			}(),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			repo := ProjectPostgresRepository{db: testDb, logger: logger}
+
+			id, err := repo.Create(tc.project)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			fetched, err := repo.GetById(id)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.project, fetched)
+		})
+	}
+}
+
+func TestArchiveProject(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := ProjectPostgresRepository{db: testDb, logger: logger}
+
+	active := createTestProject()
+	_, err := repo.Create(active)
+	assert.NoError(t, err)
+	archived := createTestProject()
+	archived.Name = "old"
+	_, err = repo.Create(archived)
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.SetArchived(archived.ID, true))
+	assert.ErrorIs(t, repo.SetArchived(999, true), ErrRecordNotFound)
+
+	projects, err := repo.GetAll(false)
+	assert.NoError(t, err)
+	assert.Len(t, projects, 1)
+	assert.Equal(t, active.ID, projects[0].ID)
+
+	projects, err = repo.GetAll(true)
+	assert.NoError(t, err)
+	assert.Len(t, projects, 2)
+
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	todoId, err := todoRepo.Create(createTestTodo())
+	assert.NoError(t, err)
+	_, err = repo.MoveTodos(&archived.ID, []int{todoId})
+	assert.ErrorIs(t, err, ErrProjectArchived)
+}
+
+func TestMoveTodos(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := ProjectPostgresRepository{db: testDb, logger: logger}
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	source := createTestProject()
+	_, err := repo.Create(source)
+	assert.NoError(t, err)
+	target := createTestProject()
+	target.Name = "home"
+	_, err = repo.Create(target)
+	assert.NoError(t, err)
+
+	var ids []int
+	for _, todoStatus := range []status.Status{status.Planned, status.Planned, status.Completed} {
+		newTodo := createTestTodo()
+		newTodo.Status = todoStatus
+		newTodo.ProjectID = &source.ID
+		id, err := todoRepo.Create(newTodo)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	_, err = todoRepo.Create(createTestTodo())
+	assert.NoError(t, err)
+
+	moved, err := repo.MoveTodos(&target.ID, ids[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, 2, moved)
+
+	counts, err := repo.StatusCounts(target.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[status.Status]int{status.Planned: 1, status.Completed: 1}, counts)
+
+	projects, err := repo.GetAll(false)
+	assert.NoError(t, err)
+	for _, p := range projects {
+		if p.ID == source.ID {
+			assert.Equal(t, map[status.Status]int{status.Planned: 1}, p.StatusCounts)
+		}
+	}
+
+	todos, err := todoRepo.GetAll(filter.Filter{ProjectID: &target.ID}, pagination.Pagination{
+		Offset: pagination.DefaultOffset,
+		Limit:  pagination.DefaultLimit,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, todos, 2)
+
+	todos, err = todoRepo.GetAll(filter.Filter{WithoutProject: true}, pagination.Pagination{
+		Offset: pagination.DefaultOffset,
+		Limit:  pagination.DefaultLimit,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, todos, 1)
+
+	missing := 999
+	_, err = repo.MoveTodos(&missing, ids)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	moved, err = repo.MoveTodos(nil, ids[1:2])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	todos, err = todoRepo.GetAll(filter.Filter{WithoutProject: true}, pagination.Pagination{
+		Offset: pagination.DefaultOffset,
+		Limit:  pagination.DefaultLimit,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, todos, 2)
+}
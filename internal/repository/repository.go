@@ -1,17 +1,133 @@
 package repository
 
 import (
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/attachment"
+	"github.com/GlebMoskalev/todo-api/internal/models/comment"
+	"github.com/GlebMoskalev/todo-api/internal/models/customfield"
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
 	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
 	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/project"
 	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/GlebMoskalev/todo-api/internal/models/tag"
+	"github.com/GlebMoskalev/todo-api/internal/models/template"
+	"github.com/GlebMoskalev/todo-api/internal/models/timeentry"
 	"github.com/GlebMoskalev/todo-api/internal/models/todo"
+	"github.com/GlebMoskalev/todo-api/internal/models/user"
+	"time"
+)
+
+var (
+	ErrRecordNotFound    = errors.New("record not found")
+	ErrForbidden         = errors.New("forbidden")
+	ErrProjectNotFound   = errors.New("project not found")
+	ErrProjectArchived   = errors.New("project is archived")
+	ErrTagExists         = errors.New("tag already exists")
+	ErrTimerRunning      = errors.New("a timer is already running")
+	ErrCustomFieldExists = errors.New("custom field already exists")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserExists        = errors.New("user already exists")
+	ErrStatusExists      = errors.New("status already exists")
+	ErrPriorityExists    = errors.New("priority already exists")
+	ErrValueInUse        = errors.New("value is in use")
+	ErrBulkRolledBack    = errors.New("bulk update rolled back")
+	ErrInvalidValue      = errors.New("invalid value")
 )
 
 type TodoRepository interface {
 	Create(todo *todo.Todo) (int, error)
+	CreateWithItems(parent *todo.Todo, items todo.Todos) ([]int, error)
+	CreateBatch(todos todo.Todos) ([]error, error)
 	GetById(id int) (*todo.Todo, error)
-	GetAll(tags []string, statusFilter status.Status, priorityFilter priority.Priority,
-		overdue *bool, dueDate todo.NullTime, pagination pagination.Pagination) (*todo.Todos, error)
+	GetAll(filter filter.Filter, pagination pagination.Pagination) (todo.Todos, error)
 	Update(todo *todo.Todo) error
-	Delete(ids []int) error
+	Patch(id int, patch func(document []byte) ([]byte, error)) (*todo.Todo, error)
+	BulkUpdate(ids []int, filter filter.Filter, update todo.BulkUpdate, options todo.BulkOptions) (*todo.BulkResult, error)
+	Snooze(id int, until *time.Time) error
+	Move(id int, after, before *int) (string, error)
+	SetArchived(id int, archived bool) error
+	Reassign(ids []int, assigneeId *int) (int, error)
+	Duplicate(id int, options todo.DuplicateOptions) (*todo.Todo, error)
+	ArchiveCompleted(after time.Duration) (int, error)
+	Delete(ids []int) ([]string, error)
+}
+
+type CommentRepository interface {
+	Create(comment *comment.Comment) (int, error)
+	GetByTodoId(todoId int) (comment.Comments, error)
+	Update(comment *comment.Comment) error
+	Delete(todoId, id, authorId int) error
+}
+
+type AttachmentRepository interface {
+	Create(attachment *attachment.Attachment) (int, error)
+	GetById(todoId, id int) (*attachment.Attachment, error)
+	GetByTodoId(todoId int) (attachment.Attachments, error)
+	Delete(todoId, id int) (*attachment.Attachment, bool, error)
+}
+
+type ProjectRepository interface {
+	Create(project *project.Project) (int, error)
+	GetById(id int) (*project.Project, error)
+	GetAll(includeArchived bool) (project.Projects, error)
+	Update(project *project.Project) error
+	SetArchived(id int, archived bool) error
+	Delete(id int) error
+	MoveTodos(projectId *int, todoIds []int) (int, error)
+	StatusCounts(projectId int) (map[status.Status]int, error)
+}
+
+type TagRepository interface {
+	GetAll(prefix string, limit int) (tag.Tags, error)
+	SetMetadata(tag *tag.Tag) error
+	Rename(oldName, newName string) (int, error)
+	Merge(sources []string, target string) (int, error)
+}
+
+type TimeEntryRepository interface {
+	StartTimer(todoId, userId int) (*timeentry.TimeEntry, error)
+	StopTimer(todoId, userId int) (*timeentry.TimeEntry, error)
+	Create(entry *timeentry.TimeEntry) (int, error)
+	GetByTodoId(todoId int) (timeentry.TimeEntries, error)
+	Delete(todoId, id, userId int) error
+	Report(groupBy timeentry.GroupBy, from, to time.Time) (*timeentry.Report, error)
+}
+
+type CustomFieldRepository interface {
+	Create(definition *customfield.Definition) (int, error)
+	GetAll() (customfield.Definitions, error)
+	Update(definition *customfield.Definition) error
+	Delete(id int) error
+}
+
+type TemplateRepository interface {
+	Create(template *template.Template) (int, error)
+	GetById(id int) (*template.Template, error)
+	GetAll() (template.Templates, error)
+	Update(template *template.Template) error
+	Delete(id int) error
+}
+
+type UserRepository interface {
+	Create(user *user.User) (int, error)
+	GetById(id int) (*user.User, error)
+	GetAll() (user.Users, error)
+	Delete(id int) error
+}
+
+type StatusRepository interface {
+	Load() error
+	GetAll() (status.Definitions, error)
+	Create(definition *status.Definition) error
+	Update(definition *status.Definition) error
+	Delete(value status.Status) error
+}
+
+type PriorityRepository interface {
+	Load() error
+	GetAll() (priority.Definitions, error)
+	Create(definition *priority.Definition) error
+	Update(definition *priority.Definition) error
+	Delete(value priority.Priority) error
 }
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"log/slog"
+)
+
+// StatusPostgresRepository manages the configurable statuses. Every change
+// reloads the status registry, so validation picks it up immediately in this
+// process; other processes see it on their next Load.
+type StatusPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewStatusPostgresRepository(db *sql.DB, logger *slog.Logger) *StatusPostgresRepository {
+	return &StatusPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Load reads the statuses from the database into the status registry.
+func (r *StatusPostgresRepository) Load() error {
+	definitions, err := r.GetAll()
+	if err != nil {
+		return err
+	}
+	status.SetRegistry(definitions)
+	r.logger.Debug("Status registry loaded", slog.Int("count", len(definitions)))
+	return nil
+}
+
+func (r *StatusPostgresRepository) GetAll() (status.Definitions, error) {
+	r.logger.Debug("Fetching all statuses")
+	rows, err := r.db.Query("SELECT value, name, position, color, terminal FROM statuses ORDER BY position, value")
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	definitions := status.Definitions{}
+	for rows.Next() {
+		d := &status.Definition{}
+		if err := rows.Scan(&d.Value, &d.Name, &d.Position, &d.Color, &d.Terminal); err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		definitions = append(definitions, d)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (r *StatusPostgresRepository) Create(d *status.Definition) error {
+	r.logger.Debug("Attempting to create status", slog.String("value", string(d.Value)))
+	if err := d.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err := r.db.Exec(
+		"INSERT INTO statuses (value, name, position, color, terminal) VALUES ($1, $2, $3, $4, $5)",
+		d.Value,
+		d.Name,
+		d.Position,
+		d.Color,
+		d.Terminal,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			r.logger.Warn("Status already exists", slog.String("value", string(d.Value)))
+			return ErrStatusExists
+		}
+		r.logger.Error("Failed to insert status", slog.String("error", err.Error()))
+		return err
+	}
+
+	r.logger.Debug("Status created successfully", slog.String("value", string(d.Value)))
+	return r.Load()
+}
+
+// Update changes name, position, color and the terminal flag of a status; the
+// value itself is fixed. Toggling the terminal flag starts or stops the
+// archiving countdown of the todos in that status.
+func (r *StatusPostgresRepository) Update(d *status.Definition) error {
+	r.logger.Debug("Updating status", slog.String("value", string(d.Value)))
+	if err := d.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	res, err := tx.Exec(
+		"UPDATE statuses SET name = $1, position = $2, color = $3, terminal = $4 WHERE value = $5",
+		d.Name,
+		d.Position,
+		d.Color,
+		d.Terminal,
+		d.Value,
+	)
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Update failed: no rows affected", slog.String("value", string(d.Value)))
+		err = ErrRecordNotFound
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE todos SET terminal_at = CASE WHEN $1 THEN COALESCE(terminal_at, now()) END WHERE status = $2",
+		d.Terminal,
+		d.Value,
+	)
+	if err != nil {
+		r.logger.Error("Failed to update todos", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return err
+	}
+	r.logger.Debug("Status updated", slog.String("value", string(d.Value)))
+	return r.Load()
+}
+
+// Delete removes a status no todo uses. Planned can't be removed, since
+// templates create their todos in it.
+func (r *StatusPostgresRepository) Delete(value status.Status) error {
+	r.logger.Debug("Attempting to delete status", slog.String("value", string(value)))
+	if value == status.Planned {
+		r.logger.Warn("Refusing to delete the planned status")
+		return errors.New("the planned status cannot be deleted")
+	}
+
+	res, err := r.db.Exec("DELETE FROM statuses WHERE value = $1", value)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("Status is in use", slog.String("value", string(value)))
+			return ErrValueInUse
+		}
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Delete failed: no rows affected", slog.String("value", string(value)))
+		return ErrRecordNotFound
+	}
+
+	r.logger.Debug("Status deleted successfully", slog.String("value", string(value)))
+	return r.Load()
+}
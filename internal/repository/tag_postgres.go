@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/tag"
+	"github.com/lib/pq"
+	"log/slog"
+	"strings"
+)
+
+type TagPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewTagPostgresRepository(db *sql.DB, logger *slog.Logger) *TagPostgresRepository {
+	return &TagPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetAll lists every known tag, either used by a todo or carrying metadata,
+// ordered by usage. A non-empty prefix narrows the list for autocomplete.
+func (r *TagPostgresRepository) GetAll(prefix string, limit int) (tag.Tags, error) {
+	r.logger.Debug("Fetching tags", slog.String("prefix", prefix), slog.Int("limit", limit))
+	if limit <= 0 {
+		r.logger.Warn("Invalid limit", slog.Int("limit", limit))
+		return nil, fmt.Errorf("invalid limit: must be > 0")
+	}
+
+	rows, err := r.db.Query(
+		"WITH usage AS (SELECT tag AS name, COUNT(*) AS usage_count FROM todos, unnest(todos.tags) AS tag GROUP BY tag) "+
+			"SELECT COALESCE(usage.name, tags.name), COALESCE(usage.usage_count, 0), "+
+			"COALESCE(tags.color, ''), COALESCE(tags.description, '') "+
+			"FROM usage FULL OUTER JOIN tags ON tags.name = usage.name "+
+			"WHERE COALESCE(usage.name, tags.name) ILIKE $1 ESCAPE '\\' "+
+			"ORDER BY 2 DESC, 1 LIMIT $2",
+		escapeLike(prefix)+"%",
+		limit,
+	)
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	tags := tag.Tags{}
+	for rows.Next() {
+		t := &tag.Tag{}
+		if err := rows.Scan(&t.Name, &t.UsageCount, &t.Color, &t.Description); err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	r.logger.Debug("Tags fetched", slog.Int("count", len(tags)))
+	return tags, nil
+}
+
+// SetMetadata stores color and description for a tag, whether or not any
+// todo uses it yet.
+func (r *TagPostgresRepository) SetMetadata(t *tag.Tag) error {
+	r.logger.Debug("Setting tag metadata", slog.String("name", t.Name))
+	if err := t.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+	_, err := r.db.Exec(
+		"INSERT INTO tags (name, color, description) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (name) DO UPDATE SET color = EXCLUDED.color, description = EXCLUDED.description",
+		t.Name,
+		t.Color,
+		t.Description,
+	)
+	if err != nil {
+		r.logger.Error("Failed to upsert tag", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// Rename replaces oldName with newName on every todo and template and moves
+// its metadata.
+// It refuses to rename onto a tag that already exists; use Merge for that.
+func (r *TagPostgresRepository) Rename(oldName, newName string) (int, error) {
+	r.logger.Debug("Renaming tag", slog.String("old", oldName), slog.String("new", newName))
+	if err := tag.ValidateName(newName); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return 0, err
+	}
+	if oldName == newName {
+		return 0, errors.New("new tag name must differ from the old one")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	var oldExists, newExists bool
+	err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM todos WHERE $1 = ANY(tags)) OR EXISTS (SELECT 1 FROM tags WHERE name = $1) "+
+			"OR EXISTS (SELECT 1 FROM templates WHERE $1 = ANY(tags)), "+
+			"EXISTS (SELECT 1 FROM todos WHERE $2 = ANY(tags)) OR EXISTS (SELECT 1 FROM tags WHERE name = $2) "+
+			"OR EXISTS (SELECT 1 FROM templates WHERE $2 = ANY(tags))",
+		oldName,
+		newName,
+	).Scan(&oldExists, &newExists)
+	if err != nil {
+		r.logger.Error("Failed to check tags", slog.String("error", err.Error()))
+		return 0, err
+	}
+	if !oldExists {
+		err = ErrRecordNotFound
+		return 0, err
+	}
+	if newExists {
+		err = ErrTagExists
+		return 0, err
+	}
+
+	updated, err := replaceTags(tx, []string{oldName}, newName)
+	if err != nil {
+		r.logger.Error("Failed to rename tag on todos", slog.String("error", err.Error()))
+		return 0, err
+	}
+	if _, err = tx.Exec("UPDATE tags SET name = $1 WHERE name = $2", newName, oldName); err != nil {
+		r.logger.Error("Failed to rename tag metadata", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return 0, err
+	}
+	r.logger.Debug("Tag renamed", slog.String("old", oldName), slog.String("new", newName),
+		slog.Int("todos", updated))
+	return updated, nil
+}
+
+// Merge folds the source tags into target on every todo and template,
+// removing duplicates.
+// The target keeps its own metadata, or inherits it from the first source that
+// has any.
+func (r *TagPostgresRepository) Merge(sources []string, target string) (int, error) {
+	r.logger.Debug("Merging tags", slog.Any("sources", sources), slog.String("target", target))
+	if err := tag.ValidateName(target); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return 0, err
+	}
+	var filteredSources []string
+	for _, source := range sources {
+		if source != target {
+			filteredSources = append(filteredSources, source)
+		}
+	}
+	if len(filteredSources) == 0 {
+		r.logger.Warn("No source tags provided for merge")
+		return 0, errors.New("no source tags provided for merge")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	updated, err := replaceTags(tx, filteredSources, target)
+	if err != nil {
+		r.logger.Error("Failed to merge tags on todos", slog.String("error", err.Error()))
+		return 0, err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO tags (name, color, description) "+
+			"SELECT $2::text, color, description FROM tags WHERE name = ANY($1::text[]) "+
+			"ORDER BY array_position($1::text[], name) LIMIT 1 "+
+			"ON CONFLICT (name) DO NOTHING",
+		pq.Array(filteredSources),
+		target,
+	)
+	if err != nil {
+		r.logger.Error("Failed to merge tag metadata", slog.String("error", err.Error()))
+		return 0, err
+	}
+	if _, err = tx.Exec("DELETE FROM tags WHERE name = ANY($1)", pq.Array(filteredSources)); err != nil {
+		r.logger.Error("Failed to delete merged tag metadata", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return 0, err
+	}
+	r.logger.Debug("Tags merged", slog.Any("sources", filteredSources), slog.String("target", target),
+		slog.Int("todos", updated))
+	return updated, nil
+}
+
+// replaceTags swaps every source tag for target on todos and on templates and
+// their items, keeping the original tag order and dropping duplicates that
+// the replacement creates. It returns the number of todos updated.
+func replaceTags(tx *sql.Tx, sources []string, target string) (int, error) {
+	res, err := tx.Exec(
+		"UPDATE todos SET tags = ("+replacedTags("unnest(todos.tags)", "array_agg")+") WHERE tags && $1::text[]",
+		pq.Array(sources),
+		target,
+	)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE templates SET tags = ("+replacedTags("unnest(templates.tags)", "array_agg")+") WHERE tags && $1::text[]",
+		pq.Array(sources),
+		target,
+	)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(
+		"UPDATE templates SET items = ("+
+			"SELECT jsonb_agg(CASE WHEN jsonb_typeof(i.item->'tags') = 'array' "+
+			"THEN jsonb_set(i.item, '{tags}', COALESCE(("+
+			replacedTags("jsonb_array_elements_text(i.item->'tags')", "jsonb_agg")+
+			"), '[]'::jsonb)) ELSE i.item END ORDER BY i.position) "+
+			"FROM jsonb_array_elements(templates.items) WITH ORDINALITY AS i(item, position)"+
+			") WHERE EXISTS ("+
+			"SELECT 1 FROM jsonb_array_elements(templates.items) AS i(item) "+
+			"WHERE jsonb_typeof(i.item->'tags') = 'array' AND i.item->'tags' ?| $1::text[]"+
+			")",
+		pq.Array(sources),
+		target,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return int(rowsAffected), nil
+}
+
+// replacedTags returns a subquery aggregating the tags of elements, with $1
+// replaced by $2, into an array with aggregate.
+func replacedTags(elements, aggregate string) string {
+	return "SELECT " + aggregate + "(merged.tag ORDER BY merged.position) FROM (" +
+		"SELECT CASE WHEN u.tag = ANY($1::text[]) THEN $2::text ELSE u.tag END AS tag, MIN(u.position) AS position " +
+		"FROM " + elements + " WITH ORDINALITY AS u(tag, position) GROUP BY 1" +
+		") merged"
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
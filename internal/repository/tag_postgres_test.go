@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/tag"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func createTodosWithTags(t *testing.T, repo *TodoPostgresRepository, tagSets ...[]string) []int {
+	var ids []int
+	for _, tags := range tagSets {
+		newTodo := createTestTodo()
+		newTodo.Tags = tags
+		id, err := repo.Create(newTodo)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestGetAllTags(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := TagPostgresRepository{db: testDb, logger: logger}
+
+	createTodosWithTags(t, &todoRepo, []string{"work", "api"}, []string{"work"}, []string{"wrok"})
+	assert.NoError(t, repo.SetMetadata(&tag.Tag{Name: "home", Color: "#00ff00"}))
+
+	tags, err := repo.GetAll("", tag.DefaultLimit)
+	assert.NoError(t, err)
+	assert.Equal(t, tag.Tags{
+		{Name: "work", UsageCount: 2},
+		{Name: "api", UsageCount: 1},
+		{Name: "wrok", UsageCount: 1},
+		{Name: "home", Color: "#00ff00", UsageCount: 0},
+	}, tags)
+
+	tags, err = repo.GetAll("W", tag.DefaultLimit)
+	assert.NoError(t, err)
+	assert.Equal(t, tag.Tags{
+		{Name: "work", UsageCount: 2},
+		{Name: "wrok", UsageCount: 1},
+	}, tags)
+
+	tags, err = repo.GetAll("%", tag.DefaultLimit)
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
+func TestRenameTag(t *testing.T) {
+	testCases := []struct {
+		name          string
+		oldName       string
+		newName       string
+		expectedError error
+		expectedTags  [][]string
+	}{
+		{
+			name:         "successfully rename",
+			oldName:      "wrok",
+			newName:      "job",
+			expectedTags: [][]string{{"work", "api"}, {"job", "home"}},
+		},
+		{
+			name:          "rename onto existing tag",
+			oldName:       "wrok",
+			newName:       "work",
+			expectedError: ErrTagExists,
+		},
+		{
+			name:          "rename unknown tag",
+			oldName:       "missing",
+			newName:       "job",
+			expectedError: ErrRecordNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+			repo := TagPostgresRepository{db: testDb, logger: logger}
+
+			ids := createTodosWithTags(t, &todoRepo, []string{"work", "api"}, []string{"wrok", "home"})
+			updated, err := repo.Rename(tc.oldName, tc.newName)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 1, updated)
+			for i, id := range ids {
+				fetched, err := todoRepo.GetById(id)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedTags[i], fetched.Tags)
+			}
+		})
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := TagPostgresRepository{db: testDb, logger: logger}
+
+	ids := createTodosWithTags(t, &todoRepo,
+		[]string{"wrok", "api", "work"},
+		[]string{"Work", "home"},
+		[]string{"home"},
+	)
+	assert.NoError(t, repo.SetMetadata(&tag.Tag{Name: "wrok", Color: "#123456"}))
+
+	updated, err := repo.Merge([]string{"wrok", "Work"}, "work")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated)
+
+	expectedTags := [][]string{{"work", "api"}, {"work", "home"}, {"home"}}
+	for i, id := range ids {
+		fetched, err := todoRepo.GetById(id)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedTags[i], fetched.Tags)
+	}
+
+	tags, err := repo.GetAll("w", tag.DefaultLimit)
+	assert.NoError(t, err)
+	assert.Equal(t, tag.Tags{{Name: "work", Color: "#123456", UsageCount: 2}}, tags)
+}
+
+func TestRenameAndMergeTagsOnTemplates(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	templateRepo := TemplatePostgresRepository{db: testDb, logger: logger}
+	repo := TagPostgresRepository{db: testDb, logger: logger}
+
+	newTemplate := createTestTemplate()
+	newTemplate.Tags = []string{"wrok", "onboarding"}
+	newTemplate.Items[0].Tags = []string{"Work", "onboarding", "work"}
+	templateId, err := templateRepo.Create(newTemplate)
+	assert.NoError(t, err)
+
+	updated, err := repo.Merge([]string{"wrok", "Work"}, "work")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, updated)
+
+	_, err = repo.Rename("onboarding", "welcome")
+	assert.NoError(t, err)
+
+	fetched, err := templateRepo.GetById(templateId)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"work", "welcome"}, fetched.Tags)
+	assert.Equal(t, []string{"work", "welcome"}, fetched.Items[0].Tags)
+	assert.Empty(t, fetched.Items[1].Tags)
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/template"
+	"github.com/lib/pq"
+	"log/slog"
+)
+
+const templateColumns = "id, name, title, description, tags, priority, due_offset, items, created_at"
+
+type TemplatePostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewTemplatePostgresRepository(db *sql.DB, logger *slog.Logger) *TemplatePostgresRepository {
+	return &TemplatePostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *TemplatePostgresRepository) Create(t *template.Template) (int, error) {
+	r.logger.Debug("Attempting to create template", slog.String("name", t.Name))
+	if err := t.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return 0, err
+	}
+	items, err := encodeTemplateItems(t.Items)
+	if err != nil {
+		return 0, err
+	}
+
+	created, err := scanTemplate(r.db.QueryRow(
+		"INSERT INTO templates (name, title, description, tags, priority, due_offset, items) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING "+templateColumns,
+		t.Name,
+		t.Title,
+		t.Description,
+		pq.Array(nonNilTags(t.Tags)),
+		t.Priority,
+		t.DueOffset,
+		items,
+	))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("Priority not found", slog.String("priority", string(t.Priority)))
+			return 0, fmt.Errorf("invalid value field \"Priority\": %s", t.Priority)
+		}
+		r.logger.Error("Failed to insert template", slog.String("error", err.Error()))
+		return 0, err
+	}
+	*t = *created
+
+	r.logger.Debug("Template created successfully", slog.Int("ID", t.ID))
+	return t.ID, nil
+}
+
+func (r *TemplatePostgresRepository) GetById(id int) (*template.Template, error) {
+	r.logger.Debug("Fetching template by id", slog.Int("ID", id))
+	t, err := scanTemplate(r.db.QueryRow("SELECT "+templateColumns+" FROM templates WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Record not found", slog.Int("id", id))
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch template", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *TemplatePostgresRepository) GetAll() (template.Templates, error) {
+	r.logger.Debug("Fetching all templates")
+	rows, err := r.db.Query("SELECT " + templateColumns + " FROM templates ORDER BY name, id")
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	templates := template.Templates{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	r.logger.Debug("Templates fetched", slog.Int("count", len(templates)))
+	return templates, nil
+}
+
+func (r *TemplatePostgresRepository) Update(t *template.Template) error {
+	r.logger.Debug("Updating template", slog.Int("ID", t.ID))
+	if t.ID == 0 {
+		r.logger.Warn("Missing ID for update")
+		return errors.New("absent id")
+	}
+	if err := t.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+	items, err := encodeTemplateItems(t.Items)
+	if err != nil {
+		return err
+	}
+
+	updated, err := scanTemplate(r.db.QueryRow(
+		"UPDATE templates SET name = $1, title = $2, description = $3, tags = $4, priority = $5, "+
+			"due_offset = $6, items = $7 WHERE id = $8 RETURNING "+templateColumns,
+		t.Name,
+		t.Title,
+		t.Description,
+		pq.Array(nonNilTags(t.Tags)),
+		t.Priority,
+		t.DueOffset,
+		items,
+		t.ID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Update failed: no rows affected", slog.Int("id", t.ID))
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("Priority not found", slog.String("priority", string(t.Priority)))
+			return fmt.Errorf("invalid value field \"Priority\": %s", t.Priority)
+		}
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return err
+	}
+	*t = *updated
+
+	r.logger.Debug("Template updated", slog.Int("ID", t.ID))
+	return nil
+}
+
+func (r *TemplatePostgresRepository) Delete(id int) error {
+	r.logger.Debug("Attempting to delete template", slog.Int("ID", id))
+	res, err := r.db.Exec("DELETE FROM templates WHERE id = $1", id)
+	if err != nil {
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Delete failed: no rows affected", slog.Int("id", id))
+		return ErrRecordNotFound
+	}
+	r.logger.Debug("Template deleted successfully", slog.Int("ID", id))
+	return nil
+}
+
+func encodeTemplateItems(items []*template.Item) (string, error) {
+	if items == nil {
+		items = []*template.Item{}
+	}
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+func scanTemplate(row rowScanner) (*template.Template, error) {
+	t := &template.Template{}
+	var items []byte
+	err := row.Scan(
+		&t.ID,
+		&t.Name,
+		&t.Title,
+		&t.Description,
+		pq.Array(&t.Tags),
+		&t.Priority,
+		&t.DueOffset,
+		&items,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(items, &t.Items); err != nil {
+		return nil, err
+	}
+	t.CreatedAt = t.CreatedAt.UTC()
+	return t, nil
+}
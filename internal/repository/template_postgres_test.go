@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
+	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/template"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func createTestTemplate() *template.Template {
+	return &template.Template{
+		Name:        "onboarding",
+		Title:       "Onboard {{name}}",
+		Description: "Welcome {{ name }} to {{team}}",
+		Tags:        []string{"onboarding", "{{team}}"},
+		Priority:    priority.High,
+		DueOffset:   "+1w",
+		Items: []*template.Item{
+			{Title: "Create accounts for {{name}}", DueOffset: "+1d"},
+			{Title: "Intro call", Priority: priority.Low, DueOffset: "+4h"},
+		},
+	}
+}
+
+func TestCreateTemplate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		template      func() *template.Template
+		expectedError bool
+	}{
+		{
+			name:     "successfully create",
+			template: createTestTemplate,
+		},
+		{
+			name: "without items",
+			template: func() *template.Template {
+				newTemplate := createTestTemplate()
+				newTemplate.Items = nil
+				return newTemplate
+			},
+		},
+		{
+			name: "invalid due offset",
+			template: func() *template.Template {
+				newTemplate := createTestTemplate()
+				newTemplate.DueOffset = "3 days"
+				return newTemplate
+			},
+			expectedError: true,
+		},
+		{
+			name: "item without title",
+			template: func() *template.Template {
+				newTemplate := createTestTemplate()
+				newTemplate.Items[1].Title = " "
+				return newTemplate
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			repo := TemplatePostgresRepository{db: testDb, logger: logger}
+
+			newTemplate := tc.template()
+			id, err := repo.Create(newTemplate)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			fetched, err := repo.GetById(id)
+			assert.NoError(t, err)
+			assert.Equal(t, newTemplate, fetched)
+		})
+	}
+}
+
+func TestUpdateAndDeleteTemplate(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TemplatePostgresRepository{db: testDb, logger: logger}
+
+	newTemplate := createTestTemplate()
+	id, err := repo.Create(newTemplate)
+	assert.NoError(t, err)
+
+	newTemplate.Title = "Release {{version}}"
+	newTemplate.Items = newTemplate.Items[:1]
+	assert.NoError(t, repo.Update(newTemplate))
+	fetched, err := repo.GetById(id)
+	assert.NoError(t, err)
+	assert.Equal(t, newTemplate, fetched)
+
+	missing := createTestTemplate()
+	missing.ID = 999
+	assert.ErrorIs(t, repo.Update(missing), ErrRecordNotFound)
+
+	assert.NoError(t, repo.Delete(id))
+	assert.ErrorIs(t, repo.Delete(id), ErrRecordNotFound)
+	_, err = repo.GetById(id)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestInstantiateTemplate(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := TemplatePostgresRepository{db: testDb, logger: logger}
+
+	newTemplate := createTestTemplate()
+	_, err := repo.Create(newTemplate)
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 4, 19, 22, 0, 0, 0, time.UTC)
+	_, err = newTemplate.Instantiate(map[string]string{"name": "Ada"}, now)
+	assert.EqualError(t, err, "missing variables: team")
+
+	todos, err := newTemplate.Instantiate(map[string]string{"name": "Ada", "team": "platform"}, now)
+	assert.NoError(t, err)
+
+	// A missing project fails the whole instantiation.
+	projectId := 999
+	for _, newTodo := range todos {
+		newTodo.ProjectID = &projectId
+	}
+	_, err = todoRepo.CreateWithItems(todos[0], todos[1:])
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+	all, err := todoRepo.GetAll(filter.Filter{}, pagination.Pagination{Limit: pagination.DefaultLimit})
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+
+	for _, newTodo := range todos {
+		newTodo.ProjectID = nil
+	}
+	ids, err := todoRepo.CreateWithItems(todos[0], todos[1:])
+	assert.NoError(t, err)
+	assert.Len(t, ids, 3)
+
+	main, err := todoRepo.GetById(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "Onboard Ada", main.Title)
+	assert.Equal(t, "Welcome Ada to platform", main.Description)
+	assert.Equal(t, []string{"onboarding", "platform"}, main.Tags)
+	assert.Equal(t, time.Date(2025, 4, 26, 0, 0, 0, 0, time.UTC), main.DueDate.Time)
+	assert.Nil(t, main.ParentID)
+
+	accounts, err := todoRepo.GetById(ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "Create accounts for Ada", accounts.Title)
+	assert.Equal(t, priority.High, accounts.Priority)
+	assert.Less(t, main.Rank, accounts.Rank)
+	assert.Equal(t, &ids[0], accounts.ParentID)
+
+	call, err := todoRepo.GetById(ids[2])
+	assert.NoError(t, err)
+	assert.Equal(t, priority.Low, call.Priority)
+	assert.True(t, call.DueDate.HasTime)
+	assert.True(t, now.Add(4*time.Hour).Equal(call.DueDate.Time))
+	assert.Equal(t, &ids[0], call.ParentID)
+
+	// Deleting the main todo keeps its items as standalone todos.
+	_, err = todoRepo.Delete([]int{ids[0]})
+	assert.NoError(t, err)
+	call, err = todoRepo.GetById(ids[2])
+	assert.NoError(t, err)
+	assert.Nil(t, call.ParentID)
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/timeentry"
+	"log/slog"
+	"time"
+)
+
+const (
+	timeEntryColumns = "id, todo_id, user_id, started_at, ended_at, note, " +
+		"COALESCE(EXTRACT(EPOCH FROM ended_at - started_at)::bigint, 0)"
+	// loggedSecondsColumn sums finished entries of the todo in the outer query.
+	loggedSecondsColumn = "(SELECT COALESCE(SUM(EXTRACT(EPOCH FROM time_entries.ended_at - time_entries.started_at)), 0)::bigint " +
+		"FROM time_entries WHERE time_entries.todo_id = todos.id AND time_entries.ended_at IS NOT NULL)"
+)
+
+type TimeEntryPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewTimeEntryPostgresRepository(db *sql.DB, logger *slog.Logger) *TimeEntryPostgresRepository {
+	return &TimeEntryPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// StartTimer opens a running entry for the user on a todo. A user can only
+// have one running timer; a second start returns ErrTimerRunning.
+func (r *TimeEntryPostgresRepository) StartTimer(todoId, userId int) (*timeentry.TimeEntry, error) {
+	r.logger.Debug("Starting timer", slog.Int("todo_id", todoId), slog.Int("user_id", userId))
+	entry, err := scanTimeEntry(r.db.QueryRow(
+		"INSERT INTO time_entries (todo_id, user_id, started_at) VALUES ($1, $2, now()) RETURNING "+timeEntryColumns,
+		todoId,
+		userId,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			r.logger.Warn("Timer already running", slog.Int("user_id", userId))
+			return nil, ErrTimerRunning
+		}
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("Todo not found", slog.Int("todo_id", todoId))
+			return nil, ErrRecordNotFound
+		}
+		r.logger.Error("Failed to start timer", slog.String("error", err.Error()))
+		return nil, err
+	}
+	r.logger.Debug("Timer started", slog.Int("ID", entry.ID))
+	return entry, nil
+}
+
+func (r *TimeEntryPostgresRepository) StopTimer(todoId, userId int) (*timeentry.TimeEntry, error) {
+	r.logger.Debug("Stopping timer", slog.Int("todo_id", todoId), slog.Int("user_id", userId))
+	entry, err := scanTimeEntry(r.db.QueryRow(
+		"UPDATE time_entries SET ended_at = now() "+
+			"WHERE todo_id = $1 AND user_id = $2 AND ended_at IS NULL RETURNING "+timeEntryColumns,
+		todoId,
+		userId,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("No running timer", slog.Int("todo_id", todoId), slog.Int("user_id", userId))
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to stop timer", slog.String("error", err.Error()))
+		return nil, err
+	}
+	r.logger.Debug("Timer stopped", slog.Int("ID", entry.ID))
+	return entry, nil
+}
+
+// Create stores a manually logged, already finished entry.
+func (r *TimeEntryPostgresRepository) Create(entry *timeentry.TimeEntry) (int, error) {
+	r.logger.Debug("Attempting to create time entry", slog.Int("todo_id", entry.TodoID))
+	if err := entry.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	created, err := scanTimeEntry(r.db.QueryRow(
+		"INSERT INTO time_entries (todo_id, user_id, started_at, ended_at, note) "+
+			"VALUES ($1, $2, $3, $4, $5) RETURNING "+timeEntryColumns,
+		entry.TodoID,
+		entry.UserID,
+		entry.StartedAt,
+		*entry.EndedAt,
+		entry.Note,
+	))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("Todo not found", slog.Int("todo_id", entry.TodoID))
+			return 0, ErrRecordNotFound
+		}
+		r.logger.Error("Failed to insert time entry", slog.String("error", err.Error()))
+		return 0, err
+	}
+	*entry = *created
+
+	r.logger.Debug("Time entry created successfully", slog.Int("ID", entry.ID))
+	return entry.ID, nil
+}
+
+func (r *TimeEntryPostgresRepository) GetByTodoId(todoId int) (timeentry.TimeEntries, error) {
+	r.logger.Debug("Fetching time entries", slog.Int("todo_id", todoId))
+
+	var exists bool
+	if err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1)", todoId).Scan(&exists); err != nil {
+		r.logger.Error("Failed to check todo", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if !exists {
+		r.logger.Warn("Todo not found", slog.Int("todo_id", todoId))
+		return nil, ErrRecordNotFound
+	}
+
+	rows, err := r.db.Query(
+		"SELECT "+timeEntryColumns+" FROM time_entries WHERE todo_id = $1 ORDER BY started_at, id",
+		todoId,
+	)
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	entries := timeentry.TimeEntries{}
+	for rows.Next() {
+		entry, err := scanTimeEntry(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	r.logger.Debug("Time entries fetched", slog.Int("count", len(entries)))
+	return entries, nil
+}
+
+// Delete removes an entry. Users can only delete their own entries.
+func (r *TimeEntryPostgresRepository) Delete(todoId, id, userId int) error {
+	r.logger.Debug("Attempting to delete time entry", slog.Int("ID", id))
+	var ownerId int
+	err := r.db.QueryRow(
+		"SELECT user_id FROM time_entries WHERE id = $1 AND todo_id = $2",
+		id,
+		todoId,
+	).Scan(&ownerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Record not found", slog.Int("id", id))
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch time entry", slog.String("error", err.Error()))
+		return err
+	}
+	if ownerId != userId {
+		r.logger.Warn("Time entry deletion rejected", slog.Int("ID", id), slog.Int("user_id", userId))
+		return ErrForbidden
+	}
+
+	if _, err := r.db.Exec("DELETE FROM time_entries WHERE id = $1 AND user_id = $2", id, userId); err != nil {
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return err
+	}
+	r.logger.Debug("Time entry deleted successfully", slog.Int("ID", id))
+	return nil
+}
+
+// Report sums finished entries that started within [from, to). When grouping
+// by tag, an entry counts towards every tag of its todo, so the rows can add
+// up to more than TotalSeconds; entries of untagged todos are grouped under
+// an empty key.
+func (r *TimeEntryPostgresRepository) Report(groupBy timeentry.GroupBy, from, to time.Time) (*timeentry.Report, error) {
+	r.logger.Debug("Building time report", slog.String("group_by", string(groupBy)),
+		slog.Time("from", from), slog.Time("to", to))
+	if !to.After(from) {
+		r.logger.Warn("Invalid report range", slog.Time("from", from), slog.Time("to", to))
+		return nil, errors.New("invalid range: to must be after from")
+	}
+
+	var keyExpression, extraFrom string
+	switch groupBy {
+	case timeentry.GroupByTag:
+		keyExpression = "COALESCE(tag, '')"
+		extraFrom = " LEFT JOIN LATERAL unnest(todos.tags) AS tag ON true"
+	case timeentry.GroupByStatus:
+		keyExpression = "COALESCE(todos.status::text, '')"
+	case timeentry.GroupByDate:
+		keyExpression = "to_char(time_entries.started_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+	default:
+		r.logger.Warn("Invalid group by", slog.String("group_by", string(groupBy)))
+		return nil, fmt.Errorf("invalid value field \"GroupBy\": %s", groupBy)
+	}
+
+	const durationExpression = "COALESCE(SUM(EXTRACT(EPOCH FROM time_entries.ended_at - time_entries.started_at)), 0)::bigint"
+	const baseFrom = "FROM time_entries JOIN todos ON todos.id = time_entries.todo_id"
+	const conditions = " WHERE time_entries.ended_at IS NOT NULL AND time_entries.started_at >= $1 AND time_entries.started_at < $2"
+
+	report := &timeentry.Report{GroupBy: groupBy, From: from.UTC(), To: to.UTC(), Rows: []*timeentry.ReportRow{}}
+	err := r.db.QueryRow("SELECT "+durationExpression+" "+baseFrom+conditions, from, to).Scan(&report.TotalSeconds)
+	if err != nil {
+		r.logger.Error("Failed to sum time entries", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	query := "SELECT " + keyExpression + ", " + durationExpression + " " + baseFrom + extraFrom + conditions +
+		" GROUP BY 1 ORDER BY 1"
+	rows, err := r.db.Query(query, from, to)
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("query", query), slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+	for rows.Next() {
+		row := &timeentry.ReportRow{}
+		if err := rows.Scan(&row.Key, &row.Seconds); err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return report, nil
+}
+
+func scanTimeEntry(row rowScanner) (*timeentry.TimeEntry, error) {
+	entry := &timeentry.TimeEntry{}
+	var endedAt sql.NullTime
+	err := row.Scan(
+		&entry.ID,
+		&entry.TodoID,
+		&entry.UserID,
+		&entry.StartedAt,
+		&endedAt,
+		&entry.Note,
+		&entry.DurationSeconds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	entry.StartedAt = entry.StartedAt.UTC()
+	if endedAt.Valid {
+		endedAtUTC := endedAt.Time.UTC()
+		entry.EndedAt = &endedAtUTC
+	}
+	return entry, nil
+}
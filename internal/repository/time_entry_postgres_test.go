@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/GlebMoskalev/todo-api/internal/models/timeentry"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func createTestTimeEntry(todoId int, startedAt time.Time, duration time.Duration) *timeentry.TimeEntry {
+	endedAt := startedAt.Add(duration)
+	return &timeentry.TimeEntry{
+		TodoID:    todoId,
+		UserID:    1,
+		StartedAt: startedAt,
+		EndedAt:   &endedAt,
+		Note:      "work",
+	}
+}
+
+func TestTimer(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := TimeEntryPostgresRepository{db: testDb, logger: logger}
+
+	firstId, err := todoRepo.Create(createTestTodo())
+	assert.NoError(t, err)
+	secondId, err := todoRepo.Create(createTestTodo())
+	assert.NoError(t, err)
+
+	_, err = repo.StopTimer(firstId, 1)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	running, err := repo.StartTimer(firstId, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, running.EndedAt)
+
+	_, err = repo.StartTimer(secondId, 1)
+	assert.ErrorIs(t, err, ErrTimerRunning)
+
+	_, err = repo.StartTimer(secondId, 2)
+	assert.NoError(t, err)
+
+	stopped, err := repo.StopTimer(firstId, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, running.ID, stopped.ID)
+	assert.NotNil(t, stopped.EndedAt)
+
+	_, err = repo.StartTimer(secondId, 1)
+	assert.NoError(t, err)
+
+	_, err = repo.StartTimer(999, 3)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestCreateTimeEntry(t *testing.T) {
+	startedAt := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		entry         func(todoId int) *timeentry.TimeEntry
+		expectedError bool
+	}{
+		{
+			name: "successfully create",
+			entry: func(todoId int) *timeentry.TimeEntry {
+				return createTestTimeEntry(todoId, startedAt, 90*time.Minute)
+			},
+		},
+		{
+			name: "ends before start",
+			entry: func(todoId int) *timeentry.TimeEntry {
+				return createTestTimeEntry(todoId, startedAt, -time.Minute)
+			},
+			expectedError: true,
+		},
+		{
+			name: "missing end",
+			entry: func(todoId int) *timeentry.TimeEntry {
+				e := createTestTimeEntry(todoId, startedAt, time.Minute)
+				e.EndedAt = nil
+				return e
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+			repo := TimeEntryPostgresRepository{db: testDb, logger: logger}
+
+			todoId, err := todoRepo.Create(createTestTodo())
+			assert.NoError(t, err)
+
+			entry := tc.entry(todoId)
+			_, err = repo.Create(entry)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(5400), entry.DurationSeconds)
+
+			entries, err := repo.GetByTodoId(todoId)
+			assert.NoError(t, err)
+			assert.Equal(t, timeentry.TimeEntries{entry}, entries)
+
+			fetchedTodo, err := todoRepo.GetById(todoId)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(5400), fetchedTodo.LoggedSeconds)
+		})
+	}
+}
+
+func TestDeleteTimeEntry(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := TimeEntryPostgresRepository{db: testDb, logger: logger}
+
+	todoId, err := todoRepo.Create(createTestTodo())
+	assert.NoError(t, err)
+	entry := createTestTimeEntry(todoId, time.Now().Add(-time.Hour), time.Minute)
+	_, err = repo.Create(entry)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, repo.Delete(todoId, entry.ID, 2), ErrForbidden)
+	assert.NoError(t, repo.Delete(todoId, entry.ID, 1))
+	assert.ErrorIs(t, repo.Delete(todoId, entry.ID, 1), ErrRecordNotFound)
+}
+
+func TestTimeReport(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	repo := TimeEntryPostgresRepository{db: testDb, logger: logger}
+
+	first := createTestTodo()
+	first.Tags = []string{"client-a", "backend"}
+	first.Status = status.InProgress
+	firstId, err := todoRepo.Create(first)
+	assert.NoError(t, err)
+
+	second := createTestTodo()
+	second.Tags = []string{"client-b"}
+	second.Status = status.Completed
+	secondId, err := todoRepo.Create(second)
+	assert.NoError(t, err)
+
+	untagged := createTestTodo()
+	untagged.Tags = nil
+	untagged.Status = status.Completed
+	untaggedId, err := todoRepo.Create(untagged)
+	assert.NoError(t, err)
+
+	day := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	for _, entry := range []*timeentry.TimeEntry{
+		createTestTimeEntry(firstId, day, time.Hour),
+		createTestTimeEntry(firstId, day.AddDate(0, 0, 1), 30*time.Minute),
+		createTestTimeEntry(secondId, day, 15*time.Minute),
+		createTestTimeEntry(secondId, day.AddDate(0, 0, 10), time.Hour),
+		createTestTimeEntry(untaggedId, day.AddDate(0, 0, 1), 10*time.Minute),
+	} {
+		_, err := repo.Create(entry)
+		assert.NoError(t, err)
+	}
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC)
+
+	report, err := repo.Report(timeentry.GroupByTag, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6900), report.TotalSeconds)
+	assert.Equal(t, []*timeentry.ReportRow{
+		{Key: "", Seconds: 600},
+		{Key: "backend", Seconds: 5400},
+		{Key: "client-a", Seconds: 5400},
+		{Key: "client-b", Seconds: 900},
+	}, report.Rows)
+
+	report, err = repo.Report(timeentry.GroupByStatus, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []*timeentry.ReportRow{
+		{Key: string(status.Completed), Seconds: 1500},
+		{Key: string(status.InProgress), Seconds: 5400},
+	}, report.Rows)
+
+	report, err = repo.Report(timeentry.GroupByDate, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []*timeentry.ReportRow{
+		{Key: "2025-04-01", Seconds: 4500},
+		{Key: "2025-04-02", Seconds: 2400},
+	}, report.Rows)
+
+	_, err = repo.Report(timeentry.GroupByDate, to, from)
+	assert.Error(t, err)
+}
@@ -1,19 +1,114 @@
 package repository
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/models/customfield"
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
 	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
 	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/project"
 	"github.com/GlebMoskalev/todo-api/internal/models/status"
 	"github.com/GlebMoskalev/todo-api/internal/models/todo"
+	"github.com/GlebMoskalev/todo-api/internal/models/user"
+	"github.com/GlebMoskalev/todo-api/internal/rank"
 	"github.com/lib/pq"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 )
 
+// todoColumnList lists the columns read for a todo with the JSON field each
+// one fills. A due date needs both due_date and due_at.
+var todoColumnList = []struct {
+	name       string
+	field      string
+	expression string
+}{
+	{"id", "id", "id"},
+	{"title", "title", "title"},
+	{"description", "description", "description"},
+	{"due_date", "due_date", "due_date"},
+	{"due_at", "due_date", "due_at"},
+	{"time_zone", "time_zone", "time_zone"},
+	{"defer_until", "defer_until", "defer_until"},
+	{"rank", "rank", "rank"},
+	{"archived_at", "archived_at", "archived_at"},
+	{"tags", "tags", "tags"},
+	{"priority", "priority", "priority"},
+	{"status", "status", "status"},
+	{"overdue", "overdue", "overdue"},
+	{"project_id", "project_id", "project_id"},
+	{"parent_id", "parent_id", "parent_id"},
+	{"assignee_id", "assignee_id", "assignee_id"},
+	{"created_by", "created_by", "created_by"},
+	{"estimate_seconds", "estimate_seconds", "estimate_seconds"},
+	{"custom_fields", "custom_fields", "custom_fields"},
+	{"comments_count", "comments_count", "(SELECT COUNT(*) FROM comments WHERE comments.todo_id = todos.id)"},
+	{"logged_seconds", "logged_seconds", loggedSecondsColumn},
+	{"version", "version", "version"},
+	{"updated_at", "updated_at", "updated_at"},
+}
+
+var todoColumns = todoColumnsFor(nil)
+
+// todoColumnsFor returns the select list reading the fields in fieldset.
+func todoColumnsFor(fieldset todo.Fieldset) string {
+	var expressions []string
+	for _, column := range todoColumnList {
+		if fieldset.Has(column.field) {
+			expressions = append(expressions, column.expression)
+		}
+	}
+	return strings.Join(expressions, ", ")
+}
+
+// todoFieldsRead returns the fields GetAll reads for todoFilter: those
+// requested, plus what building the response needs. The version feeds the
+// list's ETag and the time zone places timed due dates.
+func todoFieldsRead(todoFilter filter.Filter) todo.Fieldset {
+	if todoFilter.Fields == nil {
+		return nil
+	}
+	fieldset := append(todo.Fieldset{}, todoFilter.Fields...)
+	required := []string{"version"}
+	if fieldset.Has("due_date") {
+		required = append(required, "time_zone")
+	}
+	if slices.Contains(todoFilter.Include, todo.IncludeProject) {
+		required = append(required, "project_id")
+	}
+	if slices.Contains(todoFilter.Include, todo.IncludeAssignee) {
+		required = append(required, "assignee_id")
+	}
+	for _, field := range required {
+		if !fieldset.Has(field) {
+			fieldset = append(fieldset, field)
+		}
+	}
+	return fieldset
+}
+
+// todoSortColumns lists the columns GetAll can sort by besides custom fields.
+// On the same day timed due dates come before all-day ones. Priorities and
+// statuses sort by their configured position.
+var todoSortColumns = map[string][]string{
+	"id":       {"id"},
+	"title":    {"title"},
+	"due_date": {"due_date", "due_at"},
+	"priority": {"(SELECT position FROM priorities WHERE priorities.value = todos.priority)", "priority"},
+	"status":   {"(SELECT position FROM statuses WHERE statuses.value = todos.status)", "status"},
+	"rank":     {"rank"},
+}
+
+// rankLockKey is the advisory lock serializing rank changes, so concurrent
+// inserts and moves never compute the same key.
+const rankLockKey = 0x72616e6b
+
 type TodoPostgresRepository struct {
 	db     *sql.DB
 	logger *slog.Logger
@@ -47,100 +142,416 @@ func (r *TodoPostgresRepository) Create(todo *todo.Todo) (int, error) {
 		}
 	}()
 
-	var utcDueDate any
-	if todo.DueDate.Valid {
-		utcDueDate = todo.DueDate.Time.UTC()
-	} else {
-		utcDueDate = nil
+	if err = r.insertTodo(tx, todo); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	r.logger.Debug("Todo created successfully", slog.String("Title", todo.Title), slog.Int("ID", todo.ID))
+	return todo.ID, nil
+}
+
+// CreateWithItems inserts a todo and its items in one transaction: either all
+// of them are created or none is. The items get the todo as their parent and
+// are ranked after it in the given order. It returns the ids, the todo's
+// first.
+func (r *TodoPostgresRepository) CreateWithItems(parent *todo.Todo, items todo.Todos) ([]int, error) {
+	r.logger.Debug("Attempting to create todo with items", slog.Int("items", len(items)))
+	todos := append(todo.Todos{parent}, items...)
+	for i, t := range todos {
+		if err := t.Validate(); err != nil {
+			r.logger.Warn("Validation failed", slog.Int("index", i), slog.String("error", err.Error()))
+			return nil, fmt.Errorf("todo %d: %w", i, err)
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	ids := make([]int, 0, len(todos))
+	for i, t := range todos {
+		t.ParentID = nil
+		if i > 0 {
+			t.ParentID = &parent.ID
+		}
+		if err = r.insertTodo(tx, t); err != nil {
+			err = fmt.Errorf("todo %d: %w", i, err)
+			return nil, err
+		}
+		ids = append(ids, t.ID)
+	}
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	r.logger.Debug("Todos created successfully", slog.Any("ids", ids))
+	return ids, nil
+}
+
+// insertTodo inserts a validated todo within tx and fills in its ID and rank.
+func (r *TodoPostgresRepository) insertTodo(tx *sql.Tx, todo *todo.Todo) error {
+	customFields, err := validateCustomFields(tx, todo)
+	if err != nil {
+		r.logger.Warn("Custom field validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	dueDate, dueAt, err := dueDateColumns(todo)
+	if err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
+	}
+	todoRank, err := r.nextRank(tx)
+	if err != nil {
+		r.logger.Error("Failed to compute rank", slog.String("error", err.Error()))
+		return err
 	}
 	row := tx.QueryRow(
-		"INSERT INTO todos (title, description, due_date, tags, priority, status, overdue) "+
-			"VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		"INSERT INTO todos (title, description, due_date, due_at, time_zone, defer_until, rank, tags, priority, "+
+			"status, overdue, project_id, parent_id, assignee_id, created_by, estimate_seconds, custom_fields, terminal_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, CASE WHEN $18 THEN now() END) "+
+			"RETURNING id, version, updated_at",
 		todo.Title,
 		todo.Description,
-		utcDueDate,
+		dueDate,
+		dueAt,
+		todo.TimeZone,
+		todo.DeferUntil,
+		todoRank,
 		pq.Array(todo.Tags),
 		todo.Priority,
 		todo.Status,
 		todo.Overdue,
+		todo.ProjectID,
+		todo.ParentID,
+		todo.AssigneeID,
+		todo.CreatedBy,
+		todo.EstimateSeconds,
+		customFields,
+		status.IsTerminal(todo.Status),
 	)
-	var id int
-	if err := row.Scan(&id); err != nil {
+	if err = row.Scan(&todo.ID, &todo.Version, &todo.UpdatedAt); err != nil {
+		if isForeignKeyViolation(err) {
+			return r.todoReferenceError(todo, err)
+		}
 		r.logger.Error("Failed to scan id", slog.String("error", err.Error()))
-		return 0, fmt.Errorf("error scanning last insert id: %w", err)
+		return fmt.Errorf("error scanning last insert id: %w", err)
 	}
-	if err := tx.Commit(); err != nil {
+	todo.Rank = todoRank
+	todo.UpdatedAt = todo.UpdatedAt.UTC()
+	return nil
+}
+
+// batchChunkSize is the number of rows per INSERT statement in CreateBatch,
+// keeping the parameters of one statement well below the Postgres limit.
+const batchChunkSize = 1000
+
+// CreateBatch inserts many todos in one transaction with multi-row inserts.
+// Each todo is checked on its own: the returned row errors are aligned with
+// todos and invalid rows are skipped, while the valid ones get their ID.
+// Only database failures abort and roll back the whole batch.
+func (r *TodoPostgresRepository) CreateBatch(todos todo.Todos) ([]error, error) {
+	r.logger.Debug("Creating todo batch", slog.Int("count", len(todos)))
+	rowErrors := make([]error, len(todos))
+	for i, t := range todos {
+		rowErrors[i] = t.Validate()
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	definitions, err := getCustomFieldDefinitions(tx)
+	if err != nil {
+		r.logger.Error("Failed to fetch custom fields", slog.String("error", err.Error()))
+		return nil, err
+	}
+	// Missing references would fail a whole multi-row insert, so they are
+	// looked up once up front and reported per row.
+	var projectIds, userIds []int
+	for i, t := range todos {
+		if rowErrors[i] != nil {
+			continue
+		}
+		if t.ProjectID != nil {
+			projectIds = append(projectIds, *t.ProjectID)
+		}
+		for _, userId := range []*int{t.AssigneeID, t.CreatedBy} {
+			if userId != nil {
+				userIds = append(userIds, *userId)
+			}
+		}
+	}
+	existingProjects, err := existingIds(tx, "projects", projectIds)
+	if err != nil {
+		r.logger.Error("Failed to check projects", slog.String("error", err.Error()))
+		return nil, err
+	}
+	existingUsers, err := existingIds(tx, "users", userIds)
+	if err != nil {
+		r.logger.Error("Failed to check users", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	var rows [][]any
+	var rowIndexes []int
+	for i, t := range todos {
+		if rowErrors[i] != nil {
+			continue
+		}
+		if t.ProjectID != nil && !existingProjects[*t.ProjectID] {
+			rowErrors[i] = ErrProjectNotFound
+			continue
+		}
+		if (t.AssigneeID != nil && !existingUsers[*t.AssigneeID]) || (t.CreatedBy != nil && !existingUsers[*t.CreatedBy]) {
+			rowErrors[i] = ErrUserNotFound
+			continue
+		}
+		values, valuesErr := definitions.ValidateValues(t.CustomFields)
+		if valuesErr != nil {
+			rowErrors[i] = fmt.Errorf("%w: %w", ErrInvalidValue, valuesErr)
+			continue
+		}
+		t.CustomFields = values
+		customFields, marshalErr := json.Marshal(values)
+		if marshalErr != nil {
+			rowErrors[i] = marshalErr
+			continue
+		}
+		dueDate, dueAt, dueErr := dueDateColumns(t)
+		if dueErr != nil {
+			rowErrors[i] = dueErr
+			continue
+		}
+		rows = append(rows, []any{t.Title, t.Description, dueDate, dueAt, t.TimeZone, t.DeferUntil, nil,
+			pq.Array(t.Tags), t.Priority, t.Status, t.Overdue, t.ProjectID, t.AssigneeID, t.CreatedBy,
+			t.EstimateSeconds, string(customFields), status.IsTerminal(t.Status)})
+		rowIndexes = append(rowIndexes, i)
+	}
+
+	if len(rows) > 0 {
+		var ranks []string
+		if ranks, err = r.nextRanks(tx, len(rows)); err != nil {
+			r.logger.Error("Failed to compute ranks", slog.String("error", err.Error()))
+			return nil, err
+		}
+		indexByRank := make(map[string]int, len(rows))
+		for i, row := range rows {
+			row[6] = ranks[i]
+			indexByRank[ranks[i]] = rowIndexes[i]
+		}
+		for start := 0; start < len(rows); start += batchChunkSize {
+			if err = r.insertTodoRows(tx, rows[start:min(start+batchChunkSize, len(rows))], todos, indexByRank); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
 		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
-		return 0, err
+		return nil, err
 	}
+	r.logger.Debug("Todo batch created", slog.Int("created", len(rows)), slog.Int("failed", len(todos)-len(rows)))
+	return rowErrors, nil
+}
 
-	todo.ID = id
-	r.logger.Debug("Todo created successfully", slog.String("Title", todo.Title), slog.Int("ID", id))
-	return todo.ID, nil
+// insertTodoRows inserts rows of INSERT parameters with one statement and sets
+// the IDs and ranks of the todos they came from. Rows are matched back by
+// their unique rank, as RETURNING doesn't promise any order.
+func (r *TodoPostgresRepository) insertTodoRows(tx *sql.Tx, rows [][]any, todos todo.Todos, indexByRank map[string]int) error {
+	var placeholders []string
+	var params []any
+	for _, row := range rows {
+		n := len(params)
+		placeholders = append(placeholders, fmt.Sprintf(
+			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, CASE WHEN $%d THEN now() END)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13, n+14, n+15, n+16, n+17))
+		params = append(params, row...)
+	}
+	result, err := tx.Query(
+		"INSERT INTO todos (title, description, due_date, due_at, time_zone, defer_until, rank, tags, priority, "+
+			"status, overdue, project_id, assignee_id, created_by, estimate_seconds, custom_fields, terminal_at) "+
+			"VALUES "+strings.Join(placeholders, ", ")+" RETURNING id, rank",
+		params...,
+	)
+	if err != nil {
+		r.logger.Error("Failed to insert todos", slog.String("error", err.Error()))
+		return err
+	}
+	defer result.Close()
+	for result.Next() {
+		var id int
+		var todoRank string
+		if err := result.Scan(&id, &todoRank); err != nil {
+			r.logger.Error("Failed to scan id", slog.String("error", err.Error()))
+			return err
+		}
+		t := todos[indexByRank[todoRank]]
+		t.ID = id
+		t.Rank = todoRank
+	}
+	return result.Err()
+}
+
+// existingIds returns which of ids exist in table.
+func existingIds(q querier, table string, ids []int) (map[int]bool, error) {
+	existing := make(map[int]bool)
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	rows, err := q.Query("SELECT id FROM "+pq.QuoteIdentifier(table)+" WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		existing[id] = true
+	}
+	return existing, rows.Err()
 }
 
 func (r *TodoPostgresRepository) GetById(id int) (*todo.Todo, error) {
 	r.logger.Debug("Fetching todo by id", slog.Int("ID", id))
-	t := &todo.Todo{}
-
-	var dueDate sql.NullTime
-	err := r.db.QueryRow(
-		"SELECT id, title, description, due_date, tags, priority, status, overdue FROM todos WHERE id = $1",
-		id,
-	).Scan(
-		&t.ID,
-		&t.Title,
-		&t.Description,
-		&dueDate,
-		pq.Array(&t.Tags),
-		&t.Priority,
-		&t.Status,
-		&t.Overdue,
-	)
+	t, err := scanTodo(r.db.QueryRow("SELECT "+todoColumns+" FROM todos WHERE id = $1", id))
 	if err != nil {
 		r.logger.Warn("Record not found", slog.Int("id", id), slog.String("error", err.Error()))
-		return nil, errors.New("record not found")
+		return nil, ErrRecordNotFound
 	}
 
-	if dueDate.Valid {
-		t.DueDate = todo.NullTime{
-			Time:  dueDate.Time.UTC(),
-			Valid: true,
-		}
-	} else {
-		t.DueDate = todo.NullTime{
-			Valid: false,
-		}
+	t.Attachments, err = getAttachmentsByTodoId(r.db, t.ID)
+	if err != nil {
+		r.logger.Error("Failed to fetch attachments", slog.Int("id", t.ID), slog.String("error", err.Error()))
+		return nil, err
 	}
 
 	r.logger.Debug("Todo fetched", slog.Int("id", t.ID))
 	return t, nil
 }
 
-func (r *TodoPostgresRepository) GetAll(
-	tags []string,
-	statusFilter status.Status,
-	priorityFilter priority.Priority,
-	overdue *bool,
-	dueDate todo.NullTime,
-	paginationParams pagination.Pagination) (todo.Todos, error) {
-	r.logger.Debug("Fetching all todos", slog.Any("tags", tags),
-		slog.String("status", string(statusFilter)), slog.String("priority", string(priorityFilter)),
-		slog.Any("overdue", overdue))
+func (r *TodoPostgresRepository) GetAll(todoFilter filter.Filter, paginationParams pagination.Pagination) (todo.Todos, error) {
+	r.logger.Debug("Fetching all todos", slog.Any("tags", todoFilter.Tags),
+		slog.String("status", string(todoFilter.Status)), slog.String("priority", string(todoFilter.Priority)),
+		slog.Any("overdue", todoFilter.Overdue), slog.Any("project_id", todoFilter.ProjectID))
 
 	if paginationParams.Limit <= 0 || paginationParams.Offset < 0 {
 		r.logger.Warn("Invalid pagination parameters", slog.Int("pagination_offset", paginationParams.Offset),
 			slog.Int("pagination_limit", paginationParams.Limit))
 		return nil, fmt.Errorf("invalid pagination parameters: Offset must be >= 0 and Limit must be > 0")
 	}
-	query := "SELECT id, title, description, due_date, tags, priority, status, overdue FROM todos"
+	var definitions customfield.Definitions
+	if len(todoFilter.CustomFields) > 0 || strings.HasPrefix(todoFilter.Sort.Field, filter.CustomFieldPrefix) {
+		var err error
+		definitions, err = getCustomFieldDefinitions(r.db)
+		if err != nil {
+			r.logger.Error("Failed to fetch custom fields", slog.String("error", err.Error()))
+			return nil, err
+		}
+	}
+	conditions, params, err := todoFilterConditions(todoFilter, definitions)
+	if err != nil {
+		r.logger.Warn("Invalid filter", slog.String("error", err.Error()))
+		return nil, err
+	}
+	paramsCount := len(params) + 1
+
+	fieldset := todoFieldsRead(todoFilter)
+	query := "SELECT " + todoColumnsFor(fieldset) + " FROM todos"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if todoFilter.Sort.Field != "" {
+		orderBy, err := todoOrderBy(todoFilter.Sort, definitions)
+		if err != nil {
+			r.logger.Warn("Invalid sort", slog.String("sort", todoFilter.Sort.Field))
+			return nil, err
+		}
+		query += " ORDER BY " + orderBy
+	}
+
+	query += fmt.Sprintf(" LIMIT $%d", paramsCount)
+	params = append(params, paginationParams.Limit)
+	paramsCount++
+	query += fmt.Sprintf(" OFFSET $%d", paramsCount)
+	params = append(params, paginationParams.Offset)
+	paramsCount++
+
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("query", query), slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	var todos todo.Todos
+	for rows.Next() {
+		t, err := scanTodoFields(rows, fieldset)
+		if err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		todos = append(todos, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if err := embedTodoRelations(r.db, todos, todoFilter.Include); err != nil {
+		r.logger.Error("Failed to fetch related data", slog.Any("include", todoFilter.Include),
+			slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	r.logger.Debug("Todos fetched", slog.Int("count", len(todos)))
+	return todos, err
+}
+
+// todoFilterConditions translates a filter into WHERE conditions with
+// parameters numbered from $1. definitions must be set when the filter
+// includes custom fields.
+func todoFilterConditions(todoFilter filter.Filter, definitions customfield.Definitions) ([]string, []any, error) {
 	var conditions []string
 	var params []interface{}
 	paramsCount := 1
 
-	if len(tags) > 0 {
+	if len(todoFilter.Tags) > 0 {
 		var tagConditions []string
-		for _, tag := range tags {
+		for _, tag := range todoFilter.Tags {
 			if tag != "" {
 				tagConditions = append(tagConditions, fmt.Sprintf("$%d = ANY(tags)", paramsCount))
 				params = append(params, tag)
@@ -152,96 +563,90 @@ func (r *TodoPostgresRepository) GetAll(
 		}
 	}
 
-	if statusFilter != "" {
-		if !status.IsValidStatus(statusFilter) {
-			return nil, fmt.Errorf("invalid value field \"Status\": %s", statusFilter)
+	if todoFilter.Status != "" {
+		if !status.IsValidStatus(todoFilter.Status) {
+			return nil, nil, fmt.Errorf("invalid value field \"Status\": %s", todoFilter.Status)
 		}
 		conditions = append(conditions, fmt.Sprintf("status = $%d", paramsCount))
-		params = append(params, string(statusFilter))
+		params = append(params, string(todoFilter.Status))
 		paramsCount++
 	}
 
-	if priorityFilter != "" {
-		if !priority.IsValidPriority(priorityFilter) {
-			return nil, fmt.Errorf("invalid value field \"Priority\": %s", priorityFilter)
+	if todoFilter.Priority != "" {
+		if !priority.IsValidPriority(todoFilter.Priority) {
+			return nil, nil, fmt.Errorf("invalid value field \"Priority\": %s", todoFilter.Priority)
 		}
 		conditions = append(conditions, fmt.Sprintf("priority = $%d", paramsCount))
-		params = append(params, string(priorityFilter))
+		params = append(params, string(todoFilter.Priority))
 		paramsCount++
 	}
 
-	if overdue != nil {
+	if todoFilter.Overdue != nil {
 		conditions = append(conditions, fmt.Sprintf("overdue = $%d", paramsCount))
-		params = append(params, *overdue)
+		params = append(params, *todoFilter.Overdue)
 		paramsCount++
 	}
 
-	if dueDate.Valid {
+	if todoFilter.DueDate.Valid {
 		conditions = append(conditions, fmt.Sprintf("due_date = $%d", paramsCount))
-		params = append(params, dueDate.Time.UTC().Format(time.DateOnly))
+		params = append(params, todoFilter.DueDate.Time.Format(time.DateOnly))
 		paramsCount++
 	}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	if todoFilter.ProjectID != nil {
+		conditions = append(conditions, fmt.Sprintf("project_id = $%d", paramsCount))
+		params = append(params, *todoFilter.ProjectID)
+		paramsCount++
+	} else if todoFilter.WithoutProject {
+		conditions = append(conditions, "project_id IS NULL")
 	}
 
-	query += fmt.Sprintf(" LIMIT $%d", paramsCount)
-	params = append(params, paginationParams.Limit)
-	paramsCount++
-	query += fmt.Sprintf(" OFFSET $%d", paramsCount)
-	params = append(params, paginationParams.Offset)
-	paramsCount++
-
-	rows, err := r.db.Query(query, params...)
-	if err != nil {
-		r.logger.Error("Query failed", slog.String("query", query), slog.String("error", err.Error()))
-		return nil, err
+	if todoFilter.AssigneeID != nil {
+		conditions = append(conditions, fmt.Sprintf("assignee_id = $%d", paramsCount))
+		params = append(params, *todoFilter.AssigneeID)
+		paramsCount++
+	} else if todoFilter.WithoutAssignee {
+		conditions = append(conditions, "assignee_id IS NULL")
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
-		}
-	}()
 
-	var todos todo.Todos
-	for rows.Next() {
-		t := todo.Todo{}
-		var dueDate sql.NullTime
-		err := rows.Scan(
-			&t.ID,
-			&t.Title,
-			&t.Description,
-			&dueDate,
-			pq.Array(&t.Tags),
-			&t.Priority,
-			&t.Status,
-			&t.Overdue,
-		)
-		if err != nil {
-			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
-			return nil, err
+	if todoFilter.Archived != nil {
+		if *todoFilter.Archived {
+			conditions = append(conditions, "archived_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "archived_at IS NULL")
 		}
-		if dueDate.Valid {
-			t.DueDate = todo.NullTime{
-				Time:  dueDate.Time.UTC(),
-				Valid: true,
-			}
+	}
+
+	if todoFilter.Available != nil {
+		if *todoFilter.Available {
+			conditions = append(conditions, "(defer_until IS NULL OR defer_until <= now())")
 		} else {
-			t.DueDate = todo.NullTime{
-				Valid: false,
-			}
+			conditions = append(conditions, "defer_until > now()")
 		}
-		todos = append(todos, &t)
 	}
 
-	if err := rows.Err(); err != nil {
-		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
-		return nil, err
+	if len(todoFilter.CustomFields) > 0 {
+		contained := customfield.Values{}
+		for key, rawValue := range todoFilter.CustomFields {
+			definition := definitions.Find(key)
+			if definition == nil {
+				return nil, nil, fmt.Errorf("unknown custom field %q", key)
+			}
+			value, err := definition.ParseFilterValue(rawValue)
+			if err != nil {
+				return nil, nil, err
+			}
+			contained[key] = value
+		}
+		encoded, err := json.Marshal(contained)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("custom_fields @> $%d::jsonb", paramsCount))
+		params = append(params, string(encoded))
 	}
 
-	r.logger.Debug("Todos fetched", slog.Int("count", len(todos)))
-	return todos, err
+	return conditions, params, nil
 }
 
 func (r *TodoPostgresRepository) Update(todo *todo.Todo) error {
@@ -250,20 +655,15 @@ func (r *TodoPostgresRepository) Update(todo *todo.Todo) error {
 		r.logger.Warn("Missing ID for update")
 		return errors.New("absent id")
 	}
-	if !priority.IsValidPriority(todo.Priority) {
-		r.logger.Warn("Invalid priority", slog.String("priority", string(todo.Priority)))
-		return errors.New("invalid priority")
-	}
-	if !status.IsValidStatus(todo.Status) {
-		r.logger.Warn("Invalid status", slog.String("status", string(todo.Status)))
-		return errors.New("invalid status")
+	if err := todo.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
 	}
 
-	var utcDueDate any
-	if todo.DueDate.Valid {
-		utcDueDate = todo.DueDate.Time.UTC().Format(time.DateOnly)
-	} else {
-		utcDueDate = nil
+	dueDate, dueAt, err := dueDateColumns(todo)
+	if err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return err
 	}
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -279,47 +679,640 @@ func (r *TodoPostgresRepository) Update(todo *todo.Todo) error {
 		}
 	}()
 
-	res, err := tx.Exec(
-		"UPDATE todos set title = $1, description = $2, due_date = $3, tags = $4, priority = $5,"+
-			" status = $6, overdue = $7 WHERE id = $8",
+	customFields, err := validateCustomFields(tx, todo)
+	if err != nil {
+		r.logger.Warn("Custom field validation failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	var archivedAt sql.NullTime
+	// Rank and archived state aren't updatable here; they change through
+	// Move and SetArchived. The creator never changes. terminal_at restarts whenever the todo enters a
+	// terminal status.
+	err = tx.QueryRow(
+		"UPDATE todos set title = $1, description = $2, due_date = $3, due_at = $4, time_zone = $5, defer_until = $6,"+
+			" tags = $7, priority = $8, status = $9, overdue = $10, project_id = $11, assignee_id = $12,"+
+			" estimate_seconds = $13, custom_fields = $14, terminal_at = CASE WHEN NOT $16 THEN NULL"+
+			" WHEN status = $9 AND terminal_at IS NOT NULL THEN terminal_at ELSE now() END"+
+			" WHERE id = $15 RETURNING rank, archived_at, created_by, version, updated_at",
 		todo.Title,
 		todo.Description,
-		utcDueDate,
+		dueDate,
+		dueAt,
+		todo.TimeZone,
+		todo.DeferUntil,
 		pq.Array(todo.Tags),
 		todo.Priority,
 		todo.Status,
 		todo.Overdue,
+		todo.ProjectID,
+		todo.AssigneeID,
+		todo.EstimateSeconds,
+		customFields,
 		todo.ID,
-	)
+		status.IsTerminal(todo.Status),
+	).Scan(&todo.Rank, &archivedAt, &todo.CreatedBy, &todo.Version, &todo.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Update failed: no rows affected", slog.Int("id", todo.ID))
+		err = ErrRecordNotFound
+		return err
+	}
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			err = r.todoReferenceError(todo, err)
+			return err
+		}
 		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
 		return err
 	}
+	todo.ArchivedAt = nullTimeToPtr(archivedAt)
+	todo.UpdatedAt = todo.UpdatedAt.UTC()
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return err
+	}
+	r.logger.Debug("Todo updated", slog.Int("ID", todo.ID))
+	return nil
+}
+
+// todoPatchableFields lists the JSON fields of a todo that Patch may change,
+// in the order their columns are written. The remaining fields are
+// maintained by the server.
+var todoPatchableFields = []string{
+	"title", "description", "due_date", "time_zone", "defer_until", "tags", "priority", "status", "overdue",
+	"project_id", "assignee_id", "estimate_seconds", "custom_fields",
+}
+
+// Patch applies patch to the JSON form of a todo and writes back only the
+// columns of the fields that changed. The row stays locked from reading to
+// writing, so concurrent patches don't lose each other's changes. It returns
+// the updated todo.
+func (r *TodoPostgresRepository) Patch(id int, patch func(document []byte) ([]byte, error)) (*todo.Todo, error) {
+	r.logger.Debug("Patching todo", slog.Int("ID", id))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	updated, err := r.patchInTx(tx, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	r.logger.Debug("Todo patched", slog.Int("ID", id))
+	return updated, nil
+}
+
+// patchInTx does the work of Patch within tx and returns the updated todo.
+func (r *TodoPostgresRepository) patchInTx(tx *sql.Tx, id int, patch func(document []byte) ([]byte, error)) (*todo.Todo, error) {
+	current, err := r.getByIdInTx(tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	document, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	patchedDocument, err := patch(document)
+	if err != nil {
+		r.logger.Warn("Failed to apply patch", slog.String("error", err.Error()))
+		return nil, err
+	}
+	patched := &todo.Todo{}
+	decoder := json.NewDecoder(bytes.NewReader(patchedDocument))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(patched); err != nil {
+		r.logger.Warn("Invalid patched todo", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("invalid patched todo: %w", err)
+	}
+	changed, err := changedTodoFields(current, patched)
+	if err != nil {
+		r.logger.Warn("Invalid patch", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return current, nil
+	}
+	if err = patched.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	var assignments []string
+	var params []any
+	assign := func(column string, value any) {
+		params = append(params, value)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(params)))
+	}
+	for _, field := range todoPatchableFields {
+		if !changed[field] {
+			continue
+		}
+		switch field {
+		case "title":
+			assign("title", patched.Title)
+		case "description":
+			assign("description", patched.Description)
+		case "due_date", "time_zone":
+			if field == "time_zone" && changed["due_date"] {
+				continue
+			}
+			dueDate, dueAt, err := dueDateColumns(patched)
+			if err != nil {
+				r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+				return nil, err
+			}
+			assign("due_date", dueDate)
+			assign("due_at", dueAt)
+			assign("time_zone", patched.TimeZone)
+		case "defer_until":
+			assign("defer_until", patched.DeferUntil)
+		case "tags":
+			assign("tags", pq.Array(patched.Tags))
+		case "priority":
+			assign("priority", patched.Priority)
+		case "status":
+			assign("status", patched.Status)
+			params = append(params, status.IsTerminal(patched.Status))
+			assignments = append(assignments, fmt.Sprintf("terminal_at = CASE WHEN $%d THEN now() END", len(params)))
+		case "overdue":
+			assign("overdue", patched.Overdue)
+		case "project_id":
+			assign("project_id", patched.ProjectID)
+		case "assignee_id":
+			assign("assignee_id", patched.AssigneeID)
+		case "estimate_seconds":
+			assign("estimate_seconds", patched.EstimateSeconds)
+		case "custom_fields":
+			customFields, err := validateCustomFields(tx, patched)
+			if err != nil {
+				r.logger.Warn("Custom field validation failed", slog.String("error", err.Error()))
+				return nil, err
+			}
+			assign("custom_fields", customFields)
+		}
+	}
+
+	params = append(params, id)
+	query := fmt.Sprintf("UPDATE todos SET %s WHERE id = $%d", strings.Join(assignments, ", "), len(params))
+	if _, err = tx.Exec(query, params...); err != nil {
+		if isForeignKeyViolation(err) {
+			err = r.todoReferenceError(patched, err)
+			return nil, err
+		}
+		r.logger.Error("Failed to execute update", slog.String("query", query), slog.String("error", err.Error()))
+		return nil, err
+	}
+	r.logger.Debug("Todo fields changed", slog.Int("ID", id), slog.Any("fields", changed))
+	return r.getByIdInTx(tx, id, false)
+}
+
+// BulkUpdate applies update to the todos listed in ids or, when ids is nil,
+// to every todo matching todoFilter. Each todo is changed like Patch would,
+// and the result reports the outcome for every one of them.
+func (r *TodoPostgresRepository) BulkUpdate(ids []int, todoFilter filter.Filter, update todo.BulkUpdate,
+	options todo.BulkOptions) (*todo.BulkResult, error) {
+	r.logger.Debug("Bulk updating todos", slog.Any("ids", ids), slog.Any("update", update),
+		slog.Any("options", options))
+	if err := update.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	if ids == nil {
+		if ids, err = r.filteredTodoIds(tx, todoFilter); err != nil {
+			return nil, err
+		}
+	} else {
+		seen := make(map[int]bool, len(ids))
+		ids = slices.DeleteFunc(slices.Clone(ids), func(id int) bool {
+			duplicate := seen[id]
+			seen[id] = true
+			return duplicate
+		})
+	}
+	if len(ids) > todo.MaxBulkSize {
+		r.logger.Warn("Too many todos for bulk update", slog.Int("count", len(ids)))
+		err = fmt.Errorf("a bulk update may change at most %d todos, %d match", todo.MaxBulkSize, len(ids))
+		return nil, err
+	}
+
+	apply := func(document []byte) ([]byte, error) {
+		var t todo.Todo
+		if err := json.Unmarshal(document, &t); err != nil {
+			return nil, err
+		}
+		update.Apply(&t)
+		return json.Marshal(&t)
+	}
+	result := &todo.BulkResult{Matched: len(ids), DryRun: options.DryRun, Items: make([]todo.BulkItemResult, 0, len(ids))}
+	for n, id := range ids {
+		// Without atomicity a failed todo only rolls back to its savepoint.
+		if !options.Atomic {
+			if _, err = tx.Exec("SAVEPOINT bulk_item"); err != nil {
+				r.logger.Error("Failed to create savepoint", slog.String("error", err.Error()))
+				return nil, err
+			}
+		}
+		if _, itemErr := r.patchInTx(tx, id, apply); itemErr != nil {
+			item := todo.BulkItemResult{ID: id, Status: todo.BulkFailed, Error: itemErr.Error()}
+			if errors.Is(itemErr, ErrRecordNotFound) {
+				item.Status = todo.BulkNotFound
+			}
+			result.Items = append(result.Items, item)
+			result.Failed++
+			if options.Atomic {
+				for i := range result.Items[:len(result.Items)-1] {
+					result.Items[i].Status = todo.BulkRolledBack
+				}
+				for _, id := range ids[n+1:] {
+					result.Items = append(result.Items, todo.BulkItemResult{ID: id, Status: todo.BulkNotAttempted})
+				}
+				result.Updated = 0
+				err = ErrBulkRolledBack
+				return result, err
+			}
+			if _, err = tx.Exec("ROLLBACK TO SAVEPOINT bulk_item"); err != nil {
+				r.logger.Error("Failed to roll back to savepoint", slog.String("error", err.Error()))
+				return nil, err
+			}
+			continue
+		}
+		result.Items = append(result.Items, todo.BulkItemResult{ID: id, Status: todo.BulkUpdated})
+		result.Updated++
+	}
+
+	if options.DryRun {
+		if err = tx.Rollback(); err != nil {
+			r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			return nil, err
+		}
+		r.logger.Debug("Bulk update previewed", slog.Int("matched", result.Matched))
+		return result, nil
+	}
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	r.logger.Debug("Todos bulk updated", slog.Int("updated", result.Updated), slog.Int("failed", result.Failed))
+	return result, nil
+}
+
+// filteredTodoIds returns the ids of all todos matching todoFilter.
+func (r *TodoPostgresRepository) filteredTodoIds(tx *sql.Tx, todoFilter filter.Filter) ([]int, error) {
+	var definitions customfield.Definitions
+	if len(todoFilter.CustomFields) > 0 {
+		var err error
+		definitions, err = getCustomFieldDefinitions(tx)
+		if err != nil {
+			r.logger.Error("Failed to fetch custom fields", slog.String("error", err.Error()))
+			return nil, err
+		}
+	}
+	conditions, params, err := todoFilterConditions(todoFilter, definitions)
+	if err != nil {
+		r.logger.Warn("Invalid filter", slog.String("error", err.Error()))
+		return nil, err
+	}
+	query := "SELECT id FROM todos"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := tx.Query(query+" ORDER BY id", params...)
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("query", query), slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// getByIdInTx reads a todo with its attachments within tx, optionally
+// locking the row.
+func (r *TodoPostgresRepository) getByIdInTx(tx *sql.Tx, id int, forUpdate bool) (*todo.Todo, error) {
+	query := "SELECT " + todoColumns + " FROM todos WHERE id = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	t, err := scanTodo(tx.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Record not found", slog.Int("id", id))
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch todo", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if t.Attachments, err = getAttachmentsByTodoId(tx, id); err != nil {
+		r.logger.Error("Failed to fetch attachments", slog.Int("id", id), slog.String("error", err.Error()))
+		return nil, err
+	}
+	return t, nil
+}
+
+// Duplicate copies a todo, and optionally references to its attachments, in
+// one transaction and returns the copy. The copy is ranked last and starts
+// unsnoozed and unarchived; comments and time entries stay with the original.
+// Copied attachments share the original's blobs.
+func (r *TodoPostgresRepository) Duplicate(id int, options todo.DuplicateOptions) (*todo.Todo, error) {
+	r.logger.Debug("Duplicating todo", slog.Int("ID", id), slog.Any("options", options))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	duplicate, err := scanTodo(tx.QueryRow("SELECT "+todoColumns+" FROM todos WHERE id = $1 FOR SHARE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Record not found", slog.Int("id", id))
+		err = ErrRecordNotFound
+		return nil, err
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch todo", slog.String("error", err.Error()))
+		return nil, err
+	}
 
+	duplicate.ID = 0
+	duplicate.DeferUntil = nil
+	duplicate.ArchivedAt = nil
+	duplicate.CommentsCount = 0
+	duplicate.LoggedSeconds = 0
+	duplicate.CreatedBy = options.CreatedBy
+	if !options.Tags {
+		duplicate.Tags = nil
+	}
+	if !options.Description {
+		duplicate.Description = ""
+	}
+	if options.ResetStatus {
+		duplicate.Status = status.Planned
+	}
+	duplicate.ShiftDueDate(options.DueShiftDays)
+	if err = duplicate.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if err = r.insertTodo(tx, duplicate); err != nil {
+		return nil, err
+	}
+
+	if options.Attachments {
+		_, err = tx.Exec(
+			"INSERT INTO attachments (todo_id, file_name, content_type, size, checksum, storage_key, created_at) "+
+				"SELECT $1, file_name, content_type, size, checksum, storage_key, created_at FROM attachments "+
+				"WHERE todo_id = $2 ORDER BY created_at, id FOR SHARE",
+			duplicate.ID,
+			id,
+		)
+		if err != nil {
+			r.logger.Error("Failed to copy attachments", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if duplicate.Attachments, err = getAttachmentsByTodoId(tx, duplicate.ID); err != nil {
+			r.logger.Error("Failed to fetch attachments", slog.String("error", err.Error()))
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	r.logger.Debug("Todo duplicated", slog.Int("ID", id), slog.Int("duplicate_id", duplicate.ID))
+	return duplicate, nil
+}
+
+// Snooze hides a todo from available lists until the given moment. A nil
+// until wakes it up immediately.
+func (r *TodoPostgresRepository) Snooze(id int, until *time.Time) error {
+	r.logger.Debug("Snoozing todo", slog.Int("ID", id), slog.Any("until", until))
+	var deferUntil any
+	if until != nil {
+		deferUntil = until.UTC()
+	}
+	res, err := r.db.Exec("UPDATE todos SET defer_until = $1 WHERE id = $2", deferUntil, id)
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return err
+	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
 		return err
 	}
-
 	if rowsAffected == 0 {
-		r.logger.Warn("Update failed: no rows affected", slog.Int("id", todo.ID))
-		return errors.New("updated failed")
+		r.logger.Warn("Update failed: no rows affected", slog.Int("id", id))
+		return ErrRecordNotFound
 	}
+	r.logger.Debug("Todo snoozed", slog.Int("ID", id))
+	return nil
+}
 
-	if err := tx.Commit(); err != nil {
+// Move places a todo right after the todo `after` and/or right before the
+// todo `before` by giving it a rank between theirs. Only the moved row is
+// rewritten unless the keys have grown too long, in which case all ranks are
+// rebalanced first.
+func (r *TodoPostgresRepository) Move(id int, after, before *int) (string, error) {
+	r.logger.Debug("Moving todo", slog.Int("ID", id), slog.Any("after", after), slog.Any("before", before))
+	if after == nil && before == nil {
+		r.logger.Warn("No neighbours provided for move")
+		return "", errors.New("after or before is required")
+	}
+	if (after != nil && *after == id) || (before != nil && *before == id) {
+		r.logger.Warn("Todo moved relative to itself", slog.Int("ID", id))
+		return "", errors.New("a todo cannot be moved relative to itself")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			r.logger.Warn("Rolling back transaction", slog.String("error", err.Error()))
+			if err = tx.Rollback(); err != nil {
+				r.logger.Error("Failed to rollback transaction", slog.String("error", err.Error()))
+			}
+		}
+	}()
+
+	if _, err = tx.Exec("SELECT pg_advisory_xact_lock($1)", rankLockKey); err != nil {
+		r.logger.Error("Failed to lock ranks", slog.String("error", err.Error()))
+		return "", err
+	}
+	var exists bool
+	if err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1)", id).Scan(&exists); err != nil {
+		r.logger.Error("Failed to check todo", slog.String("error", err.Error()))
+		return "", err
+	}
+	if !exists {
+		r.logger.Warn("Record not found", slog.Int("id", id))
+		err = ErrRecordNotFound
+		return "", err
+	}
+
+	newRank, err := r.rankBetweenNeighbours(tx, id, after, before)
+	if err != nil {
+		return "", err
+	}
+	if len(newRank) > rank.MaxLength {
+		if err = r.rebalanceRanks(tx); err != nil {
+			return "", err
+		}
+		if newRank, err = r.rankBetweenNeighbours(tx, id, after, before); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err = tx.Exec("UPDATE todos SET rank = $1 WHERE id = $2", newRank, id); err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return "", err
+	}
+	if err = tx.Commit(); err != nil {
 		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return "", err
+	}
+	r.logger.Debug("Todo moved", slog.Int("ID", id), slog.String("rank", newRank))
+	return newRank, nil
+}
+
+// SetArchived archives or unarchives a todo. Unarchiving restarts the
+// countdown of a todo in a terminal status, so ArchiveCompleted doesn't
+// archive it again right away.
+func (r *TodoPostgresRepository) SetArchived(id int, archived bool) error {
+	r.logger.Debug("Setting todo archived flag", slog.Int("ID", id), slog.Bool("archived", archived))
+	query := "UPDATE todos SET archived_at = COALESCE(archived_at, now()) WHERE id = $1"
+	if !archived {
+		query = "UPDATE todos SET archived_at = NULL, " +
+			"terminal_at = CASE WHEN terminal_at IS NOT NULL THEN now() END WHERE id = $1"
+	}
+	res, err := r.db.Exec(query, id)
+	if err != nil {
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
 		return err
 	}
-	r.logger.Debug("Todo updated", slog.Int("ID", todo.ID))
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Update failed: no rows affected", slog.Int("id", id))
+		return ErrRecordNotFound
+	}
 	return nil
 }
 
-func (r *TodoPostgresRepository) Delete(ids []int) error {
+// Reassign sets the assignee of the given todos in one statement and returns
+// how many todos were changed. A nil assigneeId unassigns them.
+func (r *TodoPostgresRepository) Reassign(ids []int, assigneeId *int) (int, error) {
+	r.logger.Debug("Reassigning todos", slog.Any("ids", ids), slog.Any("assignee_id", assigneeId))
+	if len(ids) == 0 {
+		r.logger.Warn("No IDs provided for reassign")
+		return 0, errors.New("no ids provided for reassign")
+	}
+
+	todoIds := make([]int64, len(ids))
+	for i, id := range ids {
+		todoIds[i] = int64(id)
+	}
+	res, err := r.db.Exec("UPDATE todos SET assignee_id = $1 WHERE id = ANY($2)", assigneeId, pq.Array(todoIds))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			r.logger.Warn("User not found", slog.Any("assignee_id", assigneeId))
+			return 0, ErrUserNotFound
+		}
+		r.logger.Error("Failed to execute update", slog.String("error", err.Error()))
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return 0, err
+	}
+	r.logger.Debug("Todos reassigned", slog.Int64("count", rowsAffected))
+	return int(rowsAffected), nil
+}
+
+// ArchiveCompleted archives todos that have been in a terminal status for
+// longer than after and returns how many were archived.
+func (r *TodoPostgresRepository) ArchiveCompleted(after time.Duration) (int, error) {
+	r.logger.Debug("Archiving completed todos", slog.Duration("after", after))
+	res, err := r.db.Exec(
+		"UPDATE todos SET archived_at = now() "+
+			"WHERE archived_at IS NULL AND terminal_at < now() - make_interval(secs => $1)",
+		after.Seconds(),
+	)
+	if err != nil {
+		r.logger.Error("Failed to archive todos", slog.String("error", err.Error()))
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return 0, err
+	}
+	return int(rowsAffected), nil
+}
+
+// Delete removes the todos with their comments, attachments and time entries.
+// It returns the storage keys of the deleted attachments that no remaining
+// attachment references, whose blobs the caller should remove; duplicated
+// todos share blobs.
+func (r *TodoPostgresRepository) Delete(ids []int) ([]string, error) {
 	r.logger.Debug("Attempting to delete todo", slog.Any("ids", ids))
 	if len(ids) == 0 {
 		r.logger.Warn("No IDs provided for deletion")
-		return errors.New("no ids provided for deletion")
+		return nil, errors.New("no ids provided for deletion")
 	}
 
 	placeholders := make([]string, len(ids))
@@ -334,7 +1327,7 @@ func (r *TodoPostgresRepository) Delete(ids []int) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		r.logger.Error("Failed to begin transaction", slog.String("error", err.Error()))
-		return err
+		return nil, err
 	}
 	defer func() {
 		if err != nil {
@@ -344,27 +1337,542 @@ func (r *TodoPostgresRepository) Delete(ids []int) error {
 			}
 		}
 	}()
+
+	storageKeys, err := lockStorageKeys(tx, ids)
+	if err != nil {
+		r.logger.Error("Failed to lock attachments", slog.String("error", err.Error()))
+		return nil, err
+	}
 	res, err := tx.Exec(query, params...)
 	if err != nil {
 		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
-		return err
+		return nil, err
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
-		return err
+		return nil, err
 	}
 
 	if rowsAffected == 0 {
 		r.logger.Warn("Delete failed: no rows affected", slog.Any("ids", ids))
-		return errors.New("deleted failed")
+		err = ErrRecordNotFound
+		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
+	unreferenced, err := unreferencedStorageKeys(tx, storageKeys)
+	if err != nil {
+		r.logger.Error("Failed to check storage keys", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
 		r.logger.Error("Failed to commit transaction", slog.String("error", err.Error()))
+		return nil, err
+	}
+	r.logger.Debug("Todos deleted successfully", slog.Any("ids", ids), slog.Int("unreferenced_blobs", len(unreferenced)))
+	return unreferenced, nil
+}
+
+// lockStorageKeys returns the storage keys of the attachments of the todos and
+// locks every attachment sharing them, so that a concurrent Duplicate can't
+// add a reference unseen.
+func lockStorageKeys(tx *sql.Tx, todoIds []int) ([]string, error) {
+	rows, err := tx.Query(
+		"SELECT DISTINCT storage_key FROM attachments WHERE todo_id = ANY($1) ORDER BY storage_key",
+		pq.Array(todoIds),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var storageKeys []string
+	for rows.Next() {
+		var storageKey string
+		if err := rows.Scan(&storageKey); err != nil {
+			return nil, err
+		}
+		storageKeys = append(storageKeys, storageKey)
+	}
+	if err := rows.Err(); err != nil || len(storageKeys) == 0 {
+		return nil, err
+	}
+	_, err = tx.Exec(
+		"SELECT id FROM attachments WHERE storage_key = ANY($1) ORDER BY id FOR UPDATE",
+		pq.Array(storageKeys),
+	)
+	return storageKeys, err
+}
+
+// unreferencedStorageKeys returns the storage keys no attachment references.
+// A new statement sees duplicates committed while waiting for the locks.
+func unreferencedStorageKeys(tx *sql.Tx, storageKeys []string) ([]string, error) {
+	if len(storageKeys) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query(
+		"SELECT candidate FROM unnest($1::text[]) AS candidate "+
+			"WHERE NOT EXISTS (SELECT 1 FROM attachments WHERE storage_key = candidate) ORDER BY candidate",
+		pq.Array(storageKeys),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var unreferenced []string
+	for rows.Next() {
+		var storageKey string
+		if err := rows.Scan(&storageKey); err != nil {
+			return nil, err
+		}
+		unreferenced = append(unreferenced, storageKey)
+	}
+	return unreferenced, rows.Err()
+}
+
+// validateCustomFields checks the todo's custom field values against the
+// current definitions, replaces them with their normalized form and returns
+// them encoded for the jsonb column.
+func validateCustomFields(q querier, t *todo.Todo) (string, error) {
+	definitions, err := getCustomFieldDefinitions(q)
+	if err != nil {
+		return "", err
+	}
+	values, err := definitions.ValidateValues(t.CustomFields)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	t.CustomFields = values
+	return string(encoded), nil
+}
+
+// todoOrderBy builds the ORDER BY clause for a sort. Number fields are
+// compared numerically, other custom fields by their text; todos without
+// the field come last in both directions.
+func todoOrderBy(sort filter.Sort, definitions customfield.Definitions) (string, error) {
+	var expressions []string
+	if key, ok := strings.CutPrefix(sort.Field, filter.CustomFieldPrefix); ok {
+		definition := definitions.Find(key)
+		if definition == nil {
+			return "", fmt.Errorf("unknown custom field %q", key)
+		}
+		expression := "custom_fields->>" + pq.QuoteLiteral(key)
+		if definition.Type == customfield.Number {
+			expression = "(" + expression + ")::numeric"
+		}
+		expressions = []string{expression}
+	} else {
+		columns, ok := todoSortColumns[sort.Field]
+		if !ok {
+			return "", fmt.Errorf("invalid sort field: %s", sort.Field)
+		}
+		expressions = columns
+	}
+
+	direction := "ASC"
+	if sort.Descending {
+		direction = "DESC"
+	}
+	var orderBy []string
+	for _, expression := range expressions {
+		orderBy = append(orderBy, expression+" "+direction+" NULLS LAST")
+	}
+	return strings.Join(orderBy, ", ") + ", id " + direction, nil
+}
+
+// dueDateColumns splits the due date into the calendar date and, for timed
+// due dates, the exact instant. The date of a timed due date is the one in
+// the todo's time zone; all-day dates keep the date of Time as given rather
+// than the date of Time.UTC().
+func dueDateColumns(t *todo.Todo) (any, any, error) {
+	if !t.DueDate.Valid {
+		return nil, nil, nil
+	}
+	if !t.DueDate.HasTime {
+		return t.DueDate.Time.Format(time.DateOnly), nil, nil
+	}
+	location, err := t.Location()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w field \"TimeZone\": %s", ErrInvalidValue, t.TimeZone)
+	}
+	return t.DueDate.Time.In(location).Format(time.DateOnly), t.DueDate.Time.UTC(), nil
+}
+
+// changedTodoFields compares the JSON forms of two todos and returns the
+// fields that differ. Changing a field Patch can't write is an error.
+func changedTodoFields(before, after *todo.Todo) (map[string]bool, error) {
+	beforeFields, err := todoJSONFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := todoJSONFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := map[string]bool{}
+	for _, fields := range []map[string]json.RawMessage{beforeFields, afterFields} {
+		for field := range fields {
+			if bytes.Equal(beforeFields[field], afterFields[field]) || changed[field] {
+				continue
+			}
+			if !slices.Contains(todoPatchableFields, field) {
+				return nil, fmt.Errorf("field %q cannot be changed", field)
+			}
+			changed[field] = true
+		}
+	}
+	return changed, nil
+}
+
+func todoJSONFields(t *todo.Todo) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// todoReferenceError maps a foreign key violation on a todo to the missing
+// project, user, status or priority. The last two only happen when another
+// process removed the value after this one loaded its registry.
+func (r *TodoPostgresRepository) todoReferenceError(todo *todo.Todo, err error) error {
+	switch violatedConstraint(err) {
+	case "todos_status_fkey":
+		r.logger.Warn("Status not found", slog.String("status", string(todo.Status)))
+		return fmt.Errorf("%w field \"Status\": %s", ErrInvalidValue, todo.Status)
+	case "todos_priority_fkey":
+		r.logger.Warn("Priority not found", slog.String("priority", string(todo.Priority)))
+		return fmt.Errorf("%w field \"Priority\": %s", ErrInvalidValue, todo.Priority)
+	case "todos_assignee_id_fkey":
+		r.logger.Warn("User not found", slog.Any("assignee_id", todo.AssigneeID))
+		return ErrUserNotFound
+	case "todos_created_by_fkey":
+		r.logger.Warn("User not found", slog.Any("created_by", todo.CreatedBy))
+		return ErrUserNotFound
+	default:
+		r.logger.Warn("Project not found", slog.Any("project_id", todo.ProjectID))
+		return ErrProjectNotFound
+	}
+}
+
+func scanTodo(row rowScanner) (*todo.Todo, error) {
+	return scanTodoFields(row, nil)
+}
+
+// scanTodoFields scans a row selected with todoColumnsFor(fieldset).
+func scanTodoFields(row rowScanner, fieldset todo.Fieldset) (*todo.Todo, error) {
+	t := &todo.Todo{}
+	var dueDate, dueAt, deferUntil, archivedAt sql.NullTime
+	var customFields []byte
+	destinations := map[string]any{
+		"id":               &t.ID,
+		"title":            &t.Title,
+		"description":      &t.Description,
+		"due_date":         &dueDate,
+		"due_at":           &dueAt,
+		"time_zone":        &t.TimeZone,
+		"defer_until":      &deferUntil,
+		"rank":             &t.Rank,
+		"archived_at":      &archivedAt,
+		"tags":             pq.Array(&t.Tags),
+		"priority":         &t.Priority,
+		"status":           &t.Status,
+		"overdue":          &t.Overdue,
+		"project_id":       &t.ProjectID,
+		"parent_id":        &t.ParentID,
+		"assignee_id":      &t.AssigneeID,
+		"created_by":       &t.CreatedBy,
+		"estimate_seconds": &t.EstimateSeconds,
+		"custom_fields":    &customFields,
+		"comments_count":   &t.CommentsCount,
+		"logged_seconds":   &t.LoggedSeconds,
+		"version":          &t.Version,
+		"updated_at":       &t.UpdatedAt,
+	}
+	var scanned []any
+	for _, column := range todoColumnList {
+		if fieldset.Has(column.field) {
+			scanned = append(scanned, destinations[column.name])
+		}
+	}
+	if err := row.Scan(scanned...); err != nil {
+		return nil, err
+	}
+	t.UpdatedAt = t.UpdatedAt.UTC()
+
+	if dueAt.Valid {
+		location, err := t.Location()
+		if err != nil {
+			return nil, err
+		}
+		t.DueDate = todo.NullTime{
+			Time:    dueAt.Time.In(location),
+			Valid:   true,
+			HasTime: true,
+		}
+	} else if dueDate.Valid {
+		t.DueDate = todo.NullTime{
+			Time:  dueDate.Time.UTC(),
+			Valid: true,
+		}
+	} else {
+		t.DueDate = todo.NullTime{
+			Valid: false,
+		}
+	}
+	t.DeferUntil = nullTimeToPtr(deferUntil)
+	t.ArchivedAt = nullTimeToPtr(archivedAt)
+	if customFields != nil {
+		if err := json.Unmarshal(customFields, &t.CustomFields); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// embedTodoRelations sets the related data named by include on todos, with
+// one query for each kind of data.
+func embedTodoRelations(q querier, todos todo.Todos, include []string) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	todoIds := make([]int, len(todos))
+	todosById := make(map[int]*todo.Todo, len(todos))
+	var projectIds, userIds []int
+	for i, t := range todos {
+		todoIds[i] = t.ID
+		todosById[t.ID] = t
+		if t.ProjectID != nil {
+			projectIds = append(projectIds, *t.ProjectID)
+		}
+		if t.AssigneeID != nil {
+			userIds = append(userIds, *t.AssigneeID)
+		}
+	}
+
+	for _, related := range include {
+		switch related {
+		case todo.IncludeProject:
+			projects, err := queryRows(q, scanProject,
+				"SELECT "+projectColumns+" FROM projects WHERE id = ANY($1)", pq.Array(projectIds))
+			if err != nil {
+				return err
+			}
+			projectsById := make(map[int]*project.Project, len(projects))
+			for _, p := range projects {
+				projectsById[p.ID] = p
+			}
+			for _, t := range todos {
+				if t.ProjectID != nil {
+					t.Project = projectsById[*t.ProjectID]
+				}
+			}
+		case todo.IncludeAssignee:
+			users, err := queryRows(q, scanUser,
+				"SELECT "+userColumns+" FROM users WHERE id = ANY($1)", pq.Array(userIds))
+			if err != nil {
+				return err
+			}
+			usersById := make(map[int]*user.User, len(users))
+			for _, u := range users {
+				usersById[u.ID] = u
+			}
+			for _, t := range todos {
+				if t.AssigneeID != nil {
+					t.Assignee = usersById[*t.AssigneeID]
+				}
+			}
+		case todo.IncludeComments:
+			comments, err := queryRows(q, scanComment,
+				"SELECT id, todo_id, author_id, body, created_at, edited_at FROM comments "+
+					"WHERE todo_id = ANY($1) ORDER BY created_at, id", pq.Array(todoIds))
+			if err != nil {
+				return err
+			}
+			for _, c := range comments {
+				todosById[c.TodoID].Comments = append(todosById[c.TodoID].Comments, c)
+			}
+		case todo.IncludeAttachments:
+			attachments, err := queryRows(q, scanAttachment,
+				"SELECT "+attachmentColumns+" FROM attachments WHERE todo_id = ANY($1) ORDER BY created_at, id",
+				pq.Array(todoIds))
+			if err != nil {
+				return err
+			}
+			for _, a := range attachments {
+				todosById[a.TodoID].Attachments = append(todosById[a.TodoID].Attachments, a)
+			}
+		default:
+			return fmt.Errorf("unknown include %q", related)
+		}
+	}
+	return nil
+}
+
+// queryRows runs query and scans every row with scan.
+func queryRows[T any](q querier, scan func(rowScanner) (T, error), query string, args ...any) ([]T, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []T
+	for rows.Next() {
+		result, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// nextRank locks the ranks for the rest of the transaction and returns the
+// rank for a todo appended to the end of the list.
+func (r *TodoPostgresRepository) nextRank(tx *sql.Tx) (string, error) {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", rankLockKey); err != nil {
+		return "", err
+	}
+	last, err := neighbourRank(tx, "SELECT rank FROM todos ORDER BY rank DESC LIMIT 1")
+	if err != nil {
+		return "", err
+	}
+	next, err := rank.Between(last, "")
+	if err != nil || len(next) <= rank.MaxLength {
+		return next, err
+	}
+
+	if err := r.rebalanceRanks(tx); err != nil {
+		return "", err
+	}
+	if last, err = neighbourRank(tx, "SELECT rank FROM todos ORDER BY rank DESC LIMIT 1"); err != nil {
+		return "", err
+	}
+	return rank.Between(last, "")
+}
+
+// nextRanks returns n ascending ranks after the last todo, rebalancing first
+// if they would grow too long.
+func (r *TodoPostgresRepository) nextRanks(tx *sql.Tx, n int) ([]string, error) {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", rankLockKey); err != nil {
+		return nil, err
+	}
+	last, err := neighbourRank(tx, "SELECT rank FROM todos ORDER BY rank DESC LIMIT 1")
+	if err != nil {
+		return nil, err
+	}
+	ranks := rank.Append(last, n)
+	if len(ranks) == 0 || len(ranks[len(ranks)-1]) <= rank.MaxLength {
+		return ranks, nil
+	}
+
+	if err := r.rebalanceRanks(tx); err != nil {
+		return nil, err
+	}
+	if last, err = neighbourRank(tx, "SELECT rank FROM todos ORDER BY rank DESC LIMIT 1"); err != nil {
+		return nil, err
+	}
+	return rank.Append(last, n), nil
+}
+
+func (r *TodoPostgresRepository) rankBetweenNeighbours(tx *sql.Tx, id int, after, before *int) (string, error) {
+	var lower, upper string
+	var err error
+	if after != nil {
+		if lower, err = neighbourRank(tx, "SELECT rank FROM todos WHERE id = $1", *after); err != nil {
+			return "", err
+		}
+		if lower == "" {
+			r.logger.Warn("Neighbour not found", slog.Int("id", *after))
+			return "", fmt.Errorf("todo %d not found", *after)
+		}
+	}
+	if before != nil {
+		if upper, err = neighbourRank(tx, "SELECT rank FROM todos WHERE id = $1", *before); err != nil {
+			return "", err
+		}
+		if upper == "" {
+			r.logger.Warn("Neighbour not found", slog.Int("id", *before))
+			return "", fmt.Errorf("todo %d not found", *before)
+		}
+	}
+
+	switch {
+	case before == nil:
+		upper, err = neighbourRank(tx,
+			"SELECT rank FROM todos WHERE rank > $1 AND id <> $2 ORDER BY rank LIMIT 1", lower, id)
+	case after == nil:
+		lower, err = neighbourRank(tx,
+			"SELECT rank FROM todos WHERE rank < $1 AND id <> $2 ORDER BY rank DESC LIMIT 1", upper, id)
+	case lower >= upper:
+		return "", fmt.Errorf("todo %d is not ranked before todo %d", *after, *before)
+	}
+	if err != nil {
+		return "", err
+	}
+	return rank.Between(lower, upper)
+}
+
+// rebalanceRanks rewrites every rank with evenly spaced short keys, keeping
+// the current order.
+func (r *TodoPostgresRepository) rebalanceRanks(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id FROM todos ORDER BY rank, id")
+	if err != nil {
+		r.logger.Error("Failed to fetch ranks", slog.String("error", err.Error()))
 		return err
 	}
-	r.logger.Debug("Todos deleted successfully", slog.Any("ids", ids))
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE todos SET rank = v.rank FROM unnest($1::bigint[], $2::text[]) AS v(id, rank) WHERE todos.id = v.id",
+		pq.Array(ids),
+		pq.Array(rank.Spread(len(ids))),
+	)
+	if err != nil {
+		r.logger.Error("Failed to rebalance ranks", slog.String("error", err.Error()))
+		return err
+	}
+	r.logger.Info("Ranks rebalanced", slog.Int("count", len(ids)))
 	return nil
 }
+
+// neighbourRank returns the rank selected by query, or "" when there is no
+// such row.
+func neighbourRank(tx *sql.Tx, query string, args ...any) (string, error) {
+	var neighbour string
+	err := tx.QueryRow(query, args...).Scan(&neighbour)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return neighbour, err
+}
+
+func nullTimeToPtr(nt sql.NullTime) *time.Time {
+	if !nt.Valid {
+		return nil
+	}
+	utc := nt.Time.UTC()
+	return &utc
+}
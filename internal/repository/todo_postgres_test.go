@@ -2,15 +2,27 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/jsonpatch"
+	"github.com/GlebMoskalev/todo-api/internal/models/comment"
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
 	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
 	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/project"
 	"github.com/GlebMoskalev/todo-api/internal/models/status"
 	"github.com/GlebMoskalev/todo-api/internal/models/todo"
+	"github.com/GlebMoskalev/todo-api/internal/models/user"
+	"github.com/GlebMoskalev/todo-api/internal/rank"
+	"github.com/GlebMoskalev/todo-api/internal/validation"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -23,6 +35,16 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+func setupRepositoryTestDatabase(t *testing.T) (*sql.DB, *slog.Logger, func()) {
+	testDbName := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	testDb, err := SetupTestDatabase(masterTestDb.DbAddress, testDbName)
+	assert.NoError(t, err)
+	return testDb, slog.New(slog.NewTextHandler(io.Discard, nil)), func() {
+		testDb.Close()
+		TearDownTestDatabase(masterTestDb.DbAddress, testDbName)
+	}
+}
+
 func createTestTodo() *todo.Todo {
 	return &todo.Todo{
 		Title:       "test",
@@ -110,6 +132,46 @@ func TestCreateTodo(t *testing.T) {
 	}
 }
 
+func TestValidateTodo(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	invalid := createTestTodo()
+	invalid.Title = "  "
+	invalid.Description = strings.Repeat("a", todo.MaxDescriptionLength+1)
+	invalid.Tags = []string{"a,b"}
+	invalid.Priority = "urgent"
+	_, err := repo.Create(invalid)
+	var errs validation.Errors
+	assert.ErrorAs(t, err, &errs)
+	fields := make([]string, len(errs))
+	for i, fieldError := range errs {
+		fields[i] = fieldError.Field
+	}
+	assert.Equal(t, []string{"title", "description", "tags[0]", "priority"}, fields)
+
+	tooManyTags := createTestTodo()
+	tooManyTags.Tags = make([]string, todo.MaxTags+1)
+	for i := range tooManyTags.Tags {
+		tooManyTags.Tags[i] = fmt.Sprintf("tag%d", i)
+	}
+	_, err = repo.Create(tooManyTags)
+	assert.ErrorAs(t, err, &errs)
+
+	normalized := createTestTodo()
+	normalized.Tags = []string{" work ", "work", "", "home"}
+	id, err := repo.Create(normalized)
+	assert.NoError(t, err)
+	stored, err := repo.GetById(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"work", "home"}, stored.Tags)
+
+	stored.Title = ""
+	assert.ErrorAs(t, repo.Update(stored), &errs)
+}
+
 func TestGetByIdTodo(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -312,7 +374,7 @@ func TestDeleteTodo(t *testing.T) {
 			repo := TodoPostgresRepository{db: testDb, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
 
 			idsToDelete := tc.setup(&repo)
-			err = repo.Delete(idsToDelete)
+			_, err = repo.Delete(idsToDelete)
 			if tc.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -326,6 +388,39 @@ func TestDeleteTodo(t *testing.T) {
 	}
 }
 
+func TestDeleteTodoReturnsUnreferencedBlobs(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+	attachmentRepo := AttachmentPostgresRepository{db: testDb, logger: logger}
+
+	sharedId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	_, err = attachmentRepo.Create(createTestAttachment(sharedId, "shared"))
+	assert.NoError(t, err)
+	duplicate, err := repo.Duplicate(sharedId, todo.DuplicateOptions{Attachments: true})
+	assert.NoError(t, err)
+
+	ownId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	_, err = attachmentRepo.Create(createTestAttachment(ownId, "own"))
+	assert.NoError(t, err)
+
+	// The duplicate still references the shared blob.
+	storageKeys, err := repo.Delete([]int{sharedId})
+	assert.NoError(t, err)
+	assert.Empty(t, storageKeys)
+
+	storageKeys, err = repo.Delete([]int{ownId})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"own"}, storageKeys)
+
+	storageKeys, err = repo.Delete([]int{duplicate.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shared"}, storageKeys)
+}
+
 func TestGetAllTodos(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -348,9 +443,7 @@ func TestGetAllTodos(t *testing.T) {
 				return todo.Todos{todo1, todo2}
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{}, "", "", nil, todo.NullTime{
-					Valid: false,
-				}, pagination.Pagination{
+				todos, err := repo.GetAll(filter.Filter{}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
 				})
@@ -363,9 +456,7 @@ func TestGetAllTodos(t *testing.T) {
 				return todo.Todos{}
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{}, "", "", nil, todo.NullTime{
-					Valid: false,
-				}, pagination.Pagination{
+				todos, err := repo.GetAll(filter.Filter{}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
 				})
@@ -395,8 +486,8 @@ func TestGetAllTodos(t *testing.T) {
 				return todo.Todos{todo1, todo2}
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{}, status.InProgress, "", nil, todo.NullTime{
-					Valid: false,
+				todos, err := repo.GetAll(filter.Filter{
+					Status: status.InProgress,
 				}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
@@ -427,8 +518,8 @@ func TestGetAllTodos(t *testing.T) {
 				return todo.Todos{todo1, todo2}
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{}, "", priority.High, nil, todo.NullTime{
-					Valid: false,
+				todos, err := repo.GetAll(filter.Filter{
+					Priority: priority.High,
 				}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
@@ -442,8 +533,8 @@ func TestGetAllTodos(t *testing.T) {
 				return make(todo.Todos, 0)
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{}, "invalid status", "", nil, todo.NullTime{
-					Valid: false,
+				todos, err := repo.GetAll(filter.Filter{
+					Status: "invalid status",
 				}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
@@ -458,8 +549,8 @@ func TestGetAllTodos(t *testing.T) {
 				return make(todo.Todos, 0)
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{}, "", "invalid priority", nil, todo.NullTime{
-					Valid: false,
+				todos, err := repo.GetAll(filter.Filter{
+					Priority: "invalid priority",
 				}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
@@ -496,8 +587,9 @@ func TestGetAllTodos(t *testing.T) {
 				return todo.Todos{todo1, todo2, todo3}
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{"test", "api"}, "", priority.High, nil, todo.NullTime{
-					Valid: false,
+				todos, err := repo.GetAll(filter.Filter{
+					Tags:     []string{"test", "api"},
+					Priority: priority.High,
 				}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
@@ -526,8 +618,8 @@ func TestGetAllTodos(t *testing.T) {
 				return todo.Todos{todo1, todo2}
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{}, "", "", todo.BoolPtr(true), todo.NullTime{
-					Valid: false,
+				todos, err := repo.GetAll(filter.Filter{
+					Overdue: todo.BoolPtr(true),
 				}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
@@ -577,9 +669,11 @@ func TestGetAllTodos(t *testing.T) {
 				return todo.Todos{todo1, todo2, todo3}
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{}, "", "", nil, todo.NullTime{
-					Valid: true,
-					Time:  time.Date(2030, 12, 30, 0, 0, 0, 0, time.UTC),
+				todos, err := repo.GetAll(filter.Filter{
+					DueDate: todo.NullTime{
+						Valid: true,
+						Time:  time.Date(2030, 12, 30, 0, 0, 0, 0, time.UTC),
+					},
 				}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
@@ -614,8 +708,11 @@ func TestGetAllTodos(t *testing.T) {
 				return todo.Todos{todo1, todo2}
 			},
 			getAllTodos: func(repo *TodoPostgresRepository) (todo.Todos, error) {
-				todos, err := repo.GetAll([]string{"api"}, status.InProgress, priority.High, todo.BoolPtr(true), todo.NullTime{
-					Valid: false,
+				todos, err := repo.GetAll(filter.Filter{
+					Tags:     []string{"api"},
+					Status:   status.InProgress,
+					Priority: priority.High,
+					Overdue:  todo.BoolPtr(true),
 				}, pagination.Pagination{
 					Offset: pagination.DefaultOffset,
 					Limit:  pagination.DefaultLimit,
@@ -647,3 +744,665 @@ func TestGetAllTodos(t *testing.T) {
 		})
 	}
 }
+
+func TestTimedDueDate(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	// 00:30 in Berlin is still the previous day in UTC.
+	timed := createTestTodo()
+	timed.TimeZone = "Europe/Berlin"
+	timed.DueDate = todo.NullTime{
+		Time:    time.Date(2030, 12, 31, 0, 30, 0, 0, berlin),
+		Valid:   true,
+		HasTime: true,
+	}
+	timedId, err := repo.Create(timed)
+	assert.NoError(t, err)
+
+	// An all-day date built from a local midnight keeps its calendar date.
+	allDay := createTestTodo()
+	allDay.DueDate = todo.NullTime{
+		Time:  time.Date(2030, 12, 31, 0, 0, 0, 0, berlin),
+		Valid: true,
+	}
+	allDayId, err := repo.Create(allDay)
+	assert.NoError(t, err)
+
+	fetched, err := repo.GetById(timedId)
+	assert.NoError(t, err)
+	assert.True(t, fetched.DueDate.HasTime)
+	assert.True(t, timed.DueDate.Time.Equal(fetched.DueDate.Time))
+	assert.Equal(t, "Europe/Berlin", fetched.DueDate.Time.Location().String())
+
+	fetched, err = repo.GetById(allDayId)
+	assert.NoError(t, err)
+	assert.False(t, fetched.DueDate.HasTime)
+	assert.Equal(t, time.Date(2030, 12, 31, 0, 0, 0, 0, time.UTC), fetched.DueDate.Time)
+
+	todos, err := repo.GetAll(filter.Filter{
+		DueDate: todo.NullTime{Time: time.Date(2030, 12, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+		Sort:    filter.ParseSort("due_date"),
+	}, pagination.Pagination{Limit: pagination.DefaultLimit})
+	assert.NoError(t, err)
+	assert.Len(t, todos, 2)
+	assert.Equal(t, timedId, todos[0].ID)
+	assert.Equal(t, allDayId, todos[1].ID)
+
+	invalid := createTestTodo()
+	invalid.TimeZone = "Mars/Olympus_Mons"
+	_, err = repo.Create(invalid)
+	assert.Error(t, err)
+}
+
+func TestSnoozeTodo(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	availableId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	snoozedId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	expiredId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	assert.NoError(t, repo.Snooze(snoozedId, &until))
+	past := time.Now().Add(-time.Minute)
+	assert.NoError(t, repo.Snooze(expiredId, &past))
+	assert.ErrorIs(t, repo.Snooze(999, &until), ErrRecordNotFound)
+
+	fetched, err := repo.GetById(snoozedId)
+	assert.NoError(t, err)
+	assert.Equal(t, &until, fetched.DeferUntil)
+
+	idsOf := func(available *bool) []int {
+		todos, err := repo.GetAll(filter.Filter{Available: available}, pagination.Pagination{Limit: pagination.DefaultLimit})
+		assert.NoError(t, err)
+		var ids []int
+		for _, fetchedTodo := range todos {
+			ids = append(ids, fetchedTodo.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []int{availableId, expiredId}, idsOf(todo.BoolPtr(true)))
+	assert.ElementsMatch(t, []int{snoozedId}, idsOf(todo.BoolPtr(false)))
+	assert.ElementsMatch(t, []int{availableId, snoozedId, expiredId}, idsOf(nil))
+
+	assert.NoError(t, repo.Snooze(snoozedId, nil))
+	assert.ElementsMatch(t, []int{availableId, snoozedId, expiredId}, idsOf(todo.BoolPtr(true)))
+}
+
+func TestMoveTodo(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	var ids []int
+	for range 4 {
+		id, err := repo.Create(createTestTodo())
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	ranked := func() []int {
+		todos, err := repo.GetAll(filter.Filter{Sort: filter.ParseSort("rank")},
+			pagination.Pagination{Limit: pagination.DefaultLimit})
+		assert.NoError(t, err)
+		var rankedIds []int
+		for _, fetchedTodo := range todos {
+			rankedIds = append(rankedIds, fetchedTodo.ID)
+		}
+		return rankedIds
+	}
+	assert.Equal(t, ids, ranked())
+
+	_, err := repo.Move(ids[3], &ids[0], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{ids[0], ids[3], ids[1], ids[2]}, ranked())
+
+	_, err = repo.Move(ids[0], nil, &ids[2])
+	assert.NoError(t, err)
+	assert.Equal(t, []int{ids[3], ids[1], ids[0], ids[2]}, ranked())
+
+	_, err = repo.Move(ids[2], &ids[3], &ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, []int{ids[3], ids[2], ids[1], ids[0]}, ranked())
+
+	_, err = repo.Move(ids[0], &ids[0], nil)
+	assert.Error(t, err)
+	_, err = repo.Move(ids[0], &ids[1], &ids[3])
+	assert.Error(t, err)
+	_, err = repo.Move(ids[0], nil, nil)
+	assert.Error(t, err)
+	_, err = repo.Move(999, &ids[0], nil)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	// Moving back and forth into the same gap keeps halving it until the
+	// ranks are rebalanced.
+	for i := range 300 {
+		moved, target := ids[1], ids[2]
+		if i%2 == 1 {
+			moved, target = ids[2], ids[1]
+		}
+		newRank, err := repo.Move(moved, &ids[3], &target)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(newRank), rank.MaxLength)
+	}
+	assert.Equal(t, []int{ids[3], ids[2], ids[1], ids[0]}, ranked())
+}
+
+func TestArchiveTodos(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	activeTodo := createTestTodo()
+	activeTodo.Status = status.Planned
+	activeId, err := repo.Create(activeTodo)
+	assert.NoError(t, err)
+	completedTodo := createTestTodo()
+	completedTodo.Status = status.Completed
+	completedId, err := repo.Create(completedTodo)
+	assert.NoError(t, err)
+	manualId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.SetArchived(manualId, true))
+	assert.ErrorIs(t, repo.SetArchived(999, true), ErrRecordNotFound)
+	fetched, err := repo.GetById(manualId)
+	assert.NoError(t, err)
+	assert.NotNil(t, fetched.ArchivedAt)
+
+	idsOf := func(archived *bool) []int {
+		todos, err := repo.GetAll(filter.Filter{Archived: archived}, pagination.Pagination{Limit: pagination.DefaultLimit})
+		assert.NoError(t, err)
+		var ids []int
+		for _, fetchedTodo := range todos {
+			ids = append(ids, fetchedTodo.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []int{activeId, completedId}, idsOf(todo.BoolPtr(false)))
+	assert.ElementsMatch(t, []int{manualId}, idsOf(todo.BoolPtr(true)))
+	assert.ElementsMatch(t, []int{activeId, completedId, manualId}, idsOf(nil))
+
+	archived, err := repo.ArchiveCompleted(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, archived)
+	archived, err = repo.ArchiveCompleted(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, archived)
+	assert.ElementsMatch(t, []int{activeId}, idsOf(todo.BoolPtr(false)))
+
+	// Unarchiving restarts the countdown instead of archiving again at once.
+	assert.NoError(t, repo.SetArchived(completedId, false))
+	archived, err = repo.ArchiveCompleted(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, archived)
+	fetched, err = repo.GetById(completedId)
+	assert.NoError(t, err)
+	assert.Nil(t, fetched.ArchivedAt)
+}
+
+func TestAssignTodos(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+	userRepo := UserPostgresRepository{db: testDb, logger: logger}
+
+	adaId, err := userRepo.Create(&user.User{Name: "Ada", Email: "ada@example.com"})
+	assert.NoError(t, err)
+	graceId, err := userRepo.Create(&user.User{Name: "Grace", Email: "grace@example.com"})
+	assert.NoError(t, err)
+	missingId := 999
+
+	assignedTodo := createTestTodo()
+	assignedTodo.AssigneeID = &adaId
+	assignedTodo.CreatedBy = &graceId
+	assignedId, err := repo.Create(assignedTodo)
+	assert.NoError(t, err)
+	unassignedId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+
+	invalidTodo := createTestTodo()
+	invalidTodo.AssigneeID = &missingId
+	_, err = repo.Create(invalidTodo)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	invalidTodo.AssigneeID = nil
+	invalidTodo.CreatedBy = &missingId
+	_, err = repo.Create(invalidTodo)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	fetched, err := repo.GetById(assignedId)
+	assert.NoError(t, err)
+	assert.Equal(t, &adaId, fetched.AssigneeID)
+	assert.Equal(t, &graceId, fetched.CreatedBy)
+
+	// Update can't change the creator, and rejects unknown assignees.
+	assignedTodo.CreatedBy = nil
+	assignedTodo.AssigneeID = &missingId
+	assert.ErrorIs(t, repo.Update(assignedTodo), ErrUserNotFound)
+	assignedTodo.AssigneeID = &graceId
+	assert.NoError(t, repo.Update(assignedTodo))
+	assert.Equal(t, &graceId, assignedTodo.CreatedBy)
+
+	idsOf := func(todoFilter filter.Filter) []int {
+		todos, err := repo.GetAll(todoFilter, pagination.Pagination{Limit: pagination.DefaultLimit})
+		assert.NoError(t, err)
+		var ids []int
+		for _, fetchedTodo := range todos {
+			ids = append(ids, fetchedTodo.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []int{assignedId}, idsOf(filter.Filter{AssigneeID: &graceId}))
+	assert.Empty(t, idsOf(filter.Filter{AssigneeID: &adaId}))
+	assert.ElementsMatch(t, []int{unassignedId}, idsOf(filter.Filter{WithoutAssignee: true}))
+
+	reassigned, err := repo.Reassign([]int{assignedId, unassignedId}, &adaId)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reassigned)
+	assert.ElementsMatch(t, []int{assignedId, unassignedId}, idsOf(filter.Filter{AssigneeID: &adaId}))
+
+	_, err = repo.Reassign([]int{assignedId}, &missingId)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = repo.Reassign(nil, &adaId)
+	assert.Error(t, err)
+
+	reassigned, err = repo.Reassign([]int{assignedId}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reassigned)
+	assert.ElementsMatch(t, []int{assignedId}, idsOf(filter.Filter{WithoutAssignee: true}))
+}
+
+func TestDuplicateTodo(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+	attachmentRepo := AttachmentPostgresRepository{db: testDb, logger: logger}
+
+	original := createTestTodo()
+	original.Status = status.Completed
+	originalId, err := repo.Create(original)
+	assert.NoError(t, err)
+	attachmentId, err := attachmentRepo.Create(createTestAttachment(originalId, "blob-1"))
+	assert.NoError(t, err)
+
+	duplicate, err := repo.Duplicate(originalId, todo.DuplicateOptions{
+		Tags:         true,
+		Description:  true,
+		Attachments:  true,
+		ResetStatus:  true,
+		DueShiftDays: 7,
+	})
+	assert.NoError(t, err)
+	assert.NotEqual(t, originalId, duplicate.ID)
+	assert.Equal(t, original.Title, duplicate.Title)
+	assert.Equal(t, original.Description, duplicate.Description)
+	assert.Equal(t, original.Tags, duplicate.Tags)
+	assert.Equal(t, status.Planned, duplicate.Status)
+	assert.Equal(t, original.DueDate.Time.AddDate(0, 0, 7), duplicate.DueDate.Time)
+	assert.Less(t, original.Rank, duplicate.Rank)
+	assert.Len(t, duplicate.Attachments, 1)
+
+	fetched, err := repo.GetById(duplicate.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, duplicate, fetched)
+
+	// Both attachments share the blob until the last one goes.
+	_, lastReference, err := attachmentRepo.Delete(originalId, attachmentId)
+	assert.NoError(t, err)
+	assert.False(t, lastReference)
+	_, lastReference, err = attachmentRepo.Delete(duplicate.ID, duplicate.Attachments[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, lastReference)
+
+	shallow, err := repo.Duplicate(originalId, todo.DuplicateOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, shallow.Tags)
+	assert.Empty(t, shallow.Description)
+	assert.Empty(t, shallow.Attachments)
+	assert.Equal(t, status.Completed, shallow.Status)
+	assert.Equal(t, original.DueDate.Time, shallow.DueDate.Time)
+
+	// Shifting across the start of DST keeps 09:00 in the todo's time zone.
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	timed := createTestTodo()
+	timed.TimeZone = "Europe/Berlin"
+	timed.DueDate = todo.NullTime{Time: time.Date(2025, 3, 29, 9, 0, 0, 0, berlin), Valid: true, HasTime: true}
+	timedId, err := repo.Create(timed)
+	assert.NoError(t, err)
+	shifted, err := repo.Duplicate(timedId, todo.DuplicateOptions{DueShiftDays: 1})
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2025, 3, 30, 9, 0, 0, 0, berlin).Equal(shifted.DueDate.Time))
+
+	_, err = repo.Duplicate(999, todo.DuplicateOptions{})
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestPatchTodo(t *testing.T) {
+	mergePatch := func(patch string) func([]byte) ([]byte, error) {
+		return func(document []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(document, []byte(patch))
+		}
+	}
+	jsonPatch := func(patch string) func([]byte) ([]byte, error) {
+		operations, err := jsonpatch.DecodePatch([]byte(patch))
+		assert.NoError(t, err)
+		return operations.Apply
+	}
+
+	testCases := []struct {
+		name          string
+		patch         func([]byte) ([]byte, error)
+		expected      func(expectedTodo *todo.Todo)
+		expectedError bool
+		errorIs       error
+	}{
+		{
+			name:  "merge patch keeps omitted fields",
+			patch: mergePatch(`{"title": "patched", "priority": "low"}`),
+			expected: func(expectedTodo *todo.Todo) {
+				expectedTodo.Title = "patched"
+				expectedTodo.Priority = priority.Low
+			},
+		},
+		{
+			name:  "merge patch removes with null",
+			patch: mergePatch(`{"tags": null, "due_date": null}`),
+			expected: func(expectedTodo *todo.Todo) {
+				expectedTodo.Tags = nil
+				expectedTodo.DueDate = todo.NullTime{}
+			},
+		},
+		{
+			name:  "json patch",
+			patch: jsonPatch(`[{"op": "test", "path": "/title", "value": "test"}, {"op": "add", "path": "/tags/-", "value": "patched"}]`),
+			expected: func(expectedTodo *todo.Todo) {
+				expectedTodo.Tags = append(expectedTodo.Tags, "patched")
+			},
+		},
+		{
+			name:     "empty patch",
+			patch:    mergePatch(`{}`),
+			expected: func(expectedTodo *todo.Todo) {},
+		},
+		{
+			name:          "failed test",
+			patch:         jsonPatch(`[{"op": "test", "path": "/title", "value": "other"}]`),
+			expectedError: true,
+			errorIs:       jsonpatch.ErrTestFailed,
+		},
+		{
+			name:          "read-only field",
+			patch:         mergePatch(`{"rank": "a"}`),
+			expectedError: true,
+		},
+		{
+			name:          "unknown field",
+			patch:         mergePatch(`{"titel": "patched"}`),
+			expectedError: true,
+		},
+		{
+			name:          "invalid status",
+			patch:         mergePatch(`{"status": "unknown"}`),
+			expectedError: true,
+		},
+		{
+			name:          "missing project",
+			patch:         mergePatch(`{"project_id": 999}`),
+			expectedError: true,
+			errorIs:       ErrProjectNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			repo := TodoPostgresRepository{db: testDb, logger: logger}
+
+			id, err := repo.Create(createTestTodo())
+			assert.NoError(t, err)
+			original, err := repo.GetById(id)
+			assert.NoError(t, err)
+
+			patched, err := repo.Patch(id, tc.patch)
+			if tc.expectedError {
+				assert.Error(t, err)
+				if tc.errorIs != nil {
+					assert.ErrorIs(t, err, tc.errorIs)
+				}
+				fetched, err := repo.GetById(id)
+				assert.NoError(t, err)
+				assert.Equal(t, original, fetched)
+				return
+			}
+			assert.NoError(t, err)
+
+			tc.expected(original)
+			assert.Equal(t, original, patched)
+			fetched, err := repo.GetById(id)
+			assert.NoError(t, err)
+			assert.Equal(t, patched, fetched)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+		defer tearDown()
+		repo := TodoPostgresRepository{db: testDb, logger: logger}
+		_, err := repo.Patch(999, func(document []byte) ([]byte, error) { return document, nil })
+		assert.ErrorIs(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestBulkUpdateTodos(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	firstId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	secondId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	lowTodo := createTestTodo()
+	lowTodo.Priority = priority.Low
+	lowId, err := repo.Create(lowTodo)
+	assert.NoError(t, err)
+
+	update := todo.BulkUpdate{
+		Status:       status.Completed,
+		AddTags:      []string{"sprint-1"},
+		RemoveTags:   []string{"testing"},
+		DueShiftDays: 1,
+	}
+
+	_, err = repo.BulkUpdate([]int{firstId}, filter.Filter{}, todo.BulkUpdate{}, todo.BulkOptions{Atomic: true})
+	var errs validation.Errors
+	assert.ErrorAs(t, err, &errs)
+
+	result, err := repo.BulkUpdate([]int{firstId, 9999, secondId}, filter.Filter{}, update, todo.BulkOptions{Atomic: true})
+	assert.ErrorIs(t, err, ErrBulkRolledBack)
+	assert.Equal(t, []todo.BulkItemResult{
+		{ID: firstId, Status: todo.BulkRolledBack},
+		{ID: 9999, Status: todo.BulkNotFound, Error: ErrRecordNotFound.Error()},
+		{ID: secondId, Status: todo.BulkNotAttempted},
+	}, result.Items)
+	unchanged, err := repo.GetById(firstId)
+	assert.NoError(t, err)
+	assert.Equal(t, status.InProgress, unchanged.Status)
+
+	result, err = repo.BulkUpdate([]int{firstId, 9999, firstId}, filter.Filter{}, update, todo.BulkOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 1, result.Failed)
+	updated, err := repo.GetById(firstId)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Completed, updated.Status)
+	assert.Equal(t, []string{"test", "sprint-1"}, updated.Tags)
+	assert.Equal(t, unchanged.DueDate.Time.AddDate(0, 0, 1), updated.DueDate.Time)
+
+	highPriority := filter.Filter{Priority: priority.High, Status: status.InProgress}
+	result, err = repo.BulkUpdate(nil, highPriority, update, todo.BulkOptions{Atomic: true, DryRun: true})
+	assert.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 1, result.Matched)
+	assert.Equal(t, secondId, result.Items[0].ID)
+	notUpdated, err := repo.GetById(secondId)
+	assert.NoError(t, err)
+	assert.Equal(t, status.InProgress, notUpdated.Status)
+
+	result, err = repo.BulkUpdate(nil, highPriority, todo.BulkUpdate{Priority: priority.Urgent}, todo.BulkOptions{Atomic: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Updated)
+	low, err := repo.GetById(lowId)
+	assert.NoError(t, err)
+	assert.Equal(t, priority.Low, low.Priority)
+}
+
+func TestCreateTodoBatch(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	existingId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	existing, err := repo.GetById(existingId)
+	assert.NoError(t, err)
+
+	missingProject := 9999
+	todos := todo.Todos{createTestTodo(), createTestTodo(), createTestTodo(), createTestTodo()}
+	todos[1].Title = ""
+	todos[2].ProjectID = &missingProject
+	todos[3].Status = status.Completed
+	rowErrors, err := repo.CreateBatch(todos)
+	assert.NoError(t, err)
+	assert.NoError(t, rowErrors[0])
+	var errs validation.Errors
+	assert.ErrorAs(t, rowErrors[1], &errs)
+	assert.ErrorIs(t, rowErrors[2], ErrProjectNotFound)
+	assert.NoError(t, rowErrors[3])
+
+	first, err := repo.GetById(todos[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, todos[0].Title, first.Title)
+	assert.Equal(t, todos[0].Tags, first.Tags)
+	assert.Less(t, existing.Rank, first.Rank)
+	last, err := repo.GetById(todos[3].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Completed, last.Status)
+	assert.Less(t, first.Rank, last.Rank)
+	assert.Zero(t, todos[1].ID)
+	assert.Zero(t, todos[2].ID)
+
+	nextId, err := repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	next, err := repo.GetById(nextId)
+	assert.NoError(t, err)
+	assert.Less(t, last.Rank, next.Rank)
+}
+
+func TestTodoVersion(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+	commentRepo := CommentPostgresRepository{db: testDb, logger: logger}
+
+	created := createTestTodo()
+	id, err := repo.Create(created)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), created.Version)
+
+	fetched, err := repo.GetById(id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), fetched.Version)
+	assert.Equal(t, created.UpdatedAt, fetched.UpdatedAt)
+
+	// Writing the same values isn't a change.
+	assert.NoError(t, repo.Update(fetched))
+	assert.Equal(t, int64(1), fetched.Version)
+
+	fetched.Title = "renamed"
+	assert.NoError(t, repo.Update(fetched))
+	assert.Equal(t, int64(2), fetched.Version)
+
+	_, err = commentRepo.Create(&comment.Comment{TodoID: id, AuthorID: 1, Body: "hello"})
+	assert.NoError(t, err)
+	fetched, err = repo.GetById(id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), fetched.Version)
+	assert.False(t, fetched.UpdatedAt.Before(created.UpdatedAt))
+
+	_, err = repo.Patch(id, func(document []byte) ([]byte, error) {
+		return jsonpatch.MergePatch(document, []byte(`{"version": 1}`))
+	})
+	assert.Error(t, err)
+}
+
+func TestGetAllTodoFields(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := TodoPostgresRepository{db: testDb, logger: logger}
+	projectRepo := ProjectPostgresRepository{db: testDb, logger: logger}
+	commentRepo := CommentPostgresRepository{db: testDb, logger: logger}
+
+	projectId, err := projectRepo.Create(&project.Project{Name: "home"})
+	assert.NoError(t, err)
+	withProject := createTestTodo()
+	withProject.ProjectID = &projectId
+	withProjectId, err := repo.Create(withProject)
+	assert.NoError(t, err)
+	_, err = repo.Create(createTestTodo())
+	assert.NoError(t, err)
+	_, err = commentRepo.Create(&comment.Comment{TodoID: withProjectId, AuthorID: 1, Body: "hello"})
+	assert.NoError(t, err)
+
+	fieldset, err := todo.ParseFieldset("title, due_date,title")
+	assert.NoError(t, err)
+	assert.Equal(t, todo.Fieldset{"id", "title", "due_date"}, fieldset)
+	_, err = todo.ParseFieldset("title,secret")
+	assert.Error(t, err)
+	include, err := todo.ParseIncludes("project,comments")
+	assert.NoError(t, err)
+	_, err = todo.ParseIncludes("checklist")
+	assert.Error(t, err)
+
+	todos, err := repo.GetAll(filter.Filter{Fields: fieldset, Include: include, Sort: filter.Sort{Field: "id"}},
+		pagination.Pagination{Limit: pagination.DefaultLimit})
+	assert.NoError(t, err)
+	assert.Len(t, todos, 2)
+	assert.Equal(t, "test", todos[0].Title)
+	assert.Empty(t, todos[0].Description)
+	assert.True(t, todos[0].DueDate.Valid)
+	assert.Equal(t, int64(2), todos[0].Version)
+	assert.Equal(t, "home", todos[0].Project.Name)
+	assert.Len(t, todos[0].Comments, 1)
+	assert.Nil(t, todos[1].Project)
+	assert.Empty(t, todos[1].Comments)
+
+	selected, err := todos[0].Select(fieldset, include)
+	assert.NoError(t, err)
+	var selectedFields map[string]any
+	assert.NoError(t, json.Unmarshal(selected, &selectedFields))
+	assert.ElementsMatch(t, []string{"id", "title", "due_date", "project", "comments"}, slices.Collect(maps.Keys(selectedFields)))
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/GlebMoskalev/todo-api/internal/models/user"
+	"log/slog"
+)
+
+const userColumns = "id, name, email, created_at"
+
+type UserPostgresRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewUserPostgresRepository(db *sql.DB, logger *slog.Logger) *UserPostgresRepository {
+	return &UserPostgresRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *UserPostgresRepository) Create(u *user.User) (int, error) {
+	r.logger.Debug("Attempting to create user", slog.String("email", u.Email))
+	if err := u.Validate(); err != nil {
+		r.logger.Warn("Validation failed", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	err := r.db.QueryRow(
+		"INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, created_at",
+		u.Name,
+		u.Email,
+	).Scan(&u.ID, &u.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			r.logger.Warn("User already exists", slog.String("email", u.Email))
+			return 0, ErrUserExists
+		}
+		r.logger.Error("Failed to insert user", slog.String("error", err.Error()))
+		return 0, err
+	}
+	u.CreatedAt = u.CreatedAt.UTC()
+
+	r.logger.Debug("User created successfully", slog.Int("ID", u.ID))
+	return u.ID, nil
+}
+
+func (r *UserPostgresRepository) GetById(id int) (*user.User, error) {
+	r.logger.Debug("Fetching user by id", slog.Int("ID", id))
+	u, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("Record not found", slog.Int("id", id))
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch user", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *UserPostgresRepository) GetAll() (user.Users, error) {
+	r.logger.Debug("Fetching all users")
+	rows, err := r.db.Query("SELECT " + userColumns + " FROM users ORDER BY name, id")
+	if err != nil {
+		r.logger.Error("Query failed", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.logger.Warn("Failed to close rows", slog.String("error", err.Error()))
+		}
+	}()
+
+	users := user.Users{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan row", slog.String("error", err.Error()))
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Rows processing error", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	r.logger.Debug("Users fetched", slog.Int("count", len(users)))
+	return users, nil
+}
+
+// Delete removes a user. Todos assigned to or created by the user are kept
+// and lose the reference.
+func (r *UserPostgresRepository) Delete(id int) error {
+	r.logger.Debug("Attempting to delete user", slog.Int("ID", id))
+	res, err := r.db.Exec("DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		r.logger.Error("Failed to execute delete", slog.String("error", err.Error()))
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", slog.String("error", err.Error()))
+		return err
+	}
+	if rowsAffected == 0 {
+		r.logger.Warn("Delete failed: no rows affected", slog.Int("id", id))
+		return ErrRecordNotFound
+	}
+	r.logger.Debug("User deleted successfully", slog.Int("ID", id))
+	return nil
+}
+
+func scanUser(row rowScanner) (*user.User, error) {
+	u := &user.User{}
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+		return nil, err
+	}
+	u.CreatedAt = u.CreatedAt.UTC()
+	return u, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/user"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCreateUser(t *testing.T) {
+	testCases := []struct {
+		name          string
+		user          *user.User
+		expectedError bool
+		errorIs       error
+	}{
+		{
+			name: "successfully create",
+			user: &user.User{Name: "Grace", Email: "grace@example.com"},
+		},
+		{
+			name:          "duplicate email",
+			user:          &user.User{Name: "Ada again", Email: "Ada@Example.com"},
+			expectedError: true,
+			errorIs:       ErrUserExists,
+		},
+		{
+			name:          "invalid email",
+			user:          &user.User{Name: "Grace", Email: "Grace <grace@example.com>"},
+			expectedError: true,
+		},
+		{
+			name:          "empty name",
+			user:          &user.User{Name: " ", Email: "grace@example.com"},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+			defer tearDown()
+			repo := UserPostgresRepository{db: testDb, logger: logger}
+			_, err := repo.Create(&user.User{Name: "Ada", Email: "ada@example.com"})
+			assert.NoError(t, err)
+
+			id, err := repo.Create(tc.user)
+			if tc.expectedError {
+				assert.Error(t, err)
+				if tc.errorIs != nil {
+					assert.ErrorIs(t, err, tc.errorIs)
+				}
+				return
+			}
+			assert.NoError(t, err)
+
+			fetched, err := repo.GetById(id)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.user, fetched)
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	t.Parallel()
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := UserPostgresRepository{db: testDb, logger: logger}
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+
+	newUser := &user.User{Name: "Ada", Email: "ada@example.com"}
+	userId, err := repo.Create(newUser)
+	assert.NoError(t, err)
+	newTodo := createTestTodo()
+	newTodo.AssigneeID = &userId
+	newTodo.CreatedBy = &userId
+	todoId, err := todoRepo.Create(newTodo)
+	assert.NoError(t, err)
+
+	users, err := repo.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, user.Users{newUser}, users)
+
+	assert.NoError(t, repo.Delete(userId))
+	assert.ErrorIs(t, repo.Delete(userId), ErrRecordNotFound)
+	_, err = repo.GetById(userId)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	fetched, err := todoRepo.GetById(todoId)
+	assert.NoError(t, err)
+	assert.Nil(t, fetched.AssigneeID)
+	assert.Nil(t, fetched.CreatedBy)
+}
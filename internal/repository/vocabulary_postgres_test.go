@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
+	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
+	"github.com/GlebMoskalev/todo-api/internal/models/priority"
+	"github.com/GlebMoskalev/todo-api/internal/models/status"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// The registries are process wide, so these tests don't run in parallel with
+// the others.
+
+func TestStatuses(t *testing.T) {
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := StatusPostgresRepository{db: testDb, logger: logger}
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	defer status.SetRegistry(status.Defaults)
+
+	assert.NoError(t, repo.Load())
+	assert.Equal(t, status.Defaults, status.All())
+
+	blocked := &status.Definition{Value: "blocked", Name: "Blocked", Position: 2, Color: "#e91e63"}
+	assert.NoError(t, repo.Create(blocked))
+	assert.ErrorIs(t, repo.Create(blocked), ErrStatusExists)
+	assert.Error(t, repo.Create(&status.Definition{Value: "On hold", Name: "On hold"}))
+	assert.True(t, status.IsValidStatus("blocked"))
+	assert.False(t, status.IsTerminal("blocked"))
+	assert.Equal(t, []status.Status{status.Planned, "blocked", status.InProgress, status.Completed, status.Canceled},
+		statusValues(status.All()))
+
+	blockedTodo := createTestTodo()
+	blockedTodo.Status = "blocked"
+	blockedId, err := todoRepo.Create(blockedTodo)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, repo.Delete("blocked"), ErrValueInUse)
+	assert.Error(t, repo.Delete(status.Planned))
+	assert.ErrorIs(t, repo.Delete("missing"), ErrRecordNotFound)
+	assert.ErrorIs(t, repo.Update(&status.Definition{Value: "missing", Name: "Missing"}), ErrRecordNotFound)
+
+	// Making the status terminal starts the archiving countdown of its todos.
+	blocked.Terminal = true
+	assert.NoError(t, repo.Update(blocked))
+	assert.True(t, status.IsTerminal("blocked"))
+	archived, err := todoRepo.ArchiveCompleted(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, archived)
+
+	_, err = todoRepo.Delete([]int{blockedId})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete("blocked"))
+	assert.False(t, status.IsValidStatus("blocked"))
+}
+
+func TestPriorities(t *testing.T) {
+	testDb, logger, tearDown := setupRepositoryTestDatabase(t)
+	defer tearDown()
+	repo := PriorityPostgresRepository{db: testDb, logger: logger}
+	todoRepo := TodoPostgresRepository{db: testDb, logger: logger}
+	defer priority.SetRegistry(priority.Defaults)
+
+	assert.NoError(t, repo.Load())
+	assert.Equal(t, priority.Defaults, priority.All())
+
+	critical := &priority.Definition{Value: "critical", Name: "Critical", Position: 5, Color: "#b71c1c"}
+	assert.NoError(t, repo.Create(critical))
+	assert.ErrorIs(t, repo.Create(critical), ErrPriorityExists)
+	assert.Error(t, repo.Create(&priority.Definition{Value: "later", Name: "Later", Color: "red"}))
+	assert.True(t, priority.IsValidPriority("critical"))
+
+	var ids []int
+	for _, value := range []priority.Priority{priority.Low, "critical", priority.Urgent} {
+		newTodo := createTestTodo()
+		newTodo.Priority = value
+		id, err := todoRepo.Create(newTodo)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	// Sorting follows the configured position, not the value.
+	todos, err := todoRepo.GetAll(filter.Filter{Sort: filter.Sort{Field: "priority", Descending: true}},
+		pagination.Pagination{Limit: pagination.DefaultLimit})
+	assert.NoError(t, err)
+	var sorted []int
+	for _, fetchedTodo := range todos {
+		sorted = append(sorted, fetchedTodo.ID)
+	}
+	assert.Equal(t, []int{ids[1], ids[2], ids[0]}, sorted)
+
+	critical.Position = 0
+	assert.NoError(t, repo.Update(critical))
+	assert.Equal(t, priority.Priority("critical"), priority.All()[0].Value)
+
+	assert.ErrorIs(t, repo.Delete("critical"), ErrValueInUse)
+	_, err = todoRepo.Delete([]int{ids[1]})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete("critical"))
+	assert.False(t, priority.IsValidPriority("critical"))
+	assert.ErrorIs(t, repo.Delete("critical"), ErrRecordNotFound)
+}
+
+func statusValues(definitions status.Definitions) []status.Status {
+	var values []status.Status
+	for _, d := range definitions {
+		values = append(values, d.Value)
+	}
+	return values
+}
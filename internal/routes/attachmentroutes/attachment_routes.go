@@ -0,0 +1,18 @@
+package attachmentroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/blobstore"
+	"github.com/GlebMoskalev/todo-api/internal/handlers/attachmenthandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+func Routes(repo *repository.AttachmentPostgresRepository, store blobstore.BlobStore, maxSize int64) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", attachmenthandlers.GetAttachments(repo))
+	r.Post("/", attachmenthandlers.UploadAttachment(repo, store, maxSize))
+	r.Get("/{attachmentId}", attachmenthandlers.DownloadAttachment(repo, store))
+	r.Delete("/{attachmentId}", attachmenthandlers.DeleteAttachment(repo, store))
+	return r
+}
@@ -0,0 +1,17 @@
+package commentroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/handlers/commenthandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+func Routes(repo *repository.CommentPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", commenthandlers.GetComments(repo))
+	r.Post("/", commenthandlers.CreateComment(repo))
+	r.Put("/{commentId}", commenthandlers.UpdateComment(repo))
+	r.Delete("/{commentId}", commenthandlers.DeleteComment(repo))
+	return r
+}
@@ -0,0 +1,17 @@
+package customfieldroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/handlers/customfieldhandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+func Routes(repo *repository.CustomFieldPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", customfieldhandlers.GetAllCustomFields(repo))
+	r.Post("/", customfieldhandlers.CreateCustomField(repo))
+	r.Put("/{id}", customfieldhandlers.UpdateCustomField(repo))
+	r.Delete("/{id}", customfieldhandlers.DeleteCustomField(repo))
+	return r
+}
@@ -0,0 +1,22 @@
+package projectroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/handlers/projecthandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+func Routes(repo *repository.ProjectPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", projecthandlers.CreateProject(repo))
+	r.Get("/", projecthandlers.GetAllProjects(repo))
+	r.Get("/{id}", projecthandlers.GetByIdProject(repo))
+	r.Put("/{id}", projecthandlers.UpdateProject(repo))
+	r.Delete("/{id}", projecthandlers.DeleteProject(repo))
+	r.Post("/{id}/archive", projecthandlers.ArchiveProject(repo, true))
+	r.Post("/{id}/unarchive", projecthandlers.ArchiveProject(repo, false))
+	r.Post("/{id}/todos", projecthandlers.MoveTodos(repo))
+	r.Get("/{id}/counts", projecthandlers.GetStatusCounts(repo))
+	return r
+}
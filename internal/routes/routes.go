@@ -1,18 +1,114 @@
 package routes
 
 import (
+	"github.com/GlebMoskalev/todo-api/internal/apiversion"
+	"github.com/GlebMoskalev/todo-api/internal/blobstore"
+	"github.com/GlebMoskalev/todo-api/internal/idempotency"
+	"github.com/GlebMoskalev/todo-api/internal/identity"
+	"github.com/GlebMoskalev/todo-api/internal/openapi"
 	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/GlebMoskalev/todo-api/internal/routes/attachmentroutes"
+	"github.com/GlebMoskalev/todo-api/internal/routes/commentroutes"
+	"github.com/GlebMoskalev/todo-api/internal/routes/customfieldroutes"
+	"github.com/GlebMoskalev/todo-api/internal/routes/projectroutes"
+	"github.com/GlebMoskalev/todo-api/internal/routes/tagroutes"
+	"github.com/GlebMoskalev/todo-api/internal/routes/templateroutes"
+	"github.com/GlebMoskalev/todo-api/internal/routes/timeroutes"
 	"github.com/GlebMoskalev/todo-api/internal/routes/todoroutes"
+	"github.com/GlebMoskalev/todo-api/internal/routes/userroutes"
+	"github.com/GlebMoskalev/todo-api/internal/routes/vocabularyroutes"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"time"
 )
 
-func SetupRouter(repo *repository.TodoPostgresRepository) *chi.Mux {
+type Dependencies struct {
+	TodoRepo          repository.TodoRepository
+	CommentRepo       *repository.CommentPostgresRepository
+	AttachmentRepo    *repository.AttachmentPostgresRepository
+	ProjectRepo       *repository.ProjectPostgresRepository
+	TagRepo           *repository.TagPostgresRepository
+	TimeEntryRepo     *repository.TimeEntryPostgresRepository
+	CustomFieldRepo   *repository.CustomFieldPostgresRepository
+	TemplateRepo      *repository.TemplatePostgresRepository
+	UserRepo          *repository.UserPostgresRepository
+	StatusRepo        *repository.StatusPostgresRepository
+	PriorityRepo      *repository.PriorityPostgresRepository
+	BlobStore         blobstore.BlobStore
+	MaxAttachmentSize int64
+	// OpenAPIValidator checks requests against the OpenAPI document when set.
+	OpenAPIValidator *openapi.Validator
+	// IdempotencyStore keeps responses to POST requests with an
+	// Idempotency-Key header for IdempotencyTTL. Keys are ignored when nil.
+	IdempotencyStore idempotency.Store
+	IdempotencyTTL   time.Duration
+	// Sunsets holds the dates after which deprecated API versions may be
+	// removed, announced in the Sunset header.
+	Sunsets map[apiversion.Version]time.Time
+}
+
+func SetupRouter(deps Dependencies) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.Recoverer)
+	r.Use(identity.Middleware)
+	if deps.OpenAPIValidator != nil {
+		r.Use(deps.OpenAPIValidator.Middleware)
+	}
+	if deps.IdempotencyStore != nil {
+		r.Use(idempotency.Middleware(deps.IdempotencyStore, deps.IdempotencyTTL))
+	}
+
+	r.Get("/openapi.json", openapi.SpecHandler)
+	r.Get("/docs", openapi.DocsHandler)
+	r.Get("/docs/*", openapi.DocsAssetHandler)
+
+	versions := make(map[apiversion.Version]http.Handler)
+	for _, version := range apiversion.Versions {
+		versions[version] = versionRouter(deps, version)
+		r.Mount("/"+version.String(), versions[version])
+	}
+	// Unversioned paths serve the version the Accept header asks for, v1 by
+	// default.
+	r.Mount("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		version, err := apiversion.FromAccept(r.Header.Get("Accept"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotAcceptable)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if version == 0 {
+			version = apiversion.V1
+		}
+		versions[version].ServeHTTP(w, r)
+	}))
 
-	r.Mount("/todo", todoroutes.Routes(repo))
+	return r
+}
+
+// versionRouter serves the resources of one API version. Every version but
+// the latest is marked deprecated.
+func versionRouter(deps Dependencies, version apiversion.Version) chi.Router {
+	r := chi.NewRouter()
+	r.Use(apiversion.Middleware(version))
+	if latest := apiversion.Versions[len(apiversion.Versions)-1]; version < latest {
+		r.Use(apiversion.Deprecated(deps.Sunsets[version], latest))
+	}
 
+	r.Mount("/todo", todoroutes.Routes(deps.TodoRepo, deps.BlobStore, version))
+	r.Mount("/todo/{id}/comments", commentroutes.Routes(deps.CommentRepo))
+	r.Mount("/todo/{id}/attachments",
+		attachmentroutes.Routes(deps.AttachmentRepo, deps.BlobStore, deps.MaxAttachmentSize))
+	r.Mount("/todo/{id}/timer", timeroutes.TimerRoutes(deps.TimeEntryRepo))
+	r.Mount("/todo/{id}/time-entries", timeroutes.TimeEntryRoutes(deps.TimeEntryRepo))
+	r.Mount("/projects", projectroutes.Routes(deps.ProjectRepo))
+	r.Mount("/tags", tagroutes.Routes(deps.TagRepo))
+	r.Mount("/reports", timeroutes.ReportRoutes(deps.TimeEntryRepo))
+	r.Mount("/custom-fields", customfieldroutes.Routes(deps.CustomFieldRepo))
+	r.Mount("/templates", templateroutes.Routes(deps.TemplateRepo, deps.TodoRepo))
+	r.Mount("/users", userroutes.Routes(deps.UserRepo))
+	r.Mount("/admin", vocabularyroutes.Routes(deps.StatusRepo, deps.PriorityRepo))
 	return r
 }
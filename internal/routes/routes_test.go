@@ -0,0 +1,430 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/GlebMoskalev/todo-api/internal/apiversion"
+	"github.com/GlebMoskalev/todo-api/internal/models/filter"
+	"github.com/GlebMoskalev/todo-api/internal/models/pagination"
+	"github.com/GlebMoskalev/todo-api/internal/models/todo"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/GlebMoskalev/todo-api/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryTodoRepository keeps todos in a map. Methods the tests don't use
+// fall through to the nil embedded interface and panic.
+type memoryTodoRepository struct {
+	repository.TodoRepository
+	mu     sync.Mutex
+	todos  map[int]*todo.Todo
+	nextId int
+}
+
+func newMemoryTodoRepository() *memoryTodoRepository {
+	return &memoryTodoRepository{todos: make(map[int]*todo.Todo), nextId: 1}
+}
+
+func (r *memoryTodoRepository) Create(t *todo.Todo) (int, error) {
+	if err := t.Validate(); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t.ID = r.nextId
+	t.Version = 1
+	r.nextId++
+	stored := *t
+	r.todos[t.ID] = &stored
+	return t.ID, nil
+}
+
+func (r *memoryTodoRepository) GetById(id int) (*todo.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.todos[id]
+	if !ok {
+		return nil, repository.ErrRecordNotFound
+	}
+	fetched := *stored
+	return &fetched, nil
+}
+
+func (r *memoryTodoRepository) GetAll(_ filter.Filter, paginationParams pagination.Pagination) (todo.Todos, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var todos todo.Todos
+	for id := 1; id < r.nextId && len(todos) < paginationParams.Limit; id++ {
+		if stored, ok := r.todos[id]; ok {
+			fetched := *stored
+			todos = append(todos, &fetched)
+		}
+	}
+	return todos, nil
+}
+
+func (r *memoryTodoRepository) Update(t *todo.Todo) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.todos[t.ID]
+	if !ok {
+		return repository.ErrRecordNotFound
+	}
+	t.Version = stored.Version + 1
+	updated := *t
+	r.todos[t.ID] = &updated
+	return nil
+}
+
+func (r *memoryTodoRepository) Delete(ids []int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := 0
+	for _, id := range ids {
+		if _, ok := r.todos[id]; ok {
+			delete(r.todos, id)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return nil, repository.ErrRecordNotFound
+	}
+	return nil, nil
+}
+
+func TestVersionedTodoRoutes(t *testing.T) {
+	sunset := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name               string
+		prefix             string
+		accept             string
+		expectedVersion    string
+		expectedDeprecated bool
+		expectedEmptyList  string
+		expectedLegacy     int
+	}{
+		{
+			name:               "v1 path",
+			prefix:             "/v1",
+			expectedVersion:    "1",
+			expectedDeprecated: true,
+			expectedEmptyList:  "null",
+			expectedLegacy:     http.StatusOK,
+		},
+		{
+			name:              "v2 path",
+			prefix:            "/v2",
+			expectedVersion:   "2",
+			expectedEmptyList: "[]",
+			expectedLegacy:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:               "unversioned path",
+			expectedVersion:    "1",
+			expectedDeprecated: true,
+			expectedEmptyList:  "null",
+			expectedLegacy:     http.StatusOK,
+		},
+		{
+			name:              "unversioned path with v2 media type",
+			accept:            apiversion.V2.MediaType(),
+			expectedVersion:   "2",
+			expectedEmptyList: "[]",
+			expectedLegacy:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:               "unversioned path with version parameter",
+			accept:             "application/json; version=1",
+			expectedVersion:    "1",
+			expectedDeprecated: true,
+			expectedEmptyList:  "null",
+			expectedLegacy:     http.StatusOK,
+		},
+		{
+			name:              "path wins over accept",
+			prefix:            "/v2",
+			accept:            apiversion.V1.MediaType(),
+			expectedVersion:   "2",
+			expectedEmptyList: "[]",
+			expectedLegacy:    http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			router := SetupRouter(Dependencies{
+				TodoRepo: newMemoryTodoRepository(),
+				Sunsets:  map[apiversion.Version]time.Time{apiversion.V1: sunset},
+			})
+			do := func(method, target, body string) *httptest.ResponseRecorder {
+				request := httptest.NewRequest(method, tc.prefix+target, strings.NewReader(body))
+				if tc.accept != "" {
+					request.Header.Set("Accept", tc.accept)
+				}
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, request)
+				return recorder
+			}
+
+			list := do(http.MethodGet, "/todo", "")
+			assert.Equal(t, http.StatusOK, list.Code)
+			assert.Equal(t, tc.expectedEmptyList, list.Body.String())
+			assert.Equal(t, tc.expectedVersion, list.Header().Get(apiversion.Header))
+			if tc.expectedDeprecated {
+				assert.Equal(t, "true", list.Header().Get("Deprecation"))
+				assert.Equal(t, "Wed, 31 Dec 2025 00:00:00 GMT", list.Header().Get("Sunset"))
+				assert.Equal(t, `</v2/todo>; rel="successor-version"`, list.Header().Get("Link"))
+			} else {
+				assert.Empty(t, list.Header().Get("Deprecation"))
+				assert.Empty(t, list.Header().Get("Sunset"))
+			}
+
+			created := do(http.MethodPost, "/todo/", `{"title": "write tests", "priority": "high", "status": "planned"}`)
+			assert.Equal(t, http.StatusCreated, created.Code)
+			assert.Equal(t, tc.prefix+"/todo/1", created.Header().Get("Location"))
+
+			fetched := do(http.MethodGet, "/todo/1", "")
+			assert.Equal(t, http.StatusOK, fetched.Code)
+			var fetchedTodo todo.Todo
+			assert.NoError(t, json.Unmarshal(fetched.Body.Bytes(), &fetchedTodo))
+			assert.Equal(t, "write tests", fetchedTodo.Title)
+			assert.Equal(t, tc.expectedVersion, fetched.Header().Get(apiversion.Header))
+
+			legacy := do(http.MethodPut, "/todo/", `{"id": 1, "title": "renamed", "priority": "high", "status": "planned"}`)
+			assert.Equal(t, tc.expectedLegacy, legacy.Code)
+		})
+	}
+}
+
+func TestTodoRouteContract(t *testing.T) {
+	const body = `{"title": "write tests", "priority": "high", "status": "planned"}`
+	for _, prefix := range []string{"/v1", "/v2"} {
+		t.Run(prefix, func(t *testing.T) {
+			router := SetupRouter(Dependencies{TodoRepo: newMemoryTodoRepository()})
+			do := func(method, target, body string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest(method, prefix+target, strings.NewReader(body)))
+				return recorder
+			}
+
+			created := do(http.MethodPost, "/todo/", body)
+			assert.Equal(t, http.StatusCreated, created.Code)
+			assert.Equal(t, prefix+"/todo/1", created.Header().Get("Location"))
+			var createdTodo todo.Todo
+			assert.NoError(t, json.Unmarshal(created.Body.Bytes(), &createdTodo))
+			assert.Equal(t, 1, createdTodo.ID)
+			assert.Equal(t, "write tests", createdTodo.Title)
+			assert.EqualValues(t, 1, createdTodo.Version)
+
+			assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/todo/999", "").Code)
+			assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/todo/999", body).Code)
+			assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPatch, "/todo/", body).Code)
+			assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPost, "/todo/1", body).Code)
+
+			deleted := do(http.MethodDelete, "/todo/1", "")
+			assert.Equal(t, http.StatusNoContent, deleted.Code)
+			assert.Empty(t, deleted.Body.String())
+			assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/todo/1", "").Code)
+			assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/todo/1", "").Code)
+		})
+	}
+
+	t.Run("legacy routes", func(t *testing.T) {
+		router := SetupRouter(Dependencies{TodoRepo: newMemoryTodoRepository()})
+		do := func(method, target, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+			return recorder
+		}
+		assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/v1/todo/", body).Code)
+
+		updated := do(http.MethodPut, "/v1/todo/", `{"id": 1, "title": "renamed", "priority": "high", "status": "planned"}`)
+		assert.Equal(t, http.StatusOK, updated.Code)
+		assert.Equal(t, "true", updated.Header().Get("Deprecation"))
+
+		deleted := do(http.MethodDelete, "/v1/todo/", `{"ids": [1]}`)
+		assert.Equal(t, http.StatusOK, deleted.Code)
+		assert.Equal(t, "true", deleted.Header().Get("Deprecation"))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPut, "/v2/todo/", `{"id": 1}`).Code)
+		assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodDelete, "/v2/todo/", `{"ids": [1]}`).Code)
+		assert.Empty(t, do(http.MethodGet, "/v2/todo", "").Header().Get("Deprecation"))
+	})
+}
+
+func TestTodoRequestErrors(t *testing.T) {
+	router := SetupRouter(Dependencies{TodoRepo: newMemoryTodoRepository()})
+	request := httptest.NewRequest(http.MethodPost, "/v2/todo/", strings.NewReader(
+		`{"title": 1, "colour": "red", "tags": ["home", 2], "due_date": "soon", "priority": "bogus", "status": "planned"}`))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	var response struct {
+		Fields validation.Errors `json:"fields"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, validation.Errors{
+		{Field: "colour", Message: "unknown field"},
+		{Field: "due_date", Message: "invalid due_date format, expected YYYY-MM-DD or RFC 3339 datetime"},
+		{Field: "tags[1]", Message: "expected string, got number"},
+		{Field: "title", Message: "expected string, got number"},
+		{Field: "priority", Message: `invalid value "bogus"`},
+	}, response.Fields)
+
+	request = httptest.NewRequest(http.MethodPost, "/v2/todo/bulk", strings.NewReader(
+		`{"ids": [1], "update": {"bogus": true, "status": 3}, "mode": 1}`))
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	response.Fields = nil
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	var fields []string
+	for _, fieldError := range response.Fields {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"mode", "update.bogus", "update.status"}, fields)
+}
+
+// failingTodoRepository fails every create and update with err.
+type failingTodoRepository struct {
+	*memoryTodoRepository
+	err error
+}
+
+func (r *failingTodoRepository) Create(*todo.Todo) (int, error) {
+	return 0, r.err
+}
+
+func (r *failingTodoRepository) Update(*todo.Todo) error {
+	return r.err
+}
+
+func TestTodoRepositoryErrors(t *testing.T) {
+	const body = `{"id": 1, "title": "write tests", "priority": "high", "status": "planned"}`
+	testCases := []struct {
+		err          error
+		expectedCode int
+	}{
+		{fmt.Errorf("%w field \"Status\": gone", repository.ErrInvalidValue), http.StatusBadRequest},
+		{repository.ErrProjectNotFound, http.StatusBadRequest},
+		{repository.ErrUserNotFound, http.StatusBadRequest},
+		{validation.Errors{{Field: "title", Message: "required"}}, http.StatusUnprocessableEntity},
+		{repository.ErrRecordNotFound, http.StatusNotFound},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			router := SetupRouter(Dependencies{TodoRepo: &failingTodoRepository{newMemoryTodoRepository(), tc.err}})
+			do := func(method, target string) int {
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+				return recorder.Code
+			}
+			if tc.err != repository.ErrRecordNotFound {
+				assert.Equal(t, tc.expectedCode, do(http.MethodPost, "/v2/todo/"))
+			}
+			assert.Equal(t, tc.expectedCode, do(http.MethodPut, "/v2/todo/1"))
+			assert.Equal(t, tc.expectedCode, do(http.MethodPut, "/v1/todo/"))
+		})
+	}
+}
+
+func TestBulkUpdateFilters(t *testing.T) {
+	router := SetupRouter(Dependencies{TodoRepo: newMemoryTodoRepository()})
+	for _, query := range []string{"", "?", "?statsu=done", "?status=done&limit=10", "?archived=all&available=all", "?status="} {
+		request := httptest.NewRequest(http.MethodPost, "/v2/todo/bulk"+query, strings.NewReader(`{"update": {"status": "done"}}`))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, query)
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	router := SetupRouter(Dependencies{TodoRepo: newMemoryTodoRepository()})
+	request := httptest.NewRequest(http.MethodGet, "/todo", nil)
+	request.Header.Set("Accept", "application/vnd.todo-api.v3+json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNotAcceptable, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v3/todo", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestTodoFormats(t *testing.T) {
+	router := SetupRouter(Dependencies{TodoRepo: newMemoryTodoRepository()})
+	do := func(method, target, contentType, accept string, body []byte) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/v2"+target, bytes.NewReader(body))
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	created := do(http.MethodPost, "/todo/", "application/yaml", "application/yaml",
+		[]byte("title: pay rent\ndue_date: 2025-05-01\npriority: high\nstatus: planned\ntags: [home]\n"))
+	assert.Equal(t, http.StatusCreated, created.Code)
+	assert.Equal(t, "application/yaml", created.Header().Get("Content-Type"))
+	assert.Contains(t, created.Body.String(), "due_date: \"2025-05-01\"\n")
+
+	created = do(http.MethodPost, "/todo/", "text/csv", "",
+		[]byte("title,due_date,priority,status,tags\ncall mom,2025-05-02T18:00:00Z,low,planned,\"family,phone\"\n"))
+	assert.Equal(t, http.StatusCreated, created.Code)
+	var createdTodo todo.Todo
+	assert.NoError(t, json.Unmarshal(created.Body.Bytes(), &createdTodo))
+	assert.Equal(t, []string{"family", "phone"}, createdTodo.Tags)
+	assert.True(t, createdTodo.DueDate.HasTime)
+
+	encoded, err := msgpack.Marshal(map[string]any{
+		"title": "water plants", "due_date": "2025-05-03", "priority": "medium", "status": "planned",
+	})
+	assert.NoError(t, err)
+	created = do(http.MethodPost, "/todo/", "application/msgpack", "application/msgpack", encoded)
+	assert.Equal(t, http.StatusCreated, created.Code)
+	var decoded map[string]any
+	assert.NoError(t, msgpack.Unmarshal(created.Body.Bytes(), &decoded))
+	assert.Equal(t, "2025-05-03", decoded["due_date"])
+
+	list := do(http.MethodGet, "/todo?fields=title,due_date", "", "text/csv", nil)
+	assert.Equal(t, http.StatusOK, list.Code)
+	assert.Equal(t, "text/csv", list.Header().Get("Content-Type"))
+	assert.Contains(t, list.Header().Values("Vary"), "Accept")
+	assert.Equal(t, "id,title,due_date\n"+
+		"1,pay rent,2025-05-01\n"+
+		"2,call mom,2025-05-02T18:00:00Z\n"+
+		"3,water plants,2025-05-03\n", list.Body.String())
+
+	fetched := do(http.MethodGet, "/todo/1", "", "application/yaml", nil)
+	assert.Equal(t, http.StatusOK, fetched.Code)
+	fetchedJSON := do(http.MethodGet, "/todo/1", "", "", nil)
+	assert.NotEqual(t, fetchedJSON.Header().Get("ETag"), fetched.Header().Get("ETag"))
+	request := httptest.NewRequest(http.MethodGet, "/v2/todo/1", nil)
+	request.Header.Set("Accept", "application/yaml")
+	request.Header.Set("If-None-Match", fetched.Header().Get("ETag"))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+
+	invalid := do(http.MethodPost, "/todo/", "application/yaml", "", []byte("title: [pay rent\n"))
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	assert.Equal(t, http.StatusNotAcceptable, do(http.MethodGet, "/todo/1", "", "application/xml", nil).Code)
+}
@@ -0,0 +1,17 @@
+package tagroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/handlers/taghandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+func Routes(repo *repository.TagPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", taghandlers.GetAllTags(repo))
+	r.Post("/merge", taghandlers.MergeTags(repo))
+	r.Put("/{name}", taghandlers.SetTagMetadata(repo))
+	r.Post("/{name}/rename", taghandlers.RenameTag(repo))
+	return r
+}
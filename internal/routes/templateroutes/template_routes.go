@@ -0,0 +1,19 @@
+package templateroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/handlers/templatehandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+func Routes(templateRepo *repository.TemplatePostgresRepository, todoRepo repository.TodoRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", templatehandlers.CreateTemplate(templateRepo))
+	r.Get("/", templatehandlers.GetAllTemplates(templateRepo))
+	r.Get("/{id}", templatehandlers.GetByIdTemplate(templateRepo))
+	r.Put("/{id}", templatehandlers.UpdateTemplate(templateRepo))
+	r.Delete("/{id}", templatehandlers.DeleteTemplate(templateRepo))
+	r.Post("/{id}/instantiate", templatehandlers.InstantiateTemplate(templateRepo, todoRepo))
+	return r
+}
@@ -0,0 +1,31 @@
+package timeroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/handlers/timehandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+func TimerRoutes(repo *repository.TimeEntryPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/start", timehandlers.StartTimer(repo))
+	r.Post("/stop", timehandlers.StopTimer(repo))
+	return r
+}
+
+func TimeEntryRoutes(repo *repository.TimeEntryPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", timehandlers.GetTimeEntries(repo))
+	r.Post("/", timehandlers.CreateTimeEntry(repo))
+	r.Delete("/{entryId}", timehandlers.DeleteTimeEntry(repo))
+	return r
+}
+
+func ReportRoutes(repo *repository.TimeEntryPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/time", timehandlers.GetTimeReport(repo))
+	return r
+}
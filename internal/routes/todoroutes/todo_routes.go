@@ -1,18 +1,50 @@
 package todoroutes
 
 import (
+	"github.com/GlebMoskalev/todo-api/internal/apiversion"
+	"github.com/GlebMoskalev/todo-api/internal/blobstore"
+	"github.com/GlebMoskalev/todo-api/internal/codec"
 	"github.com/GlebMoskalev/todo-api/internal/handlers/todohandlers"
+	"github.com/GlebMoskalev/todo-api/internal/models/todo"
 	"github.com/GlebMoskalev/todo-api/internal/repository"
 	"github.com/go-chi/chi/v5"
+	"net/http"
 )
 
-func Routes(repo *repository.TodoPostgresRepository) chi.Router {
+func Routes(repo repository.TodoRepository, store blobstore.BlobStore, version apiversion.Version) chi.Router {
 	r := chi.NewRouter()
+	// Todos are also read and written as CSV, YAML and MessagePack.
+	r.Use(codec.Middleware(todo.Todo{}))
 
 	r.Post("/", todohandlers.CreateTodo(repo))
-	r.Delete("/", todohandlers.DeleteTodos(repo))
-	r.Get("/{id}", todohandlers.GetByIdTodo(repo))
 	r.Get("/", todohandlers.GetAllTodos(repo))
-	r.Put("/", todohandlers.UpdateTodo(repo))
+	r.Get("/{id}", todohandlers.GetByIdTodo(repo))
+	r.Put("/{id}", todohandlers.UpdateTodoById(repo))
+	r.Patch("/{id}", todohandlers.PatchTodo(repo))
+	r.Delete("/{id}", todohandlers.DeleteTodo(repo, store))
+	r.Post("/reassign", todohandlers.ReassignTodos(repo))
+	r.Post("/bulk", todohandlers.BulkUpdateTodos(repo))
+	r.Post("/batch", todohandlers.BatchCreateTodos(repo))
+	r.Post("/{id}/snooze", todohandlers.SnoozeTodo(repo))
+	r.Delete("/{id}/snooze", todohandlers.UnsnoozeTodo(repo))
+	r.Post("/{id}/move", todohandlers.MoveTodo(repo))
+	r.Post("/{id}/duplicate", todohandlers.DuplicateTodo(repo))
+	r.Post("/{id}/archive", todohandlers.ArchiveTodo(repo, true))
+	r.Post("/{id}/unarchive", todohandlers.ArchiveTodo(repo, false))
+
+	// Legacy routes taking the ids in the body, kept for one deprecation
+	// period and dropped in v2.
+	if version < apiversion.V2 {
+		r.With(deprecated).Put("/", todohandlers.UpdateTodo(repo))
+		r.With(deprecated).Delete("/", todohandlers.DeleteTodos(repo, store))
+	}
 	return r
 }
+
+// deprecated marks responses of legacy routes with a Deprecation header.
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		next.ServeHTTP(w, r)
+	})
+}
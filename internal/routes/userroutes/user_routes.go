@@ -0,0 +1,18 @@
+package userroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/handlers/userhandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+func Routes(repo *repository.UserPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", userhandlers.CreateUser(repo))
+	r.Get("/", userhandlers.GetAllUsers(repo))
+	r.Get("/me", userhandlers.GetMe(repo))
+	r.Get("/{id}", userhandlers.GetByIdUser(repo))
+	r.Delete("/{id}", userhandlers.DeleteUser(repo))
+	return r
+}
@@ -0,0 +1,23 @@
+package vocabularyroutes
+
+import (
+	"github.com/GlebMoskalev/todo-api/internal/handlers/vocabularyhandlers"
+	"github.com/GlebMoskalev/todo-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes serves the admin endpoints for the configurable statuses and
+// priorities.
+func Routes(statusRepo *repository.StatusPostgresRepository, priorityRepo *repository.PriorityPostgresRepository) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/statuses", vocabularyhandlers.GetAllStatuses(statusRepo))
+	r.Post("/statuses", vocabularyhandlers.CreateStatus(statusRepo))
+	r.Put("/statuses/{value}", vocabularyhandlers.UpdateStatus(statusRepo))
+	r.Delete("/statuses/{value}", vocabularyhandlers.DeleteStatus(statusRepo))
+	r.Get("/priorities", vocabularyhandlers.GetAllPriorities(priorityRepo))
+	r.Post("/priorities", vocabularyhandlers.CreatePriority(priorityRepo))
+	r.Put("/priorities/{value}", vocabularyhandlers.UpdatePriority(priorityRepo))
+	r.Delete("/priorities/{value}", vocabularyhandlers.DeletePriority(priorityRepo))
+	return r
+}
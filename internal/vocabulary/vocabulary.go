@@ -0,0 +1,97 @@
+// Package vocabulary caches configurable value lists, such as statuses and
+// priorities, whose definitions live in the database.
+package vocabulary
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	valuePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// Validate checks the fields every definition has. kind names the vocabulary
+// in error messages, e.g. "status".
+func Validate(kind, value, name, color string) error {
+	if !valuePattern.MatchString(value) {
+		return fmt.Errorf("invalid %s value %q, expected lower case letters, digits and underscores", kind, value)
+	}
+	if strings.TrimSpace(name) == "" {
+		return errors.New(kind + " name must not be empty")
+	}
+	if color != "" && !colorPattern.MatchString(color) {
+		return fmt.Errorf("invalid value field \"Color\": %s, expected #RRGGBB", color)
+	}
+	return nil
+}
+
+// Registry holds the definitions D of values V in display order: by position,
+// then by value. It is safe for concurrent use and hands out copies only.
+type Registry[V ~string, D any] struct {
+	value    func(*D) V
+	position func(*D) int
+
+	mu          sync.RWMutex
+	definitions []*D
+	byValue     map[V]*D
+}
+
+// NewRegistry returns an empty registry reading the value and position of a
+// definition with the given functions.
+func NewRegistry[V ~string, D any](value func(*D) V, position func(*D) int) *Registry[V, D] {
+	return &Registry[V, D]{value: value, position: position, byValue: map[V]*D{}}
+}
+
+// Set replaces the definitions.
+func (r *Registry[V, D]) Set(definitions []*D) {
+	sorted := copyDefinitions(definitions)
+	slices.SortStableFunc(sorted, func(a, b *D) int {
+		if r.position(a) != r.position(b) {
+			return r.position(a) - r.position(b)
+		}
+		return strings.Compare(string(r.value(a)), string(r.value(b)))
+	})
+	byValue := make(map[V]*D, len(sorted))
+	for _, d := range sorted {
+		byValue[r.value(d)] = d
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.definitions = sorted
+	r.byValue = byValue
+}
+
+// All returns the definitions in display order.
+func (r *Registry[V, D]) All() []*D {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyDefinitions(r.definitions)
+}
+
+// Get returns the definition of value, reporting whether there is one.
+func (r *Registry[V, D]) Get(value V) (D, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.byValue[value]
+	if !ok {
+		var zero D
+		return zero, false
+	}
+	return *d, true
+}
+
+func copyDefinitions[D any](definitions []*D) []*D {
+	copied := make([]*D, 0, len(definitions))
+	for _, d := range definitions {
+		definition := *d
+		copied = append(copied, &definition)
+	}
+	return copied
+}
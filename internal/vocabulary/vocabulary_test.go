@@ -0,0 +1,42 @@
+package vocabulary
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type level string
+
+type definition struct {
+	Value    level
+	Position int
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(
+		func(d *definition) level { return d.Value },
+		func(d *definition) int { return d.Position },
+	)
+	_, ok := registry.Get("low")
+	assert.False(t, ok)
+
+	definitions := []*definition{{Value: "urgent", Position: 2}, {Value: "low", Position: 1}, {Value: "high", Position: 2}}
+	registry.Set(definitions)
+	definitions[0].Position = 0
+	assert.Equal(t, []*definition{{Value: "low", Position: 1}, {Value: "high", Position: 2}, {Value: "urgent", Position: 2}},
+		registry.All())
+
+	registry.All()[0].Position = 5
+	low, ok := registry.Get("low")
+	assert.True(t, ok)
+	assert.Equal(t, 1, low.Position)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("status", "on_hold", "On hold", "#00ff00"))
+	assert.NoError(t, Validate("status", "on_hold", "On hold", ""))
+	assert.EqualError(t, Validate("status", "On hold", "On hold", ""),
+		`invalid status value "On hold", expected lower case letters, digits and underscores`)
+	assert.EqualError(t, Validate("priority", "p1", " ", ""), "priority name must not be empty")
+	assert.Error(t, Validate("priority", "p1", "P1", "red"))
+}
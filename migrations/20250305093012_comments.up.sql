@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    todo_id int NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    author_id int NOT NULL,
+    body text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    edited_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS comments_todo_id_idx ON comments (todo_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS todos_tags_idx;
+DROP TABLE IF EXISTS tags;
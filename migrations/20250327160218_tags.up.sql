@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS tags (
+    name text PRIMARY KEY,
+    color text NOT NULL DEFAULT '',
+    description text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS todos_tags_idx ON todos USING GIN (tags);
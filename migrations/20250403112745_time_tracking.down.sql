@@ -0,0 +1,2 @@
+ALTER TABLE todos DROP COLUMN IF EXISTS estimate_seconds;
+DROP TABLE IF EXISTS time_entries;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS time_entries (
+    id SERIAL PRIMARY KEY,
+    todo_id int NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    user_id int NOT NULL,
+    started_at timestamptz NOT NULL,
+    ended_at timestamptz,
+    note text NOT NULL DEFAULT '',
+    CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+CREATE INDEX IF NOT EXISTS time_entries_todo_id_idx ON time_entries (todo_id);
+CREATE INDEX IF NOT EXISTS time_entries_started_at_idx ON time_entries (started_at);
+-- A timer is an entry without ended_at; each user may only run one at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS time_entries_running_timer_idx ON time_entries (user_id) WHERE ended_at IS NULL;
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS estimate_seconds bigint CHECK (estimate_seconds >= 0);
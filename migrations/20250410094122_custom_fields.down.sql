@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos_custom_fields_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS custom_fields;
+DROP TABLE IF EXISTS custom_fields;
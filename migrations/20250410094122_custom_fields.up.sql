@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS custom_fields (
+    id SERIAL PRIMARY KEY,
+    key text NOT NULL UNIQUE,
+    name text NOT NULL,
+    type text NOT NULL CHECK (type IN ('text', 'number', 'date', 'enum', 'boolean')),
+    options text[] NOT NULL DEFAULT '{}',
+    required bool NOT NULL DEFAULT false,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS custom_fields jsonb NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS todos_custom_fields_idx ON todos USING GIN (custom_fields jsonb_path_ops);
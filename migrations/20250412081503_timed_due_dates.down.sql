@@ -0,0 +1,3 @@
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS todos_due_at_has_date;
+ALTER TABLE todos DROP COLUMN IF EXISTS time_zone;
+ALTER TABLE todos DROP COLUMN IF EXISTS due_at;
@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS due_at timestamptz;
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS time_zone text NOT NULL DEFAULT '';
+ALTER TABLE todos ADD CONSTRAINT todos_due_at_has_date CHECK (due_at IS NULL OR due_date IS NOT NULL);
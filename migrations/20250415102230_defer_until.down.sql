@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS todos_defer_until_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS defer_until;
@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS defer_until timestamptz;
+
+CREATE INDEX IF NOT EXISTS todos_defer_until_idx ON todos (defer_until) WHERE defer_until IS NOT NULL;
@@ -0,0 +1,10 @@
+-- Ranks are compared byte-wise, so the column must not use a linguistic collation.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS rank text COLLATE "C";
+
+-- Existing todos keep their id order: zero-padded ids with a trailing '1'
+-- are valid keys (they never end in '0').
+UPDATE todos SET rank = lpad(id::text, 10, '0') || '1' WHERE rank IS NULL;
+
+ALTER TABLE todos ALTER COLUMN rank SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS todos_rank_idx ON todos (rank);
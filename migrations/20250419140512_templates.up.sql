@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS templates (
+    id SERIAL PRIMARY KEY,
+    name text NOT NULL,
+    title text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    tags text[] NOT NULL DEFAULT '{}',
+    priority priority NOT NULL,
+    due_offset text NOT NULL DEFAULT '',
+    items jsonb NOT NULL DEFAULT '[]',
+    created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS todos_archive_candidates_idx;
+DROP INDEX IF EXISTS todos_active_status_idx;
+DROP INDEX IF EXISTS todos_active_due_date_idx;
+DROP INDEX IF EXISTS todos_active_rank_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS terminal_at;
+ALTER TABLE todos DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,14 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS archived_at timestamptz;
+-- terminal_at is when the todo entered a terminal status; the archiver counts
+-- from it. Unarchiving resets it so the countdown starts over.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS terminal_at timestamptz;
+
+UPDATE todos SET terminal_at = now() WHERE status IN ('completed', 'canceled') AND terminal_at IS NULL;
+
+-- Active listings only touch unarchived rows, so their indexes skip archived ones.
+-- todos_rank_idx stays: new ranks are taken after the highest rank of any todo.
+CREATE INDEX IF NOT EXISTS todos_active_rank_idx ON todos (rank) WHERE archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS todos_active_due_date_idx ON todos (due_date) WHERE archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS todos_active_status_idx ON todos (status) WHERE archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS todos_archive_candidates_idx ON todos (terminal_at)
+    WHERE archived_at IS NULL AND terminal_at IS NOT NULL;
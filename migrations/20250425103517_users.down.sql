@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS todos_assignee_id_idx;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS created_by;
+ALTER TABLE todos DROP COLUMN IF EXISTS assignee_id;
+
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name text NOT NULL,
+    email text NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS assignee_id int REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS created_by int REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS todos_assignee_id_idx ON todos (assignee_id);
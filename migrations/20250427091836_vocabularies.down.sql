@@ -0,0 +1,16 @@
+-- Fails while todos or templates use values added after the up migration.
+ALTER TABLE templates DROP CONSTRAINT IF EXISTS templates_priority_fkey;
+ALTER TABLE todos
+    DROP CONSTRAINT IF EXISTS todos_priority_fkey,
+    DROP CONSTRAINT IF EXISTS todos_status_fkey;
+
+CREATE TYPE priority AS ENUM ('low', 'medium', 'high', 'urgent');
+CREATE TYPE status AS ENUM ('planned', 'in_progress', 'completed', 'canceled');
+
+ALTER TABLE todos
+    ALTER COLUMN status TYPE status USING status::status,
+    ALTER COLUMN priority TYPE priority USING priority::priority;
+ALTER TABLE templates ALTER COLUMN priority TYPE priority USING priority::priority;
+
+DROP TABLE IF EXISTS priorities;
+DROP TABLE IF EXISTS statuses;
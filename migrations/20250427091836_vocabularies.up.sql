@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS statuses (
+    value text PRIMARY KEY,
+    name text NOT NULL,
+    position int NOT NULL DEFAULT 0,
+    color text NOT NULL DEFAULT '',
+    terminal bool NOT NULL DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS priorities (
+    value text PRIMARY KEY,
+    name text NOT NULL,
+    position int NOT NULL DEFAULT 0,
+    color text NOT NULL DEFAULT ''
+);
+
+INSERT INTO statuses (value, name, position, color, terminal) VALUES
+    ('planned', 'Planned', 1, '#9e9e9e', false),
+    ('in_progress', 'In progress', 2, '#2196f3', false),
+    ('completed', 'Completed', 3, '#4caf50', true),
+    ('canceled', 'Canceled', 4, '#795548', true)
+ON CONFLICT (value) DO NOTHING;
+
+INSERT INTO priorities (value, name, position, color) VALUES
+    ('low', 'Low', 1, '#8bc34a'),
+    ('medium', 'Medium', 2, '#ffc107'),
+    ('high', 'High', 3, '#ff9800'),
+    ('urgent', 'Urgent', 4, '#f44336')
+ON CONFLICT (value) DO NOTHING;
+
+ALTER TABLE todos
+    ALTER COLUMN status TYPE text USING status::text,
+    ALTER COLUMN priority TYPE text USING priority::text;
+ALTER TABLE todos
+    ADD CONSTRAINT todos_status_fkey FOREIGN KEY (status) REFERENCES statuses (value),
+    ADD CONSTRAINT todos_priority_fkey FOREIGN KEY (priority) REFERENCES priorities (value);
+
+ALTER TABLE templates ALTER COLUMN priority TYPE text USING priority::text;
+ALTER TABLE templates ADD CONSTRAINT templates_priority_fkey FOREIGN KEY (priority) REFERENCES priorities (value);
+
+DROP TYPE IF EXISTS status;
+DROP TYPE IF EXISTS priority;
@@ -0,0 +1,3 @@
+-- Fails while duplicated attachments share a storage key.
+DROP INDEX IF EXISTS attachments_storage_key_idx;
+ALTER TABLE attachments ADD CONSTRAINT attachments_storage_key_key UNIQUE (storage_key);
@@ -0,0 +1,4 @@
+-- Duplicated todos reference the blobs of the original's attachments, so a
+-- storage key may now appear on several rows.
+ALTER TABLE attachments DROP CONSTRAINT IF EXISTS attachments_storage_key_key;
+CREATE INDEX IF NOT EXISTS attachments_storage_key_idx ON attachments (storage_key);
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS time_entries_touch_todo ON time_entries;
+DROP TRIGGER IF EXISTS attachments_touch_todo ON attachments;
+DROP TRIGGER IF EXISTS comments_touch_todo ON comments;
+DROP FUNCTION IF EXISTS touch_todo();
+DROP TRIGGER IF EXISTS todos_bump_version ON todos;
+DROP FUNCTION IF EXISTS bump_todo_version();
+ALTER TABLE todos DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE todos DROP COLUMN IF EXISTS version;
@@ -0,0 +1,40 @@
+-- version counts the changes to a todo and updated_at records the last one;
+-- together they back the ETag and Last-Modified headers.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+CREATE OR REPLACE FUNCTION bump_todo_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    NEW.updated_at := now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS todos_bump_version ON todos;
+CREATE TRIGGER todos_bump_version BEFORE UPDATE ON todos
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION bump_todo_version();
+
+-- Comment counts, logged time and attachments are part of a todo's
+-- representation, so changing them counts as a change to the todo.
+CREATE OR REPLACE FUNCTION touch_todo() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE todos SET updated_at = now() WHERE id = OLD.todo_id;
+    END IF;
+    IF TG_OP <> 'DELETE' AND (TG_OP = 'INSERT' OR NEW.todo_id <> OLD.todo_id) THEN
+        UPDATE todos SET updated_at = now() WHERE id = NEW.todo_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS comments_touch_todo ON comments;
+CREATE TRIGGER comments_touch_todo AFTER INSERT OR DELETE ON comments
+    FOR EACH ROW EXECUTE FUNCTION touch_todo();
+DROP TRIGGER IF EXISTS attachments_touch_todo ON attachments;
+CREATE TRIGGER attachments_touch_todo AFTER INSERT OR UPDATE OR DELETE ON attachments
+    FOR EACH ROW EXECUTE FUNCTION touch_todo();
+DROP TRIGGER IF EXISTS time_entries_touch_todo ON time_entries;
+CREATE TRIGGER time_entries_touch_todo AFTER INSERT OR UPDATE OR DELETE ON time_entries
+    FOR EACH ROW EXECUTE FUNCTION touch_todo();
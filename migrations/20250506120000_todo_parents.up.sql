@@ -0,0 +1,3 @@
+-- Todos instantiated from a template item point at the template's main todo.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS parent_id int REFERENCES todos(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS todos_parent_id_idx ON todos (parent_id) WHERE parent_id IS NOT NULL;